@@ -0,0 +1,164 @@
+// Package audit records changes to Auditable resources in the generated
+// audit_logs table and reads them back across resources. Each entry carries
+// who made the change (the impersonating admin, when there is one) and the
+// request it came from: request ID, client IP, user agent, API key and tenant.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// Entry is one row of audit_logs.
+type Entry struct {
+	ID           uuid.UUID `json:"id"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   uuid.UUID `json:"resource_id"`
+	Operation    string    `json:"operation"`
+	// ChangedFields maps each changed field to its old and new value.
+	ChangedFields json.RawMessage `json:"changed_fields"`
+	// CreatedBy is the real actor: the admin, during impersonation.
+	CreatedBy *uuid.UUID `json:"created_by"`
+	// ImpersonatedUserID is the user CreatedBy was acting as, if any.
+	ImpersonatedUserID *uuid.UUID `json:"impersonated_user_id,omitempty"`
+	RequestID          string     `json:"request_id,omitempty"`
+	IP                 string     `json:"ip,omitempty"`
+	UserAgent          string     `json:"user_agent,omitempty"`
+	// APIKeyID is the API key the request authenticated with, if any.
+	APIKeyID  *uuid.UUID `json:"api_key_id,omitempty"`
+	TenantID  *uuid.UUID `json:"tenant_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Request describes the HTTP request behind a change.
+type Request struct {
+	ID        string
+	IP        string
+	UserAgent string
+}
+
+// Config controls how audit entries are written.
+type Config struct {
+	// BestEffort logs a failed audit write and lets the change commit without
+	// its entry. By default the failure rolls back the change.
+	BestEffort bool
+}
+
+// requestContextKey and configContextKey are the private context key types.
+type (
+	requestContextKey struct{}
+	configContextKey  struct{}
+)
+
+// WithRequest stores the request recorded with audit entries written under
+// ctx. Retrieve it with RequestFromContext.
+func WithRequest(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, r)
+}
+
+// RequestFromContext returns the request stored by WithRequest or Middleware,
+// or the zero Request.
+func RequestFromContext(ctx context.Context) Request {
+	r, _ := ctx.Value(requestContextKey{}).(Request)
+	return r
+}
+
+// Middleware returns a Chi-compatible middleware that stores each request's
+// ID, client IP and user agent for Record, and cfg for audit writes made while
+// handling it. It must run after the RealIP and RequestID middleware; the
+// request ID is echoed in the X-Request-Id response header so a user can quote
+// it to support.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := Request{
+				ID:        chimiddleware.GetReqID(r.Context()),
+				IP:        r.RemoteAddr,
+				UserAgent: r.UserAgent(),
+			}
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				req.IP = host
+			}
+			if req.ID != "" {
+				w.Header().Set(chimiddleware.RequestIDHeader, req.ID)
+			}
+			ctx := context.WithValue(WithRequest(r.Context(), req), configContextKey{}, cfg)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DB is the database access Record needs. It is satisfied by *pgxpool.Pool,
+// pgx.Tx and the generated actions.DB.
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Record writes e to audit_logs on db, normally the transaction of the change
+// it describes. CreatedBy, ImpersonatedUserID, the request fields, APIKeyID
+// and TenantID are taken from ctx; ID and CreatedAt are set by the database.
+//
+// A failed write returns an error, so the caller's transaction rolls back,
+// unless Config.BestEffort is set for the request: the entry is then written
+// in a savepoint whose failure is logged and does not abort the transaction.
+func Record(ctx context.Context, db DB, e Entry) error {
+	fillFromContext(ctx, &e)
+	cfg, _ := ctx.Value(configContextKey{}).(Config)
+	if !cfg.BestEffort {
+		return insert(ctx, db, e)
+	}
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		return insert(ctx, tx, e)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "forge: audit log write failed", "resource_type", e.ResourceType,
+			"resource_id", e.ResourceID, "operation", e.Operation, "request_id", e.RequestID, "err", err)
+	}
+	return nil
+}
+
+// fillFromContext sets the actor and request fields of e from ctx.
+func fillFromContext(ctx context.Context, e *Entry) {
+	if actor := auth.ActorFromContext(ctx); actor != uuid.Nil {
+		e.CreatedBy = &actor
+	}
+	if _, ok := auth.ImpersonatorFromContext(ctx); ok {
+		user := auth.UserFromContext(ctx)
+		e.ImpersonatedUserID = &user
+	}
+	req := RequestFromContext(ctx)
+	e.RequestID, e.IP, e.UserAgent = req.ID, req.IP, req.UserAgent
+	if id, ok := auth.APIKeyFromContext(ctx); ok {
+		e.APIKeyID = &id
+	}
+	if id, ok := auth.TenantFromContext(ctx); ok {
+		e.TenantID = &id
+	}
+}
+
+func insert(ctx context.Context, db DB, e Entry) error {
+	_, err := db.Exec(ctx,
+		`INSERT INTO audit_logs (resource_type, resource_id, operation, changed_fields, created_by,
+		   impersonated_user_id, request_id, ip_address, user_agent, api_key_id, tenant_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11)`,
+		e.ResourceType, e.ResourceID, e.Operation, e.ChangedFields, e.CreatedBy,
+		e.ImpersonatedUserID, e.RequestID, e.IP, e.UserAgent, e.APIKeyID, e.TenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("insert audit log: %w", err)
+	}
+	return nil
+}
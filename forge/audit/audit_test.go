@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// fakeDB records Exec calls and fails them with err. Begin returns a fakeTx
+// on the same fakeDB, standing in for a savepoint.
+type fakeDB struct {
+	err       error
+	execs     [][]any
+	begins    int
+	rollbacks int
+}
+
+func (db *fakeDB) Exec(_ context.Context, _ string, args ...any) (pgconn.CommandTag, error) {
+	db.execs = append(db.execs, args)
+	return pgconn.CommandTag{}, db.err
+}
+
+func (db *fakeDB) Begin(context.Context) (pgx.Tx, error) {
+	db.begins++
+	return &fakeTx{db: db}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	db *fakeDB
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.db.Exec(ctx, sql, args...)
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.db.rollbacks++
+	return nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error { return nil }
+
+// requestContext runs Middleware over a request and returns the handler's
+// context.
+func requestContext(t *testing.T, cfg Config) context.Context {
+	t.Helper()
+	var ctx context.Context
+	h := chimiddleware.RequestID(Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.RemoteAddr = "203.0.113.7:52100"
+	req.Header.Set("User-Agent", "curl/8.0")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("X-Request-Id") == "" {
+		t.Error("Middleware did not echo the request ID")
+	}
+	return ctx
+}
+
+func TestMiddleware(t *testing.T) {
+	req := RequestFromContext(requestContext(t, Config{}))
+	if req.ID == "" || req.IP != "203.0.113.7" || req.UserAgent != "curl/8.0" {
+		t.Errorf("RequestFromContext = %+v", req)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	userID, keyID, tenantID := uuid.New(), uuid.New(), uuid.New()
+	ctx := requestContext(t, Config{})
+	ctx = auth.WithAPIKey(auth.WithTenant(auth.WithUser(ctx, userID), tenantID), keyID)
+
+	db := &fakeDB{}
+	if err := Record(ctx, db, Entry{ResourceType: "product", ResourceID: uuid.New(), Operation: "update"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if len(db.execs) != 1 || db.begins != 0 {
+		t.Fatalf("execs = %d, begins = %d; want one direct insert", len(db.execs), db.begins)
+	}
+	args := db.execs[0]
+	if *args[4].(*uuid.UUID) != userID || args[7] != "203.0.113.7" || args[8] != "curl/8.0" ||
+		*args[9].(*uuid.UUID) != keyID || *args[10].(*uuid.UUID) != tenantID {
+		t.Errorf("insert args = %v", args)
+	}
+}
+
+func TestRecord_Failure(t *testing.T) {
+	e := Entry{ResourceType: "product", ResourceID: uuid.New(), Operation: "delete"}
+	dbErr := errors.New("relation \"audit_logs\" does not exist")
+
+	// By default the error is returned so the change rolls back.
+	db := &fakeDB{err: dbErr}
+	if err := Record(requestContext(t, Config{}), db, e); !errors.Is(err, dbErr) {
+		t.Errorf("Record error = %v, want %v", err, dbErr)
+	}
+
+	// Best effort writes in a savepoint and swallows the failure.
+	db = &fakeDB{err: dbErr}
+	if err := Record(requestContext(t, Config{BestEffort: true}), db, e); err != nil {
+		t.Errorf("best-effort Record error = %v, want nil", err)
+	}
+	if db.begins != 1 || db.rollbacks == 0 {
+		t.Errorf("begins = %d, rollbacks = %d; want the savepoint rolled back", db.begins, db.rollbacks)
+	}
+}
+
+func TestListQuery(t *testing.T) {
+	actor := uuid.New()
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	last := Entry{ID: uuid.New(), CreatedAt: since.Add(time.Hour)}
+
+	sql, args, limit, err := listQuery(Filter{
+		ActorID:      &actor,
+		ResourceType: "product",
+		Since:        since,
+		Cursor:       encodeCursor(last),
+		Limit:        500,
+	})
+	if err != nil {
+		t.Fatalf("listQuery: %v", err)
+	}
+	for _, c := range []string{
+		"created_by = $1", "resource_type = $2", "created_at >= $3",
+		"(created_at, id) < ($4, $5)", "ORDER BY created_at DESC, id DESC", "LIMIT $6",
+	} {
+		if !strings.Contains(sql, c) {
+			t.Errorf("query missing %q:\n%s", c, sql)
+		}
+	}
+	if limit != MaxLimit || args[5] != MaxLimit+1 {
+		t.Errorf("limit = %d, LIMIT arg = %v; want %d capped, plus one", limit, args[5], MaxLimit)
+	}
+	if !args[3].(time.Time).Equal(last.CreatedAt) || args[4] != last.ID {
+		t.Errorf("cursor args = %v, %v; want %v, %v", args[3], args[4], last.CreatedAt, last.ID)
+	}
+
+	if _, _, _, err := listQuery(Filter{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Page size limits for List.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor is returned by List for a Filter.Cursor it did not issue.
+var ErrInvalidCursor = errors.New("audit: invalid cursor")
+
+// Querier is the database access List needs. It is satisfied by
+// *pgxpool.Pool, pgx.Tx and the generated actions.DB.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Filter selects audit entries for List. Zero fields match everything.
+type Filter struct {
+	// ActorID matches entries made by this user, including those made while
+	// they impersonated someone else.
+	ActorID *uuid.UUID
+	// TenantID restricts the entries to changes made within one tenant.
+	TenantID     *uuid.UUID
+	ResourceType string
+	Operation    string
+	// Since and Until bound created_at: Since inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+	// Cursor is a Page.NextCursor from a previous call.
+	Cursor string
+	// Limit is the page size: DefaultLimit when zero, at most MaxLimit.
+	Limit int
+}
+
+// Page is one page of audit entries, newest first.
+type Page struct {
+	Entries []Entry
+	HasMore bool
+	// NextCursor continues after the last entry; empty when !HasMore.
+	NextCursor string
+}
+
+// cursor is the position after which the next page starts, encoded as
+// base64(json) for an opaque token.
+type cursor struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func encodeCursor(e Entry) string {
+	b, _ := json.Marshal(cursor{ID: e.ID, CreatedAt: e.CreatedAt})
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// List returns the audit entries matching f across all resources, newest
+// first.
+func List(ctx context.Context, db Querier, f Filter) (Page, error) {
+	sql, args, limit, err := listQuery(f)
+	if err != nil {
+		return Page{}, err
+	}
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return Page{}, fmt.Errorf("list audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var page Page
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(
+			&e.ID, &e.ResourceType, &e.ResourceID, &e.Operation, &e.ChangedFields,
+			&e.CreatedBy, &e.ImpersonatedUserID, &e.RequestID, &e.IP, &e.UserAgent,
+			&e.APIKeyID, &e.TenantID, &e.CreatedAt,
+		); err != nil {
+			return Page{}, fmt.Errorf("scan audit log: %w", err)
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return Page{}, fmt.Errorf("list audit logs: %w", err)
+	}
+
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(page.Entries[limit-1])
+	}
+	return page, nil
+}
+
+// listQuery builds the SQL and arguments for f, fetching one row more than
+// the page size to learn whether another page follows.
+func listQuery(f Filter) (string, []any, int, error) {
+	limit := f.Limit
+	switch {
+	case limit <= 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.ActorID != nil {
+		where = append(where, "created_by = "+arg(*f.ActorID))
+	}
+	if f.TenantID != nil {
+		where = append(where, "tenant_id = "+arg(*f.TenantID))
+	}
+	if f.ResourceType != "" {
+		where = append(where, "resource_type = "+arg(f.ResourceType))
+	}
+	if f.Operation != "" {
+		where = append(where, "operation = "+arg(f.Operation))
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= "+arg(f.Since))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at < "+arg(f.Until))
+	}
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(c.CreatedAt), arg(c.ID)))
+	}
+
+	var b strings.Builder
+	b.WriteString(`SELECT id, resource_type, resource_id, operation, COALESCE(changed_fields, 'null'::jsonb),
+       created_by, impersonated_user_id, COALESCE(request_id, ''), COALESCE(ip_address, ''),
+       COALESCE(user_agent, ''), api_key_id, tenant_id, created_at
+FROM audit_logs`)
+	if len(where) > 0 {
+		b.WriteString("\nWHERE ")
+		b.WriteString(strings.Join(where, " AND "))
+	}
+	b.WriteString("\nORDER BY created_at DESC, id DESC\nLIMIT " + arg(limit+1))
+	return b.String(), args, limit, nil
+}
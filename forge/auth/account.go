@@ -0,0 +1,566 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/google/uuid"
+)
+
+// Purposes of the single-use tokens issued by Accounts.
+const (
+	AccountTokenVerifyEmail   = "verify_email"
+	AccountTokenResetPassword = "reset_password"
+)
+
+var (
+	// ErrUserNotFound is returned by UserStore lookups for unknown users.
+	ErrUserNotFound = errors.New("auth: user not found")
+	// ErrEmailTaken is returned by UserStore.CreateUser for a registered email.
+	ErrEmailTaken = errors.New("auth: email already registered")
+	// ErrInvalidAccountToken is returned by UserStore.ConsumeAccountToken for
+	// an unknown, expired, already used or other-purpose token.
+	ErrInvalidAccountToken = errors.New("auth: invalid or expired token")
+	// ErrInvalidCredentials is returned by Accounts.Authenticate for an unknown
+	// email or a wrong password.
+	ErrInvalidCredentials = errors.New("auth: invalid email or password")
+	// ErrEmailNotVerified is returned by Accounts.Authenticate when
+	// RequireVerifiedEmail is set and the user has not verified their email.
+	ErrEmailNotVerified = errors.New("auth: email not verified")
+)
+
+// User is an account of the built-in password lifecycle.
+type User struct {
+	ID    uuid.UUID
+	Email string // lower-cased
+	// PasswordHash is the HashPassword digest, empty for users who only sign
+	// in with OAuth or OIDC.
+	PasswordHash    string
+	EmailVerifiedAt *time.Time // nullable
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// UserStore defines the interface for account persistence used by Accounts.
+// pgstore.UserStore implements it on the generated users and account_tokens
+// tables.
+type UserStore interface {
+	// CreateUser creates a user, returning ErrEmailTaken if email is registered.
+	CreateUser(ctx context.Context, email, passwordHash string) (*User, error)
+	// GetUserByEmail returns the user with email, or ErrUserNotFound.
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	// GetUserByID returns the user with id, or ErrUserNotFound.
+	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
+	// SetPassword replaces the user's password hash.
+	SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+	// MarkEmailVerified records that the user proved ownership of their email.
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+	// CreateAccountToken stores a single-use token for purpose and returns its
+	// plaintext. Only a hash of the token should be persisted.
+	CreateAccountToken(ctx context.Context, userID uuid.UUID, purpose string, expiresAt time.Time) (string, error)
+	// ConsumeAccountToken deletes the token and returns its user, or
+	// ErrInvalidAccountToken.
+	ConsumeAccountToken(ctx context.Context, token, purpose string) (uuid.UUID, error)
+}
+
+// AccountsConfig configures Accounts.
+type AccountsConfig struct {
+	Users  UserStore
+	Mailer Mailer
+	// BaseURL is the app's public URL, e.g. "https://app.example.com", used
+	// for links in emails. It is required: links are never built from the
+	// request's Host header, which a client controls.
+	BaseURL string
+	// AppName names the app in email subjects. Defaults to "your account".
+	AppName string
+	// DisableSignup removes the /auth/signup routes, e.g. for invite-only apps.
+	DisableSignup bool
+	// RequireVerifiedEmail rejects password sign-in until the user follows
+	// the verification link. Signup then answers every submission with the
+	// same notice; without it a new account is signed in at once, which tells
+	// the visitor the email was not registered yet.
+	RequireVerifiedEmail bool
+	// ResetTokenTTL and VerifyTokenTTL bound how long emailed links stay
+	// valid. Defaults: 1 hour and 48 hours.
+	ResetTokenTTL  time.Duration
+	VerifyTokenTTL time.Duration
+	// MinPasswordLength defaults to 8.
+	MinPasswordLength int
+	// Limiter, when set, is the login limiter whose lockout of an account a
+	// password reset lifts.
+	Limiter *LoginLimiter
+	// Sessions, when set, is the session index used to sign the user out
+	// everywhere after a password reset or change. Without it every stored
+	// session is scanned.
+	Sessions *Sessions
+}
+
+// Accounts implements the built-in password account lifecycle: signup,
+// email verification, forgotten and reset passwords, and password changes.
+// Emailed links carry single-use, expiring tokens; resetting or changing a
+// password signs the user out of every other session.
+type Accounts struct {
+	cfg AccountsConfig
+}
+
+// NewAccounts validates cfg, applies defaults and returns an Accounts.
+func NewAccounts(cfg AccountsConfig) (*Accounts, error) {
+	if cfg.Users == nil {
+		return nil, errors.New("accounts: user store is required")
+	}
+	if cfg.Mailer == nil {
+		return nil, errors.New("accounts: mailer is required")
+	}
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, errors.New("accounts: base URL is required, e.g. https://app.example.com")
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.AppName == "" {
+		cfg.AppName = "your account"
+	}
+	if cfg.ResetTokenTTL <= 0 {
+		cfg.ResetTokenTTL = time.Hour
+	}
+	if cfg.VerifyTokenTTL <= 0 {
+		cfg.VerifyTokenTTL = 48 * time.Hour
+	}
+	if cfg.MinPasswordLength <= 0 {
+		cfg.MinPasswordLength = 8
+	}
+	return &Accounts{cfg: cfg}, nil
+}
+
+// dummyPasswordHash is compared against when an email is unknown, so
+// Authenticate takes as long for unknown users as for wrong passwords. It is
+// computed on first use to keep bcrypt out of program startup.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("forge-dummy-password")
+	return hash
+})
+
+// Authenticate verifies an email/password pair against the user store. It
+// satisfies PasswordAuthenticator and returns ErrInvalidCredentials or, with
+// RequireVerifiedEmail, ErrEmailNotVerified.
+func (a *Accounts) Authenticate(ctx context.Context, email, password string) (string, error) {
+	user, err := a.cfg.Users.GetUserByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, ErrUserNotFound) || (err == nil && user.PasswordHash == "") {
+		CheckPassword(password, dummyPasswordHash()) //nolint:errcheck
+		return "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", err
+	}
+	if CheckPassword(password, user.PasswordHash) != nil {
+		return "", ErrInvalidCredentials
+	}
+	if a.cfg.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return "", ErrEmailNotVerified
+	}
+	return user.ID.String(), nil
+}
+
+// HandleSignup returns an http.HandlerFunc for GET and POST /auth/signup.
+// A successful signup emails a verification link and signs the user in, or,
+// with RequireVerifiedEmail, redirects to the login page with a notice. A
+// registered email gets the same notice, while its owner is emailed that
+// someone tried to sign up, so the form never says which emails are taken.
+func (a *Accounts) HandleSignup(sm *scs.SessionManager, pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			renderPage(w, r, http.StatusOK, pages.Signup(PageData{}))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		email := normalizeEmail(r.FormValue("email"))
+		data := PageData{Email: email}
+
+		if _, err := mail.ParseAddress(email); err != nil {
+			data.Error = "Enter a valid email address."
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.Signup(data))
+			return
+		}
+		hash, msg := a.newPasswordHash(r.FormValue("password"), r.FormValue("password_confirm"))
+		if msg != "" {
+			data.Error = msg
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.Signup(data))
+			return
+		}
+		user, err := a.cfg.Users.CreateUser(ctx, email, hash)
+		if errors.Is(err, ErrEmailTaken) {
+			a.inBackground(r, "signup notice email", func(ctx context.Context) error {
+				return a.sendSignupNotice(ctx, email)
+			})
+			setFlash(sm, r, signupNotice)
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			log.Printf("signup error: %v", err)
+			http.Error(w, "Signup failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		a.inBackground(r, "verification email", func(ctx context.Context) error {
+			return a.sendVerification(ctx, user)
+		})
+
+		if a.cfg.RequireVerifiedEmail {
+			setFlash(sm, r, signupNotice)
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		if err := LoginUser(sm, r, user.ID.String(), user.Email); err != nil {
+			log.Printf("login session error: %v", err)
+			http.Error(w, "Signup failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// signupNotice is shown after a signup that needs verifying and after one
+// for a registered email alike.
+const signupNotice = "Check your email for a link to verify your account."
+
+// HandleForgotPassword returns an http.HandlerFunc for GET and POST
+// /auth/forgot-password. The POST emails a reset link when the email belongs
+// to a user and shows the same notice either way. The lookup and email happen
+// after the response, so its timing does not reveal which emails are
+// registered either.
+func (a *Accounts) HandleForgotPassword(sm *scs.SessionManager, pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			renderPage(w, r, http.StatusOK, pages.ForgotPassword(PageData{}))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		email := normalizeEmail(r.FormValue("email"))
+
+		a.inBackground(r, "password reset email", func(ctx context.Context) error {
+			user, err := a.cfg.Users.GetUserByEmail(ctx, email)
+			if errors.Is(err, ErrUserNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return a.sendPasswordReset(ctx, user)
+		})
+
+		renderPage(w, r, http.StatusOK, pages.ForgotPassword(PageData{
+			Notice: "If an account exists for " + email + ", we have emailed a link to reset its password.",
+		}))
+	}
+}
+
+// HandleResetPassword returns an http.HandlerFunc for GET and POST
+// /auth/reset-password. The GET renders the form for the emailed ?token=
+// without using it up; the POST consumes the token, sets the new password,
+// marks the email verified, lifts any login lockout and signs the user out of
+// every session.
+func (a *Accounts) HandleResetPassword(sm *scs.SessionManager, pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			renderPage(w, r, http.StatusOK, pages.ResetPassword(PageData{Token: r.URL.Query().Get("token")}))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		data := PageData{Token: r.FormValue("token")}
+
+		hash, msg := a.newPasswordHash(r.FormValue("password"), r.FormValue("password_confirm"))
+		if msg != "" {
+			data.Error = msg
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.ResetPassword(data))
+			return
+		}
+		userID, err := a.cfg.Users.ConsumeAccountToken(ctx, data.Token, AccountTokenResetPassword)
+		if errors.Is(err, ErrInvalidAccountToken) {
+			renderPage(w, r, http.StatusBadRequest, pages.ForgotPassword(PageData{
+				Error: "This reset link is invalid or has expired. Request a new one.",
+			}))
+			return
+		}
+		if err != nil {
+			log.Printf("reset token error: %v", err)
+			http.Error(w, "Password reset failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		if err := a.cfg.Users.SetPassword(ctx, userID, hash); err != nil {
+			log.Printf("set password error: %v", err)
+			http.Error(w, "Password reset failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		// Following the emailed link proves the user owns the address.
+		if err := a.cfg.Users.MarkEmailVerified(ctx, userID); err != nil {
+			log.Printf("mark email verified error: %v", err)
+		}
+		if err := a.revokeSessions(ctx, sm, userID.String()); err != nil {
+			log.Printf("invalidate sessions error: %v", err)
+		}
+		if a.cfg.Limiter != nil {
+			if user, err := a.cfg.Users.GetUserByID(ctx, userID); err != nil {
+				log.Printf("unlock lookup error: %v", err)
+			} else if err := a.cfg.Limiter.Unlock(r, user.Email); err != nil {
+				log.Printf("unlock error: %v", err)
+			}
+		}
+		if GetSessionUserID(sm, r) == userID.String() {
+			if err := LogoutUser(sm, r); err != nil {
+				log.Printf("logout error: %v", err)
+			}
+		}
+
+		setFlash(sm, r, "Your password has been reset. Sign in with your new password.")
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+	}
+}
+
+// HandleVerifyEmail returns an http.HandlerFunc for GET and POST
+// /auth/verify-email. A GET with the emailed ?token= verifies the email;
+// without one it renders a form to resend the link, which the POST does after
+// responding, like HandleForgotPassword.
+func (a *Accounts) HandleVerifyEmail(sm *scs.SessionManager, pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.Method == http.MethodPost {
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			email := normalizeEmail(r.FormValue("email"))
+			a.inBackground(r, "verification email", func(ctx context.Context) error {
+				user, err := a.cfg.Users.GetUserByEmail(ctx, email)
+				if errors.Is(err, ErrUserNotFound) || (err == nil && user.EmailVerifiedAt != nil) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				return a.sendVerification(ctx, user)
+			})
+			renderPage(w, r, http.StatusOK, pages.VerifyEmail(PageData{
+				Notice: "If " + email + " needs verifying, we have emailed a new link.",
+			}))
+			return
+		}
+
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			renderPage(w, r, http.StatusOK, pages.VerifyEmail(PageData{}))
+			return
+		}
+		userID, err := a.cfg.Users.ConsumeAccountToken(ctx, token, AccountTokenVerifyEmail)
+		if errors.Is(err, ErrInvalidAccountToken) {
+			renderPage(w, r, http.StatusBadRequest, pages.VerifyEmail(PageData{
+				Error: "This verification link is invalid or has expired. Request a new one.",
+			}))
+			return
+		}
+		if err == nil {
+			err = a.cfg.Users.MarkEmailVerified(ctx, userID)
+		}
+		if err != nil {
+			log.Printf("verify email error: %v", err)
+			http.Error(w, "Email verification failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		setFlash(sm, r, "Your email address has been verified.")
+		if GetSessionUserID(sm, r) != "" {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+	}
+}
+
+// HandleChangePassword returns an http.HandlerFunc for GET and POST
+// /auth/change-password. It requires a signed-in user and their current
+// password; on success every other session of the user is signed out and the
+// current session is renewed.
+func (a *Accounts) HandleChangePassword(sm *scs.SessionManager, pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, err := uuid.Parse(GetSessionUserID(sm, r))
+		if err != nil {
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			renderPage(w, r, http.StatusOK, pages.ChangePassword(PageData{}))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		user, err := a.cfg.Users.GetUserByID(ctx, userID)
+		if err != nil {
+			log.Printf("change password lookup error: %v", err)
+			http.Error(w, "Password change failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		if user.PasswordHash == "" || CheckPassword(r.FormValue("current_password"), user.PasswordHash) != nil {
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.ChangePassword(PageData{Error: "Your current password is incorrect."}))
+			return
+		}
+		hash, msg := a.newPasswordHash(r.FormValue("password"), r.FormValue("password_confirm"))
+		if msg != "" {
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.ChangePassword(PageData{Error: msg}))
+			return
+		}
+
+		if err := a.cfg.Users.SetPassword(ctx, userID, hash); err != nil {
+			log.Printf("set password error: %v", err)
+			http.Error(w, "Password change failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		if err := a.revokeSessions(ctx, sm, userID.String()); err != nil {
+			log.Printf("invalidate sessions error: %v", err)
+		}
+		// Keep this browser signed in under a fresh session token.
+		if err := LoginUser(sm, r, userID.String(), user.Email); err != nil {
+			log.Printf("login session error: %v", err)
+			http.Error(w, "Password change failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		renderPage(w, r, http.StatusOK, pages.ChangePassword(PageData{Notice: "Your password has been changed."}))
+	}
+}
+
+// newPasswordHash validates a new password and its confirmation and hashes
+// it. On invalid input it returns a message for the form instead.
+func (a *Accounts) newPasswordHash(password, confirm string) (hash, msg string) {
+	switch {
+	case len(password) < a.cfg.MinPasswordLength:
+		return "", fmt.Sprintf("Password must be at least %d characters.", a.cfg.MinPasswordLength)
+	case len(password) > bcryptMaxLength:
+		return "", fmt.Sprintf("Password must be at most %d characters.", bcryptMaxLength)
+	case password != confirm:
+		return "", "Passwords do not match."
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return "", "Password could not be set. Please try another."
+	}
+	return hash, ""
+}
+
+// emailTimeout bounds the background work of sending one account email.
+const emailTimeout = time.Minute
+
+// inBackground runs send after the handler returns, detached from the
+// request's cancellation, and logs its error. Account email is sent this way
+// so response times do not depend on whether an email is registered.
+func (a *Accounts) inBackground(r *http.Request, what string, send func(ctx context.Context) error) {
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("%s error: %v", what, err)
+		}
+	}()
+}
+
+// sendVerification emails user a link to /auth/verify-email.
+func (a *Accounts) sendVerification(ctx context.Context, user *User) error {
+	token, err := a.cfg.Users.CreateAccountToken(ctx, user.ID, AccountTokenVerifyEmail, time.Now().Add(a.cfg.VerifyTokenTTL))
+	if err != nil {
+		return err
+	}
+	link := a.link("/auth/verify-email", token)
+	return a.cfg.Mailer.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Verify your email for " + a.cfg.AppName,
+		Text: "Confirm your email address by opening this link:\n\n" + link +
+			"\n\nThe link expires in " + formatTTL(a.cfg.VerifyTokenTTL) + ". If you did not sign up, ignore this email.\n",
+	})
+}
+
+// sendSignupNotice tells the owner of a registered email that someone tried
+// to sign up with it.
+func (a *Accounts) sendSignupNotice(ctx context.Context, email string) error {
+	return a.cfg.Mailer.Send(ctx, Message{
+		To:      email,
+		Subject: "Sign-up attempt for " + a.cfg.AppName,
+		Text: "Someone tried to sign up with this email address, which already has an account. " +
+			"To sign in, open:\n\n" + a.cfg.BaseURL + "/auth/login" +
+			"\n\nIf you forgot your password, reset it at " + a.cfg.BaseURL + "/auth/forgot-password. If this was not you, ignore this email.\n",
+	})
+}
+
+// sendPasswordReset emails user a link to /auth/reset-password.
+func (a *Accounts) sendPasswordReset(ctx context.Context, user *User) error {
+	token, err := a.cfg.Users.CreateAccountToken(ctx, user.ID, AccountTokenResetPassword, time.Now().Add(a.cfg.ResetTokenTTL))
+	if err != nil {
+		return err
+	}
+	link := a.link("/auth/reset-password", token)
+	return a.cfg.Mailer.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Reset your password for " + a.cfg.AppName,
+		Text: "Choose a new password by opening this link:\n\n" + link +
+			"\n\nThe link expires in " + formatTTL(a.cfg.ResetTokenTTL) + " and can be used once. If you did not ask to reset your password, ignore this email.\n",
+	})
+}
+
+// link returns the absolute URL of path on BaseURL with the token query
+// parameter.
+func (a *Accounts) link(path, token string) string {
+	return a.cfg.BaseURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// revokeSessions signs userID out of every session, through the session index
+// when one is configured.
+func (a *Accounts) revokeSessions(ctx context.Context, sm *scs.SessionManager, userID string) error {
+	if a.cfg.Sessions != nil {
+		return a.cfg.Sessions.RevokeAll(ctx, userID)
+	}
+	return InvalidateUserSessions(ctx, sm, userID)
+}
+
+// formatTTL renders a token lifetime or wait for a message, e.g. "1 hour",
+// "30 minutes" or "8 seconds". Minutes are rounded up.
+func formatTTL(d time.Duration) string {
+	n, unit := int(d/time.Second), "second"
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		n, unit = int(d/time.Hour), "hour"
+	case d >= time.Minute:
+		n, unit = int((d+time.Minute-1)/time.Minute), "minute"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// normalizeEmail trims and lower-cases an email address, the form stored by
+// UserStore implementations.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,434 @@
+package auth_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/forgetest"
+)
+
+// memUserStore is an in-memory auth.UserStore.
+type memUserStore struct {
+	mu     sync.Mutex
+	users  map[uuid.UUID]*auth.User
+	tokens map[string]memAccountToken
+}
+
+type memAccountToken struct {
+	userID    uuid.UUID
+	purpose   string
+	expiresAt time.Time
+}
+
+func newMemUserStore() *memUserStore {
+	return &memUserStore{users: map[uuid.UUID]*auth.User{}, tokens: map[string]memAccountToken{}}
+}
+
+func (s *memUserStore) CreateUser(ctx context.Context, email, passwordHash string) (*auth.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return nil, auth.ErrEmailTaken
+		}
+	}
+	u := &auth.User{ID: uuid.New(), Email: email, PasswordHash: passwordHash, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	s.users[u.ID] = u
+	copied := *u
+	return &copied, nil
+}
+
+func (s *memUserStore) GetUserByEmail(ctx context.Context, email string) (*auth.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, auth.ErrUserNotFound
+}
+
+func (s *memUserStore) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (s *memUserStore) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID].PasswordHash = passwordHash
+	return nil
+}
+
+func (s *memUserStore) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.users[userID].EmailVerifiedAt = &now
+	return nil
+}
+
+func (s *memUserStore) CreateAccountToken(ctx context.Context, userID uuid.UUID, purpose string, expiresAt time.Time) (string, error) {
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[auth.HashSecret(token)] = memAccountToken{userID: userID, purpose: purpose, expiresAt: expiresAt}
+	return token, nil
+}
+
+func (s *memUserStore) ConsumeAccountToken(ctx context.Context, token, purpose string) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[auth.HashSecret(token)]
+	if !ok || t.purpose != purpose || time.Now().After(t.expiresAt) {
+		return uuid.Nil, auth.ErrInvalidAccountToken
+	}
+	delete(s.tokens, auth.HashSecret(token))
+	return t.userID, nil
+}
+
+// testBaseURL is the BaseURL of test Accounts. browser.do follows emailed
+// links to it on the test server.
+const testBaseURL = "https://app.example.com"
+
+// accountsApp serves the account routes plus "/", which prints the session's
+// user ID.
+type accountsApp struct {
+	users  *memUserStore
+	mailer *forgetest.Mailer
+	server *httptest.Server
+}
+
+func newAccountsApp(t *testing.T, cfg auth.AccountsConfig) *accountsApp {
+	t.Helper()
+
+	a := &accountsApp{users: newMemUserStore(), mailer: forgetest.NewMailer()}
+	cfg.Users, cfg.Mailer, cfg.BaseURL = a.users, a.mailer, testBaseURL
+	accounts, err := auth.NewAccounts(cfg)
+	if err != nil {
+		t.Fatalf("NewAccounts: %v", err)
+	}
+
+	sm := scs.New()
+	r := chi.NewRouter()
+	r.Use(sm.LoadAndSave)
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{Accounts: accounts, Limiter: cfg.Limiter, Sessions: cfg.Sessions})
+	r.With(auth.SessionUser(sm, nil, nil)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, auth.UserFromContext(r.Context()).String())
+	})
+	a.server = forgetest.NewApp(t, r)
+	return a
+}
+
+// browser is an HTTP client with its own cookie jar.
+type browser struct {
+	app    *accountsApp
+	client *http.Client
+}
+
+func (a *accountsApp) browser() *browser {
+	jar, _ := cookiejar.New(nil)
+	return &browser{app: a, client: &http.Client{Jar: jar}}
+}
+
+func (b *browser) do(t *testing.T, method, path string, form url.Values) (int, string) {
+	t.Helper()
+	target := path
+	if link, ok := strings.CutPrefix(path, testBaseURL); ok {
+		target = b.app.server.URL + link
+	} else if !strings.HasPrefix(target, "http") {
+		target = b.app.server.URL + path
+	}
+	var resp *http.Response
+	var err error
+	if method == http.MethodPost {
+		resp, err = b.client.PostForm(target, form)
+	} else {
+		resp, err = b.client.Get(target)
+	}
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// userID returns the signed-in user's ID, or uuid.Nil.
+func (b *browser) userID(t *testing.T) string {
+	t.Helper()
+	_, body := b.do(t, http.MethodGet, "/", nil)
+	return body
+}
+
+func (b *browser) login(t *testing.T, email, password string) (int, string) {
+	t.Helper()
+	return b.do(t, http.MethodPost, "/auth/login", url.Values{"email": {email}, "password": {password}})
+}
+
+func signupForm(email, password string) url.Values {
+	return url.Values{"email": {email}, "password": {password}, "password_confirm": {password}}
+}
+
+func TestNewAccounts_RequiresBaseURL(t *testing.T) {
+	for _, base := range []string{"", "app.example.com", "/app"} {
+		_, err := auth.NewAccounts(auth.AccountsConfig{Users: newMemUserStore(), Mailer: forgetest.NewMailer(), BaseURL: base})
+		if err == nil || !strings.Contains(err.Error(), "base URL") {
+			t.Errorf("NewAccounts(BaseURL: %q) error = %v, want a base URL error", base, err)
+		}
+	}
+}
+
+func TestAccounts_SignupAndVerifyEmail(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+	b := app.browser()
+
+	b.do(t, http.MethodPost, "/auth/signup", signupForm(" Ada@Example.com ", "correct horse"))
+	user, err := app.users.GetUserByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("user not created with normalized email: %v", err)
+	}
+	if got := b.userID(t); got != user.ID.String() {
+		t.Fatalf("signed-in user = %q, want %s", got, user.ID)
+	}
+	if user.EmailVerifiedAt != nil {
+		t.Fatal("email verified before following the link")
+	}
+
+	msgs := app.mailer.Wait(t, 1)
+	if len(msgs) != 1 || msgs[0].To != "ada@example.com" {
+		t.Fatalf("verification emails = %+v", msgs)
+	}
+	link := app.mailer.LastLink(t)
+	if !strings.Contains(link, "/auth/verify-email?token=") {
+		t.Fatalf("unexpected verification link %q", link)
+	}
+	b.do(t, http.MethodGet, link, nil)
+	if user, _ = app.users.GetUserByID(context.Background(), user.ID); user.EmailVerifiedAt == nil {
+		t.Fatal("email not verified after following the link")
+	}
+
+	// The link works once.
+	if status, _ := b.do(t, http.MethodGet, link, nil); status != http.StatusBadRequest {
+		t.Errorf("reused verification link status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestAccounts_SignupValidation(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		wantErr string
+	}{
+		{"invalid email", signupForm("not-an-email", "correct horse"), "valid email"},
+		{"short password", signupForm("bob@example.com", "short"), "at least 8"},
+		{"mismatched confirmation", url.Values{"email": {"bob@example.com"}, "password": {"correct horse"}, "password_confirm": {"correct horse!"}}, "do not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := app.browser().do(t, http.MethodPost, "/auth/signup", tt.form)
+			if status != http.StatusUnprocessableEntity || !strings.Contains(body, tt.wantErr) {
+				t.Errorf("status %d, body %q; want 422 mentioning %q", status, body, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccounts_SignupTakenEmail(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{RequireVerifiedEmail: true})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	app.mailer.Wait(t, 1)
+
+	// A registered email gets the notice a new account gets, and its owner is
+	// told about the attempt instead.
+	b := app.browser()
+	status, body := b.do(t, http.MethodPost, "/auth/signup", signupForm("ADA@example.com", "battery staple"))
+	if status != http.StatusOK || !strings.Contains(body, "Check your email") {
+		t.Errorf("taken email: status %d, body %q; want the verification notice", status, body)
+	}
+	if got := b.userID(t); got != uuid.Nil.String() {
+		t.Error("signed in with a taken email")
+	}
+	msgs := app.mailer.Wait(t, 2)
+	if msgs[1].To != "ada@example.com" || !strings.Contains(msgs[1].Text, testBaseURL+"/auth/forgot-password") {
+		t.Errorf("notice to the owner = %+v", msgs[1])
+	}
+}
+
+func TestAccounts_RequireVerifiedEmail(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{RequireVerifiedEmail: true})
+	b := app.browser()
+
+	_, body := b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	if !strings.Contains(body, "Check your email") {
+		t.Errorf("signup did not show the verification notice: %q", body)
+	}
+	if got := b.userID(t); got != uuid.Nil.String() {
+		t.Fatal("signed in before verifying email")
+	}
+	// An unverified account gets the wrong-password response, so the form
+	// does not confirm the password.
+	if _, body := b.login(t, "ada@example.com", "correct horse"); !strings.Contains(body, "Invalid email or password.") {
+		t.Fatalf("login before verification: %q", body)
+	}
+	if got := b.userID(t); got != uuid.Nil.String() {
+		t.Fatal("signed in before verifying email")
+	}
+
+	app.mailer.Wait(t, 1)
+	b.do(t, http.MethodGet, app.mailer.LastLink(t), nil)
+	b.login(t, "ada@example.com", "correct horse")
+	if got := b.userID(t); got == uuid.Nil.String() {
+		t.Fatal("login failed after verifying email")
+	}
+}
+
+func TestAccounts_PasswordReset(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+	other := app.browser()
+	other.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	if other.userID(t) == uuid.Nil.String() {
+		t.Fatal("signup did not sign in")
+	}
+	app.mailer.Wait(t, 1)
+
+	b := app.browser()
+	_, body := b.do(t, http.MethodPost, "/auth/forgot-password", url.Values{"email": {"ada@example.com"}})
+	if !strings.Contains(body, "If an account exists") {
+		t.Fatalf("forgot-password notice missing: %q", body)
+	}
+	app.mailer.Wait(t, 2)
+	link := app.mailer.LastLink(t)
+	if !strings.Contains(link, "/auth/reset-password?token=") {
+		t.Fatalf("unexpected reset link %q", link)
+	}
+	token, _ := url.Parse(link)
+
+	// Rendering the form does not use up the token.
+	if _, body := b.do(t, http.MethodGet, link, nil); !strings.Contains(body, token.Query().Get("token")) {
+		t.Fatalf("reset form does not carry the token: %q", body)
+	}
+	form := url.Values{"token": {token.Query().Get("token")}, "password": {"battery staple"}, "password_confirm": {"battery staple"}}
+	_, body = b.do(t, http.MethodPost, "/auth/reset-password", form)
+	if !strings.Contains(body, "Your password has been reset") {
+		t.Fatalf("reset did not redirect to login with a notice: %q", body)
+	}
+
+	if other.userID(t) != uuid.Nil.String() {
+		t.Error("existing session survived the password reset")
+	}
+	if _, body := b.login(t, "ada@example.com", "correct horse"); !strings.Contains(body, "Invalid email or password") {
+		t.Error("old password still works")
+	}
+	b.login(t, "ada@example.com", "battery staple")
+	if b.userID(t) == uuid.Nil.String() {
+		t.Error("new password does not work")
+	}
+
+	// The token works once.
+	if status, _ := b.do(t, http.MethodPost, "/auth/reset-password", form); status != http.StatusBadRequest {
+		t.Errorf("reused reset token status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestAccounts_ForgotPasswordUnknownEmail(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+
+	_, body := app.browser().do(t, http.MethodPost, "/auth/forgot-password", url.Values{"email": {"nobody@example.com"}})
+	if !strings.Contains(body, "If an account exists") {
+		t.Errorf("unknown email got a different response: %q", body)
+	}
+	if n := len(app.mailer.Messages()); n != 0 {
+		t.Errorf("sent %d emails for an unknown address", n)
+	}
+}
+
+func TestAccounts_ResetTokenExpires(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	user, _ := app.users.GetUserByEmail(context.Background(), "ada@example.com")
+	token, _ := app.users.CreateAccountToken(context.Background(), user.ID, auth.AccountTokenResetPassword, time.Now().Add(-time.Minute))
+
+	form := url.Values{"token": {token}, "password": {"battery staple"}, "password_confirm": {"battery staple"}}
+	if status, _ := app.browser().do(t, http.MethodPost, "/auth/reset-password", form); status != http.StatusBadRequest {
+		t.Errorf("expired token status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestAccounts_ChangePassword(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+
+	change := func(current string) (int, string) {
+		return b.do(t, http.MethodPost, "/auth/change-password", url.Values{
+			"current_password": {current}, "password": {"battery staple"}, "password_confirm": {"battery staple"},
+		})
+	}
+	if status, body := change("wrong"); status != http.StatusUnprocessableEntity || !strings.Contains(body, "current password is incorrect") {
+		t.Fatalf("wrong current password: %d %q", status, body)
+	}
+	if _, body := change("correct horse"); !strings.Contains(body, "Your password has been changed") {
+		t.Fatalf("change password: %q", body)
+	}
+
+	if got := b.userID(t); got != userID {
+		t.Errorf("changing the password signed out the current session: %q", got)
+	}
+	if other.userID(t) != uuid.Nil.String() {
+		t.Error("other session survived the password change")
+	}
+}
+
+func TestAccounts_ChangePasswordRequiresSession(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{})
+	b := app.browser()
+	b.client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	if status, _ := b.do(t, http.MethodGet, "/auth/change-password", nil); status != http.StatusFound {
+		t.Errorf("status = %d, want redirect to login", status)
+	}
+}
+
+func TestAccounts_DisableSignup(t *testing.T) {
+	app := newAccountsApp(t, auth.AccountsConfig{DisableSignup: true})
+	b := app.browser()
+
+	if status, _ := b.do(t, http.MethodGet, "/auth/signup", nil); status != http.StatusNotFound {
+		t.Errorf("signup status = %d, want %d", status, http.StatusNotFound)
+	}
+	if _, body := b.do(t, http.MethodGet, "/auth/login", nil); strings.Contains(body, "/auth/signup") || !strings.Contains(body, "/auth/forgot-password") {
+		t.Errorf("login page links: %q", body)
+	}
+}
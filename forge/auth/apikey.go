@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,20 +18,34 @@ const (
 	PrefixTest = "forg_test_"
 )
 
+// OpenAPI security scheme names used by generated operations. Operations list the
+// API key scopes they require under SecuritySchemeAPIKey; bearer tokens carry no scopes.
+const (
+	// SecuritySchemeBearer is the bearer token security scheme.
+	SecuritySchemeBearer = "bearerAuth"
+	// SecuritySchemeAPIKey is the API key security scheme.
+	SecuritySchemeAPIKey = "apiKeyAuth"
+)
+
 // validPrefixes holds all recognized API key prefixes.
 var validPrefixes = []string{PrefixLive, PrefixTest}
 
 // APIKey represents an API key stored in the database.
 type APIKey struct {
-	ID        uuid.UUID
-	Name      string
-	Key       string
-	Prefix    string     // "forg_live_" or "forg_test_"
-	UserID    uuid.UUID
-	Scopes    []string
-	ExpiresAt *time.Time // nullable
-	RevokedAt *time.Time // nullable
-	CreatedAt time.Time
+	ID   uuid.UUID
+	Name string
+	Key  string // plaintext; stores that keep only KeyHash set it on Create alone
+	// KeyHash is HashSecret(Key). When set, AuthMiddleware compares the
+	// presented key against it instead of Key.
+	KeyHash    string
+	Lookup     string // SecretLookup(Key), safe to display
+	Prefix     string // "forg_live_" or "forg_test_"
+	UserID     uuid.UUID
+	Scopes     []string
+	ExpiresAt  *time.Time // nullable
+	RevokedAt  *time.Time // nullable
+	LastUsedAt *time.Time // nullable
+	CreatedAt  time.Time
 }
 
 // APIKeyStore defines the interface for API key persistence.
@@ -72,3 +87,13 @@ func IsAPIKey(key string) bool {
 	_, ok := ValidateKeyPrefix(key)
 	return ok
 }
+
+// HasScopes reports whether granted contains every scope in required.
+func HasScopes(granted, required []string) bool {
+	for _, r := range required {
+		if !slices.Contains(granted, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -12,11 +14,48 @@ type userContextKey struct{}
 // roleContextKey is the private type for the role context key.
 type roleContextKey struct{}
 
+// permissionContextKey is the private type for the permission context key.
+type permissionContextKey struct{}
+
+// scopeContextKey is the private type for the scope context key.
+type scopeContextKey struct{}
+
+// impersonatorContextKey is the private type for the impersonator context key.
+type impersonatorContextKey struct{}
+
+// apiKeyContextKey is the private type for the API key ID context key.
+type apiKeyContextKey struct{}
+
+// RoleResolver loads the roles of an authenticated user. forge.App calls it once
+// per request for bearer-token, API-key and session principals (API keys resolve
+// to the roles of the user who owns the key) and stores the result with
+// WithUserRoles, so generated Permission checks apply. ctx carries the resolved
+// tenant, if any, for tenant-specific roles.
+type RoleResolver func(ctx context.Context, userID uuid.UUID) ([]string, error)
+
+// PermissionResolver loads the permission strings granted to an authenticated
+// user, such as "product.update". forge.App calls it alongside the RoleResolver
+// and stores the result with WithPermissions; generated Permission checks admit
+// a user holding the operation's permission string even without a listed role.
+type PermissionResolver func(ctx context.Context, userID uuid.UUID) ([]string, error)
+
+// WithUser stores the authenticated user's ID in the context without changing
+// any roles already stored.
+func WithUser(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userContextKey{}, userID)
+}
+
 // WithUserRole stores both a user ID and role in the context.
 // These values can be retrieved with UserFromContext and RoleFromContext.
 func WithUserRole(ctx context.Context, userID uuid.UUID, role string) context.Context {
+	return WithUserRoles(ctx, userID, role)
+}
+
+// WithUserRoles stores a user ID and all of the user's roles in the context.
+// Retrieve them with UserFromContext and RolesFromContext.
+func WithUserRoles(ctx context.Context, userID uuid.UUID, roles ...string) context.Context {
 	ctx = context.WithValue(ctx, userContextKey{}, userID)
-	ctx = context.WithValue(ctx, roleContextKey{}, role)
+	ctx = context.WithValue(ctx, roleContextKey{}, roles)
 	return ctx
 }
 
@@ -29,11 +68,105 @@ func UserFromContext(ctx context.Context) uuid.UUID {
 	return uuid.Nil
 }
 
+// WithImpersonator records that the context's user is being impersonated by
+// imp. Retrieve it with ImpersonatorFromContext.
+func WithImpersonator(ctx context.Context, imp Impersonator) context.Context {
+	return context.WithValue(ctx, impersonatorContextKey{}, imp)
+}
+
+// ImpersonatorFromContext returns the admin impersonating the context's user,
+// and false when the user is acting as themselves.
+func ImpersonatorFromContext(ctx context.Context) (Impersonator, bool) {
+	imp, ok := ctx.Value(impersonatorContextKey{}).(Impersonator)
+	return imp, ok
+}
+
+// ActorFromContext returns the ID of the person really making the request:
+// the impersonating admin during impersonation, otherwise UserFromContext.
+// Generated actions record it in created_by, updated_by and audit_logs.
+func ActorFromContext(ctx context.Context) uuid.UUID {
+	if imp, ok := ImpersonatorFromContext(ctx); ok {
+		return imp.ID
+	}
+	return UserFromContext(ctx)
+}
+
 // RoleFromContext retrieves the authenticated user's role from the context.
-// Returns an empty string if no role has been stored.
+// When several roles are stored it returns the first. Returns an empty string
+// if no role has been stored.
 func RoleFromContext(ctx context.Context) string {
-	if role, ok := ctx.Value(roleContextKey{}).(string); ok {
-		return role
+	if roles := RolesFromContext(ctx); len(roles) > 0 {
+		return roles[0]
 	}
 	return ""
 }
+
+// RolesFromContext retrieves all of the authenticated user's roles from the
+// context. Returns nil if no roles have been stored.
+func RolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(roleContextKey{}).([]string)
+	return roles
+}
+
+// HasRole reports whether the authenticated user holds any of the given roles.
+func HasRole(ctx context.Context, roles ...string) bool {
+	for _, role := range RolesFromContext(ctx) {
+		if role != "" && slices.Contains(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPermissions stores the authenticated user's permission strings in the
+// context. Retrieve them with PermissionsFromContext or check them with
+// HasPermission.
+func WithPermissions(ctx context.Context, permissions ...string) context.Context {
+	return context.WithValue(ctx, permissionContextKey{}, permissions)
+}
+
+// PermissionsFromContext retrieves the authenticated user's permission strings
+// from the context. Returns nil if none have been stored.
+func PermissionsFromContext(ctx context.Context) []string {
+	permissions, _ := ctx.Value(permissionContextKey{}).([]string)
+	return permissions
+}
+
+// HasPermission reports whether the authenticated user holds permission, e.g.
+// "product.update". A grant of "product.*" covers every operation on product
+// and "*" covers every permission.
+func HasPermission(ctx context.Context, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ".")
+	for _, p := range PermissionsFromContext(ctx) {
+		if p == permission || p == "*" || p == resource+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WithAPIKey stores the ID of the API key a request authenticated with.
+// Retrieve it with APIKeyFromContext.
+func WithAPIKey(ctx context.Context, keyID uuid.UUID) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, keyID)
+}
+
+// APIKeyFromContext returns the ID of the API key the request authenticated
+// with, and false for session and bearer-token requests.
+func APIKeyFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(apiKeyContextKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// WithScopes stores the scopes granted to the request's credential (an API
+// key's scopes or a JWT's scope claim). Retrieve them with ScopesFromContext.
+func WithScopes(ctx context.Context, scopes ...string) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, scopes)
+}
+
+// ScopesFromContext retrieves the scopes granted to the request's credential.
+// Returns nil if none have been stored.
+func ScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopeContextKey{}).([]string)
+	return scopes
+}
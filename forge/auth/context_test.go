@@ -0,0 +1,43 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		granted    []string
+		permission string
+		want       bool
+	}{
+		{[]string{"product.update"}, "product.update", true},
+		{[]string{"product.update"}, "product.delete", false},
+		{[]string{"product.*"}, "product.delete", true},
+		{[]string{"product.*"}, "order.read", false},
+		// The resource wildcard does not cover resources sharing a prefix
+		{[]string{"product.*"}, "productline.read", false},
+		{[]string{"*"}, "order.read", true},
+		{[]string{"order.read", "product.*"}, "product.update", true},
+		{nil, "product.read", false},
+		// Only "*" and "res.*" are wildcards
+		{[]string{"product.up*"}, "product.update", false},
+		{[]string{"*.read"}, "product.read", false},
+	}
+	for _, tt := range tests {
+		name := strings.Join(tt.granted, ",") + " " + tt.permission
+		t.Run(name, func(t *testing.T) {
+			ctx := auth.WithPermissions(context.Background(), tt.granted...)
+			if got := auth.HasPermission(ctx, tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%v, %q) = %v, want %v", tt.granted, tt.permission, got, tt.want)
+			}
+		})
+	}
+
+	if auth.HasPermission(context.Background(), "product.read") {
+		t.Error("HasPermission without stored permissions = true, want false")
+	}
+}
@@ -0,0 +1,160 @@
+package auth_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/forgetest"
+)
+
+var csrfFieldPattern = regexp.MustCompile(`name="csrf_token" value="([^"]*)"`)
+
+// newCSRFServer serves the auth routes with password login for "ada" /
+// "secret", plus GET and POST /token, which print the CSRF token, and
+// /hooks/*, exempt from the check.
+func newCSRFServer(t *testing.T) (string, *http.Client) {
+	t.Helper()
+
+	sm := scs.New()
+	r := chi.NewRouter()
+	r.Use(sm.LoadAndSave, auth.CSRF(sm, "/hooks/*"))
+	authenticate := func(ctx context.Context, email, password string) (string, error) {
+		if email == "ada" && password == "secret" {
+			return "0b8e3f5c-8d5e-4e08-9a37-0e4a4c7f9e61", nil
+		}
+		return "", auth.ErrInvalidCredentials
+	}
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{AuthenticateUser: authenticate})
+	printToken := func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, auth.CSRFToken(r.Context())) }
+	r.Get("/token", printToken)
+	r.Post("/token", printToken)
+	r.Post("/hooks/stripe", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, auth.GetSessionUserID(sm, r))
+	})
+
+	jar, _ := cookiejar.New(nil)
+	return forgetest.NewApp(t, r).URL, &http.Client{Jar: jar}
+}
+
+func get(t *testing.T, client *http.Client, target string) string {
+	t.Helper()
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func post(t *testing.T, client *http.Client, target string, form url.Values, header string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if header != "" {
+		req.Header.Set(auth.CSRFHeader, header)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", target, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestCSRF_RequiresToken(t *testing.T) {
+	srv, client := newCSRFServer(t)
+	token := get(t, client, srv+"/token")
+	if token == "" {
+		t.Fatal("no CSRF token issued")
+	}
+	if again := get(t, client, srv+"/token"); again != token {
+		t.Errorf("token changed between requests: %q, %q", token, again)
+	}
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		header string
+		want   int
+	}{
+		{"missing", nil, "", http.StatusForbidden},
+		{"wrong field", url.Values{"csrf_token": {"nope"}}, "", http.StatusForbidden},
+		{"wrong header", nil, "nope", http.StatusForbidden},
+		{"form field", url.Values{"csrf_token": {token}}, "", http.StatusOK},
+		{"header", nil, token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := post(t, client, srv+"/token", tt.form, tt.header); resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// Another client's session has a different token.
+	jar, _ := cookiejar.New(nil)
+	other := &http.Client{Jar: jar}
+	get(t, other, srv+"/token")
+	if resp := post(t, other, srv+"/token", nil, token); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("token accepted from another session: status %d", resp.StatusCode)
+	}
+}
+
+func TestCSRF_ExemptPaths(t *testing.T) {
+	srv, client := newCSRFServer(t)
+	if resp := post(t, client, srv+"/hooks/stripe", nil, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("exempt path status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestCSRF_LoginAndLogout(t *testing.T) {
+	srv, client := newCSRFServer(t)
+
+	// A forged login without the page's token is rejected.
+	if resp := post(t, client, srv+"/auth/login", url.Values{"email": {"ada"}, "password": {"secret"}}, ""); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login without token status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	m := csrfFieldPattern.FindStringSubmatch(get(t, client, srv+"/auth/login"))
+	if m == nil || m[1] == "" {
+		t.Fatal("login page has no csrf_token field")
+	}
+	post(t, client, srv+"/auth/login", url.Values{"email": {"ada"}, "password": {"secret"}, "csrf_token": {m[1]}}, "")
+	if get(t, client, srv+"/whoami") == "" {
+		t.Fatal("login with the page's token failed")
+	}
+
+	// Signing in issues a new token.
+	token := get(t, client, srv+"/token")
+	if token == m[1] {
+		t.Error("CSRF token not rotated at sign-in")
+	}
+
+	resp, err := client.Get(srv + "/auth/logout")
+	if err != nil {
+		t.Fatalf("GET /auth/logout: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || get(t, client, srv+"/whoami") == "" {
+		t.Errorf("GET /auth/logout = %d, want 405 without signing out", resp.StatusCode)
+	}
+	if resp := post(t, client, srv+"/auth/logout", nil, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("logout without token status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	post(t, client, srv+"/auth/logout", url.Values{"csrf_token": {token}}, "")
+	if get(t, client, srv+"/whoami") != "" {
+		t.Error("logout with token did not sign out")
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Types of AuthEvent.
+const (
+	// AuthEventLoginFailed records a rejected email/password pair.
+	AuthEventLoginFailed = "login.failed"
+	// AuthEventLoginThrottled records an attempt refused without checking the
+	// password because of a progressive delay or lockout.
+	AuthEventLoginThrottled = "login.throttled"
+	// AuthEventLoginLocked records an account or client IP being locked out.
+	AuthEventLoginLocked = "login.locked"
+	// AuthEventLoginUnlocked records a lockout lifted before it expired, e.g.
+	// by a password reset.
+	AuthEventLoginUnlocked = "login.unlocked"
+	// AuthEventImpersonationStarted records an admin starting to act as
+	// another user.
+	AuthEventImpersonationStarted = "impersonation.started"
+	// AuthEventImpersonationEnded records an admin stopping an impersonation.
+	AuthEventImpersonationEnded = "impersonation.ended"
+	// AuthEventImpersonationExpired records an impersonation ended because it
+	// outlived its maximum duration.
+	AuthEventImpersonationExpired = "impersonation.expired"
+)
+
+// AuthEvent is a security-relevant authentication event for the audit trail.
+type AuthEvent struct {
+	Type string
+	// Email is the submitted email, lower-cased; it need not belong to a user.
+	// For impersonation events it is the impersonating admin's email.
+	Email     string
+	IP        string
+	UserAgent string
+	// Detail adds context, e.g. "account" or "ip" for AuthEventLoginLocked,
+	// or the impersonated user for impersonation events.
+	Detail string
+	Time   time.Time
+}
+
+// AuthEventRecorder stores AuthEvents. pgstore.AuthEventStore writes them to
+// the generated auth_events table.
+type AuthEventRecorder interface {
+	RecordAuthEvent(ctx context.Context, event AuthEvent) error
+}
+
+// LogEventRecorder writes AuthEvents to the structured log.
+type LogEventRecorder struct{}
+
+// RecordAuthEvent logs event at warning level.
+func (LogEventRecorder) RecordAuthEvent(ctx context.Context, e AuthEvent) error {
+	slog.WarnContext(ctx, "forge: auth event", "type", e.Type, "email", e.Email,
+		"ip", e.IP, "user_agent", e.UserAgent, "detail", e.Detail)
+	return nil
+}
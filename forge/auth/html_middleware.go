@@ -1,9 +1,12 @@
 package auth
 
 import (
+	"log"
 	"net/http"
+	"slices"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/google/uuid"
 )
 
 // RequireSession returns a Chi-compatible middleware that enforces session-based
@@ -23,6 +26,59 @@ func RequireSession(sm *scs.SessionManager) func(http.Handler) http.Handler {
 	}
 }
 
+// SessionUser returns a Chi-compatible middleware that stores the session's
+// user in the forge/auth context (UserFromContext) with the roles granted at
+// sign-in (SessionKeyUserRoles) and, when the resolvers are non-nil, the
+// user's resolved roles (RolesFromContext) and permission strings
+// (PermissionsFromContext), so generated actions can apply Permission rules and
+// record who made a change. During impersonation the admin is stored too
+// (ImpersonatorFromContext). Requests without a logged-in user, or whose session
+// user ID is not a UUID, pass through unchanged. A resolver error responds with
+// 500 Internal Server Error.
+func SessionUser(sm *scs.SessionManager, roles RoleResolver, permissions PermissionResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := uuid.Parse(sm.GetString(r.Context(), SessionKeyUserID))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := WithUser(r.Context(), userID)
+			if imp, ok := sessionImpersonator(sm, ctx); ok {
+				ctx = WithImpersonator(ctx, imp)
+			}
+			sessionRoles, _ := sm.Get(r.Context(), SessionKeyUserRoles).([]string)
+			if len(sessionRoles) > 0 {
+				ctx = WithUserRoles(ctx, userID, sessionRoles...)
+			}
+			if roles != nil {
+				userRoles, err := roles(ctx, userID)
+				if err != nil {
+					log.Printf("role resolver error: %v", err)
+					http.Error(w, "Could not load user roles.", http.StatusInternalServerError)
+					return
+				}
+				for _, role := range sessionRoles {
+					if !slices.Contains(userRoles, role) {
+						userRoles = append(userRoles, role)
+					}
+				}
+				ctx = WithUserRoles(ctx, userID, userRoles...)
+			}
+			if permissions != nil {
+				userPermissions, err := permissions(ctx, userID)
+				if err != nil {
+					log.Printf("permission resolver error: %v", err)
+					http.Error(w, "Could not load user permissions.", http.StatusInternalServerError)
+					return
+				}
+				ctx = WithPermissions(ctx, userPermissions...)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // GetSessionUserID returns the authenticated user's ID string from the session.
 // Returns an empty string when no user is logged in.
 func GetSessionUserID(sm *scs.SessionManager, r *http.Request) string {
@@ -35,7 +91,8 @@ func GetSessionUserEmail(sm *scs.SessionManager, r *http.Request) string {
 	return sm.GetString(r.Context(), SessionKeyUserEmail)
 }
 
-// LoginUser writes the user's ID and email into the session. It calls
+// LoginUser writes the user's ID and email into the session, clearing the
+// roles and impersonation state of any previous sign-in. It calls
 // sm.RenewToken first to rotate the session ID and prevent session fixation
 // attacks. Must be called after the session middleware has loaded the session
 // (i.e. inside an HTTP handler, not before SessionMiddleware in the chain).
@@ -47,6 +104,17 @@ func LoginUser(sm *scs.SessionManager, r *http.Request, userID, email string) er
 	}
 	sm.Put(r.Context(), SessionKeyUserID, userID)
 	sm.Put(r.Context(), SessionKeyUserEmail, email)
+	// Roles granted by a previous sign-in (e.g. an OIDC groups claim) belong
+	// to that sign-in; the caller stores any the new one grants.
+	sm.Remove(r.Context(), SessionKeyUserRoles)
+	// Signing in ends any impersonation the session was used for.
+	sm.Remove(r.Context(), sessionKeyImpersonatorID)
+	sm.Remove(r.Context(), sessionKeyImpersonatorEmail)
+	sm.Remove(r.Context(), sessionKeyImpersonatorRoles)
+	sm.Remove(r.Context(), sessionKeyImpersonateUntil)
+	// A fresh CSRF token is issued on the next request, so a token seen
+	// before sign-in is useless afterwards.
+	sm.Remove(r.Context(), sessionKeyCSRF)
 	return nil
 }
 
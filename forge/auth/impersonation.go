@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/google/uuid"
+)
+
+// Session keys of an impersonation. While they are set, SessionKeyUserID
+// holds the impersonated user and these hold the admin to restore.
+const (
+	sessionKeyImpersonatorID    = "impersonator_id"
+	sessionKeyImpersonatorEmail = "impersonator_email"
+	sessionKeyImpersonatorRoles = "impersonator_roles"
+	sessionKeyImpersonateUntil  = "impersonate_until" // Unix seconds
+)
+
+// Impersonator is the admin acting as another user during impersonation.
+type Impersonator struct {
+	ID    uuid.UUID
+	Email string
+	// UserEmail is the email of the impersonated user.
+	UserEmail string
+	// Until is when the impersonation ends by itself.
+	Until time.Time
+}
+
+// sessionImpersonator returns the impersonator recorded in the session of
+// ctx, and false outside impersonation.
+func sessionImpersonator(sm *scs.SessionManager, ctx context.Context) (Impersonator, bool) {
+	id, err := uuid.Parse(sm.GetString(ctx, sessionKeyImpersonatorID))
+	if err != nil {
+		return Impersonator{}, false
+	}
+	return Impersonator{
+		ID:        id,
+		Email:     sm.GetString(ctx, sessionKeyImpersonatorEmail),
+		UserEmail: sm.GetString(ctx, SessionKeyUserEmail),
+		Until:     time.Unix(sm.GetInt64(ctx, sessionKeyImpersonateUntil), 0),
+	}, true
+}
+
+// sessionOwner returns the user who signed in to the session of ctx: the
+// impersonator during impersonation, otherwise the session user.
+func sessionOwner(sm *scs.SessionManager, ctx context.Context) string {
+	if id := sm.GetString(ctx, sessionKeyImpersonatorID); id != "" {
+		return id
+	}
+	return sm.GetString(ctx, SessionKeyUserID)
+}
+
+// DenyImpersonation returns a Chi-compatible middleware that refuses requests
+// made while impersonating another user with 403 Forbidden. RegisterOAuthRoutes
+// applies it to the password, session and 2FA pages; use it for other
+// operations only the account holder may perform.
+func DenyImpersonation(sm *scs.SessionManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sm.GetString(r.Context(), sessionKeyImpersonatorID) != "" {
+				http.Error(w, "Not available while impersonating another user.", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ImpersonationConfig configures Impersonation.
+type ImpersonationConfig struct {
+	// AllowedRoles lists roles that may impersonate other users. Users holding
+	// one in any tenant cannot be impersonated themselves. Default: "admin".
+	AllowedRoles []string
+	// Roles loads the roles checked against AllowedRoles.
+	Roles RoleResolver
+	// AllRoles loads the roles a user holds in every tenant, which are checked
+	// before impersonating them, so a tenant's admin is refused too. RBAC.AllRoles
+	// satisfies it. Default: Roles, which must then ignore the tenant.
+	AllRoles RoleResolver
+	// Users, when set, checks that the impersonated user exists and supplies
+	// their email.
+	Users UserStore
+	// Events records when impersonations start and end. Default:
+	// LogEventRecorder.
+	Events AuthEventRecorder
+	// MaxDuration bounds how long an impersonation lasts before the admin's
+	// own session is restored. Default: 1 hour.
+	MaxDuration time.Duration
+	// Now returns the current time. Tests set it to a fake clock. Default:
+	// time.Now.
+	Now func() time.Time
+}
+
+// Impersonation lets admins act as another user, e.g. so support staff see
+// the app as a customer does. POST /auth/impersonate swaps the session's user
+// while remembering the admin, who is restored by POST /auth/impersonate/stop
+// or once MaxDuration has passed. Throughout, ImpersonatorFromContext and
+// ActorFromContext identify the admin, so changes are attributed to them.
+type Impersonation struct {
+	sm  *scs.SessionManager
+	cfg ImpersonationConfig
+}
+
+// NewImpersonation validates cfg, applies defaults and returns an
+// Impersonation of sessions in sm.
+func NewImpersonation(sm *scs.SessionManager, cfg ImpersonationConfig) (*Impersonation, error) {
+	if cfg.Roles == nil {
+		return nil, errors.New("impersonation: a role resolver is required")
+	}
+	if len(cfg.AllowedRoles) == 0 {
+		cfg.AllowedRoles = []string{"admin"}
+	}
+	if cfg.AllRoles == nil {
+		cfg.AllRoles = cfg.Roles
+	}
+	if cfg.Events == nil {
+		cfg.Events = LogEventRecorder{}
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = time.Hour
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Impersonation{sm: sm, cfg: cfg}, nil
+}
+
+// allowed reports whether userID, with the roles granted at sign-in, holds a
+// role in AllowedRoles.
+func (i *Impersonation) allowed(ctx context.Context, userID uuid.UUID, sessionRoles []string) (bool, error) {
+	roles, err := i.cfg.Roles(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return i.anyAllowed(slices.Concat(roles, sessionRoles)), nil
+}
+
+// privileged reports whether userID holds a role in AllowedRoles in any
+// tenant.
+func (i *Impersonation) privileged(ctx context.Context, userID uuid.UUID) (bool, error) {
+	roles, err := i.cfg.AllRoles(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return i.anyAllowed(roles), nil
+}
+
+// anyAllowed reports whether roles include one in AllowedRoles.
+func (i *Impersonation) anyAllowed(roles []string) bool {
+	for _, role := range roles {
+		if slices.Contains(i.cfg.AllowedRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// start makes the session act as target while keeping the signed-in admin.
+// The token is renewed as at sign-in.
+func (i *Impersonation) start(r *http.Request, target uuid.UUID, targetEmail string) error {
+	ctx := r.Context()
+	actorID, actorEmail := GetSessionUserID(i.sm, r), GetSessionUserEmail(i.sm, r)
+	actorRoles, _ := i.sm.Get(ctx, SessionKeyUserRoles).([]string)
+	if err := i.sm.RenewToken(ctx); err != nil {
+		return err
+	}
+	i.sm.Put(ctx, sessionKeyImpersonatorID, actorID)
+	i.sm.Put(ctx, sessionKeyImpersonatorEmail, actorEmail)
+	if len(actorRoles) > 0 {
+		i.sm.Put(ctx, sessionKeyImpersonatorRoles, actorRoles)
+	}
+	i.sm.Put(ctx, sessionKeyImpersonateUntil, i.cfg.Now().Add(i.cfg.MaxDuration).Unix())
+	i.sm.Put(ctx, SessionKeyUserID, target.String())
+	i.sm.Put(ctx, SessionKeyUserEmail, targetEmail)
+	// The admin's sign-in roles must not carry over to the user.
+	i.sm.Remove(ctx, SessionKeyUserRoles)
+	i.sm.Remove(ctx, sessionKeyCSRF)
+	i.record(r, AuthEventImpersonationStarted, actorEmail, target.String(), targetEmail)
+	return nil
+}
+
+// end restores the impersonating admin's own session and records event.
+func (i *Impersonation) end(r *http.Request, event string) error {
+	ctx := r.Context()
+	imp, ok := sessionImpersonator(i.sm, ctx)
+	if !ok {
+		return nil
+	}
+	userID := GetSessionUserID(i.sm, r)
+	roles, _ := i.sm.Get(ctx, sessionKeyImpersonatorRoles).([]string)
+	if err := i.sm.RenewToken(ctx); err != nil {
+		return err
+	}
+	i.sm.Put(ctx, SessionKeyUserID, imp.ID.String())
+	i.sm.Put(ctx, SessionKeyUserEmail, imp.Email)
+	if len(roles) > 0 {
+		i.sm.Put(ctx, SessionKeyUserRoles, roles)
+	}
+	i.sm.Remove(ctx, sessionKeyImpersonatorID)
+	i.sm.Remove(ctx, sessionKeyImpersonatorEmail)
+	i.sm.Remove(ctx, sessionKeyImpersonatorRoles)
+	i.sm.Remove(ctx, sessionKeyImpersonateUntil)
+	i.sm.Remove(ctx, sessionKeyCSRF)
+	i.record(r, event, imp.Email, userID, imp.UserEmail)
+	return nil
+}
+
+// record stores an impersonation event, logging failures so a broken
+// recorder never blocks the admin.
+func (i *Impersonation) record(r *http.Request, event, actorEmail, userID, userEmail string) {
+	detail := "user " + userID
+	if userEmail != "" {
+		detail += " (" + userEmail + ")"
+	}
+	err := i.cfg.Events.RecordAuthEvent(r.Context(), AuthEvent{
+		Type:      event,
+		Email:     actorEmail,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Detail:    detail,
+		Time:      i.cfg.Now(),
+	})
+	if err != nil {
+		log.Printf("auth event error: %v", err)
+	}
+}
+
+// Enforce is a Chi-compatible middleware that ends impersonations which
+// outlived MaxDuration, redirecting to / as the admin, and otherwise stores
+// the impersonator in the request context (ImpersonatorFromContext). Every
+// state-changing request made during impersonation is logged with both
+// users. It must run after the session is loaded (sm.LoadAndSave).
+func (i *Impersonation) Enforce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		imp, ok := sessionImpersonator(i.sm, ctx)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !i.cfg.Now().Before(imp.Until) {
+			if err := i.end(r, AuthEventImpersonationExpired); err != nil {
+				log.Printf("impersonation session error: %v", err)
+				http.Error(w, "Could not end impersonation. Please sign in again.", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			slog.InfoContext(ctx, "forge: impersonated request", "impersonator_id", imp.ID,
+				"user_id", GetSessionUserID(i.sm, r), "method", r.Method, "path", r.URL.Path)
+		}
+		next.ServeHTTP(w, r.WithContext(WithImpersonator(ctx, imp)))
+	})
+}
+
+// HandleStart returns an http.HandlerFunc for POST /auth/impersonate, which
+// makes the signed-in admin act as the user in the user_id form field and
+// redirects to /. Admins cannot impersonate each other, and an impersonation
+// must be stopped before another starts.
+func (i *Impersonation) HandleStart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		actorID, err := uuid.Parse(GetSessionUserID(i.sm, r))
+		if err != nil {
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		if _, ok := sessionImpersonator(i.sm, ctx); ok {
+			http.Error(w, "Stop the current impersonation first.", http.StatusConflict)
+			return
+		}
+		sessionRoles, _ := i.sm.Get(ctx, SessionKeyUserRoles).([]string)
+		allowed, err := i.allowed(ctx, actorID, sessionRoles)
+		if err != nil {
+			log.Printf("role resolver error: %v", err)
+			http.Error(w, "Could not load user roles.", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "You are not allowed to impersonate users.", http.StatusForbidden)
+			return
+		}
+
+		target, err := uuid.Parse(r.PostFormValue("user_id"))
+		if err != nil || target == actorID {
+			http.Error(w, "Choose another user to impersonate.", http.StatusBadRequest)
+			return
+		}
+		var targetEmail string
+		if i.cfg.Users != nil {
+			user, err := i.cfg.Users.GetUserByID(ctx, target)
+			if errors.Is(err, ErrUserNotFound) {
+				http.Error(w, "User not found.", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("impersonation user error: %v", err)
+				http.Error(w, "Could not load the user. Please try again.", http.StatusInternalServerError)
+				return
+			}
+			targetEmail = user.Email
+		}
+		privileged, err := i.privileged(ctx, target)
+		if err != nil {
+			log.Printf("role resolver error: %v", err)
+			http.Error(w, "Could not load user roles.", http.StatusInternalServerError)
+			return
+		}
+		if privileged {
+			http.Error(w, "Users who can impersonate cannot be impersonated.", http.StatusForbidden)
+			return
+		}
+
+		if err := i.start(r, target, targetEmail); err != nil {
+			log.Printf("impersonation session error: %v", err)
+			http.Error(w, "Could not start impersonation. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// HandleStop returns an http.HandlerFunc for POST /auth/impersonate/stop,
+// which restores the admin's own session and redirects to /.
+func (i *Impersonation) HandleStop() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := i.end(r, AuthEventImpersonationEnded); err != nil {
+			log.Printf("impersonation session error: %v", err)
+			http.Error(w, "Could not end impersonation. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,220 @@
+package auth_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/forgetest"
+)
+
+// impersonationApp is an accountsApp with impersonation on a fake clock. Its
+// "/actor" route prints ActorFromContext.
+type impersonationApp struct {
+	*accountsApp
+	events *memEvents
+	clock  *fakeClock
+
+	mu     sync.Mutex
+	admins map[uuid.UUID]bool
+	// tenantAdmins maps users to the tenant they are an admin in.
+	tenantAdmins map[uuid.UUID]uuid.UUID
+}
+
+func newImpersonationApp(t *testing.T) *impersonationApp {
+	t.Helper()
+
+	sm := scs.New()
+	app := &impersonationApp{
+		accountsApp:  &accountsApp{users: newMemUserStore(), mailer: forgetest.NewMailer()},
+		events:       &memEvents{},
+		clock:        &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)},
+		admins:       map[uuid.UUID]bool{},
+		tenantAdmins: map[uuid.UUID]uuid.UUID{},
+	}
+	impersonation, err := auth.NewImpersonation(sm, auth.ImpersonationConfig{
+		Roles:    app.roles,
+		AllRoles: app.allRoles,
+		Users:    app.users,
+		Events:   app.events,
+		Now:      app.clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("NewImpersonation: %v", err)
+	}
+	accounts, err := auth.NewAccounts(auth.AccountsConfig{Users: app.users, Mailer: app.mailer, BaseURL: testBaseURL})
+	if err != nil {
+		t.Fatalf("NewAccounts: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(sm.LoadAndSave, impersonation.Enforce)
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{Accounts: accounts, Impersonation: impersonation})
+	r.With(auth.SessionUser(sm, nil, nil)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, auth.UserFromContext(r.Context()).String())
+	})
+	r.With(auth.SessionUser(sm, nil, nil)).Get("/actor", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, auth.ActorFromContext(r.Context()).String())
+	})
+	app.server = forgetest.NewApp(t, r)
+	return app
+}
+
+// roles returns the user's roles in the tenant in ctx.
+func (a *impersonationApp) roles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	tenantID, ok := auth.TenantFromContext(ctx)
+	if a.admins[userID] || (ok && a.tenantAdmins[userID] == tenantID) {
+		return []string{"admin"}, nil
+	}
+	return nil, nil
+}
+
+// allRoles returns the user's roles in every tenant.
+func (a *impersonationApp) allRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.tenantAdmins[userID]; ok || a.admins[userID] {
+		return []string{"admin"}, nil
+	}
+	return nil, nil
+}
+
+// signup creates a signed-in user, an admin if admin is set.
+func (a *impersonationApp) signup(t *testing.T, email string, admin bool) (*browser, string) {
+	t.Helper()
+	b := a.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm(email, "correct horse"))
+	id := b.userID(t)
+	a.mu.Lock()
+	a.admins[uuid.MustParse(id)] = admin
+	a.mu.Unlock()
+	return b, id
+}
+
+func TestImpersonation_StartAndStop(t *testing.T) {
+	app := newImpersonationApp(t)
+	admin, adminID := app.signup(t, "ada@example.com", true)
+	_, userID := app.signup(t, "bob@example.com", false)
+
+	admin.do(t, http.MethodPost, "/auth/impersonate", url.Values{"user_id": {userID}})
+	if got := admin.userID(t); got != userID {
+		t.Fatalf("session user = %q, want the impersonated %s", got, userID)
+	}
+	if _, body := admin.do(t, http.MethodGet, "/actor", nil); body != adminID {
+		t.Errorf("actor = %q, want the admin %s", body, adminID)
+	}
+
+	// Sensitive account pages are barred while impersonating.
+	if status, _ := admin.do(t, http.MethodGet, "/auth/change-password", nil); status != http.StatusForbidden {
+		t.Errorf("GET /auth/change-password status = %d, want 403", status)
+	}
+	status, _ := admin.do(t, http.MethodPost, "/auth/change-password", url.Values{
+		"current_password": {"correct horse"}, "password": {"battery staple"}, "password_confirm": {"battery staple"},
+	})
+	if status != http.StatusForbidden {
+		t.Errorf("POST /auth/change-password status = %d, want 403", status)
+	}
+
+	admin.do(t, http.MethodPost, "/auth/impersonate/stop", nil)
+	if got := admin.userID(t); got != adminID {
+		t.Errorf("after stopping, session user = %q, want the admin %s", got, adminID)
+	}
+	if _, body := admin.do(t, http.MethodGet, "/actor", nil); body != adminID {
+		t.Errorf("after stopping, actor = %q, want %s", body, adminID)
+	}
+	if status, _ := admin.do(t, http.MethodGet, "/auth/change-password", nil); status != http.StatusOK {
+		t.Errorf("after stopping, GET /auth/change-password status = %d, want 200", status)
+	}
+
+	if app.events.count(auth.AuthEventImpersonationStarted) != 1 || app.events.count(auth.AuthEventImpersonationEnded) != 1 {
+		t.Fatalf("events = %+v", app.events.events)
+	}
+	started := app.events.events[0]
+	if started.Email != "ada@example.com" || !strings.Contains(started.Detail, "bob@example.com") {
+		t.Errorf("started event = %+v", started)
+	}
+}
+
+func TestImpersonation_Refused(t *testing.T) {
+	app := newImpersonationApp(t)
+	admin, adminID := app.signup(t, "ada@example.com", true)
+	_, otherAdminID := app.signup(t, "eve@example.com", true)
+	user, userID := app.signup(t, "bob@example.com", false)
+
+	tests := []struct {
+		name   string
+		b      *browser
+		target string
+		want   int
+	}{
+		{"not an admin", user, adminID, http.StatusForbidden},
+		{"another admin", admin, otherAdminID, http.StatusForbidden},
+		{"unknown user", admin, uuid.NewString(), http.StatusNotFound},
+		{"self", admin, adminID, http.StatusBadRequest},
+		{"not a UUID", admin, "bob", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status, _ := tt.b.do(t, http.MethodPost, "/auth/impersonate", url.Values{"user_id": {tt.target}}); status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.want)
+		}
+	}
+	if user.userID(t) != userID || admin.userID(t) != adminID {
+		t.Error("a refused impersonation changed the session user")
+	}
+
+	// An impersonation must be stopped before another starts.
+	admin.do(t, http.MethodPost, "/auth/impersonate", url.Values{"user_id": {userID}})
+	if status, _ := admin.do(t, http.MethodPost, "/auth/impersonate", url.Values{"user_id": {userID}}); status != http.StatusConflict {
+		t.Errorf("nested impersonation status = %d, want 409", status)
+	}
+}
+
+func TestImpersonation_RefusesTenantAdmin(t *testing.T) {
+	app := newImpersonationApp(t)
+	admin, adminID := app.signup(t, "ada@example.com", true)
+	_, tenantAdminID := app.signup(t, "eve@example.com", false)
+	app.mu.Lock()
+	app.tenantAdmins[uuid.MustParse(tenantAdminID)] = uuid.New()
+	app.mu.Unlock()
+
+	// The impersonation routes have no tenant, but an admin of any tenant is
+	// still refused.
+	if status, _ := admin.do(t, http.MethodPost, "/auth/impersonate", url.Values{"user_id": {tenantAdminID}}); status != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", status)
+	}
+	if got := admin.userID(t); got != adminID {
+		t.Errorf("session user = %q, want the admin %s", got, adminID)
+	}
+}
+
+func TestImpersonation_Expires(t *testing.T) {
+	app := newImpersonationApp(t)
+	admin, adminID := app.signup(t, "ada@example.com", true)
+	_, userID := app.signup(t, "bob@example.com", false)
+
+	admin.do(t, http.MethodPost, "/auth/impersonate", url.Values{"user_id": {userID}})
+	app.clock.Advance(59 * time.Minute)
+	if got := admin.userID(t); got != userID {
+		t.Fatalf("impersonation ended early: session user = %q", got)
+	}
+
+	app.clock.Advance(2 * time.Minute)
+	if got := admin.userID(t); got != adminID {
+		t.Errorf("after expiry, session user = %q, want the admin %s", got, adminID)
+	}
+	if app.events.count(auth.AuthEventImpersonationExpired) != 1 {
+		t.Errorf("events = %+v", app.events.events)
+	}
+}
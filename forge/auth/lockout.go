@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// LoginAttempts is the failure state of one throttling key, an account
+// ("account:<email>") or a client IP ("ip:<addr>").
+type LoginAttempts struct {
+	// Failures counts failed attempts since the window started.
+	Failures      int
+	LastFailureAt time.Time
+	// LockedUntil is set while the key is locked out.
+	LockedUntil *time.Time
+}
+
+// LoginAttemptStore persists login failure counters so throttling holds across
+// app instances. pgstore.LoginAttemptStore uses the generated login_attempts
+// table.
+type LoginAttemptStore interface {
+	// GetLoginAttempts returns the state of key, the zero value if none.
+	GetLoginAttempts(ctx context.Context, key string) (LoginAttempts, error)
+	// RecordLoginFailure counts a failure for key, restarting the count when
+	// the first counted failure is older than window, and returns the new state.
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (LoginAttempts, error)
+	// LockLogin locks key until the given time and clears its failure count.
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// ResetLoginAttempts forgets the failures and lockout of key.
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+// LoginAttemptPruner is implemented by a LoginAttemptStore that can delete
+// stale counters. Each distinct email and IP tried leaves a row behind, so
+// without pruning the store grows with every credential-stuffing attempt.
+type LoginAttemptPruner interface {
+	// PruneLoginAttempts deletes keys that are not locked and whose last
+	// failure is older than olderThan, returning how many were deleted.
+	PruneLoginAttempts(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// LockoutConfig configures LoginLimiter. Zero values use the defaults.
+type LockoutConfig struct {
+	// MaxAccountFailures failed attempts for one email within Window lock the
+	// account for LockoutDuration. Default: 10.
+	MaxAccountFailures int
+	// MaxIPFailures failed attempts from one IP within Window, across all
+	// emails, lock the IP for LockoutDuration. Default: 100.
+	MaxIPFailures int
+	// Window is how long failures are counted. Default: 15 minutes.
+	Window time.Duration
+	// LockoutDuration is how long a lockout lasts. Default: 15 minutes.
+	LockoutDuration time.Duration
+	// DelayAfter failures of an account, each further attempt must wait a
+	// delay that starts at one second and doubles up to MaxDelay.
+	// Defaults: 3 and 30 seconds.
+	DelayAfter int
+	MaxDelay   time.Duration
+}
+
+// ThrottleError is returned by LoginLimiter.Allow when an attempt must wait.
+type ThrottleError struct {
+	// RetryAfter is how long until the next attempt is allowed.
+	RetryAfter time.Duration
+	// Locked reports a lockout rather than a progressive delay.
+	Locked bool
+}
+
+func (e *ThrottleError) Error() string {
+	if e.Locked {
+		return "auth: login locked for " + e.RetryAfter.String()
+	}
+	return "auth: login throttled for " + e.RetryAfter.String()
+}
+
+// LoginLimiter protects password login against brute force and credential
+// stuffing. It counts failures per account and per client IP, makes each
+// attempt on an account with repeated failures wait a growing delay, and locks
+// the account or IP out for a while when the failures pile up. Counters are
+// keyed by the submitted email whether or not it belongs to a user, so
+// throttling does not reveal which emails are registered.
+type LoginLimiter struct {
+	store  LoginAttemptStore
+	events AuthEventRecorder
+	cfg    LockoutConfig
+}
+
+// NewLoginLimiter returns a LoginLimiter storing counters in store and
+// recording events to events, which may be nil to use LogEventRecorder.
+func NewLoginLimiter(store LoginAttemptStore, events AuthEventRecorder, cfg LockoutConfig) *LoginLimiter {
+	if events == nil {
+		events = LogEventRecorder{}
+	}
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = 10
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = 100
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = 15 * time.Minute
+	}
+	if cfg.DelayAfter <= 0 {
+		cfg.DelayAfter = 3
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 30 * time.Second
+	}
+	return &LoginLimiter{store: store, events: events, cfg: cfg}
+}
+
+// Allow checks whether a login attempt for email from r may be verified. It
+// returns a *ThrottleError while the account or IP is locked or an account
+// delay has not elapsed.
+func (l *LoginLimiter) Allow(r *http.Request, email string) error {
+	ctx := r.Context()
+	ip, err := l.store.GetLoginAttempts(ctx, ipKey(r))
+	if err != nil {
+		return err
+	}
+	account, err := l.store.GetLoginAttempts(ctx, accountKey(email))
+	if err != nil {
+		return err
+	}
+
+	var throttle *ThrottleError
+	now := time.Now()
+	for _, a := range []LoginAttempts{ip, account} {
+		if a.LockedUntil == nil || !a.LockedUntil.After(now) {
+			continue
+		}
+		if wait := a.LockedUntil.Sub(now); throttle == nil || wait > throttle.RetryAfter {
+			throttle = &ThrottleError{RetryAfter: wait, Locked: true}
+		}
+	}
+	if throttle == nil {
+		if wait := account.LastFailureAt.Add(l.delay(account.Failures)).Sub(now); wait > 0 {
+			throttle = &ThrottleError{RetryAfter: wait}
+		}
+	}
+	if throttle == nil {
+		return nil
+	}
+	throttle.RetryAfter = max(throttle.RetryAfter.Round(time.Second), time.Second)
+	l.record(r, AuthEvent{Type: AuthEventLoginThrottled, Email: normalizeEmail(email)})
+	return throttle
+}
+
+// Fail records a failed attempt for email from r and locks the account or IP
+// when it reaches its limit.
+func (l *LoginLimiter) Fail(r *http.Request, email string) error {
+	ctx := r.Context()
+	email = normalizeEmail(email)
+	l.record(r, AuthEvent{Type: AuthEventLoginFailed, Email: email})
+
+	limits := []struct {
+		key, detail string
+		max         int
+	}{
+		{accountKey(email), "account", l.cfg.MaxAccountFailures},
+		{ipKey(r), "ip", l.cfg.MaxIPFailures},
+	}
+	var errs []error
+	for _, limit := range limits {
+		a, err := l.store.RecordLoginFailure(ctx, limit.key, l.cfg.Window)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if a.Failures < limit.max {
+			continue
+		}
+		if err := l.store.LockLogin(ctx, limit.key, time.Now().Add(l.cfg.LockoutDuration)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		l.record(r, AuthEvent{Type: AuthEventLoginLocked, Email: email, Detail: limit.detail})
+	}
+	return errors.Join(errs...)
+}
+
+// Succeed forgets the failures of email after a successful login. Failures
+// counted against the client IP are kept.
+func (l *LoginLimiter) Succeed(ctx context.Context, email string) error {
+	return l.store.ResetLoginAttempts(ctx, accountKey(email))
+}
+
+// Unlock lifts a lockout of email and forgets its failures, e.g. after the
+// user proves ownership of the address by resetting their password.
+func (l *LoginLimiter) Unlock(r *http.Request, email string) error {
+	key := accountKey(email)
+	a, err := l.store.GetLoginAttempts(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	if err := l.store.ResetLoginAttempts(r.Context(), key); err != nil {
+		return err
+	}
+	if a.LockedUntil != nil && a.LockedUntil.After(time.Now()) {
+		l.record(r, AuthEvent{Type: AuthEventLoginUnlocked, Email: normalizeEmail(email)})
+	}
+	return nil
+}
+
+// Prune deletes the counters that no longer affect throttling: keys with no
+// lockout in force and no failure within the window or the longest delay. It
+// does nothing when the store is not a LoginAttemptPruner. forge runs it
+// periodically while the app is listening.
+func (l *LoginLimiter) Prune(ctx context.Context) error {
+	pruner, ok := l.store.(LoginAttemptPruner)
+	if !ok {
+		return nil
+	}
+	_, err := pruner.PruneLoginAttempts(ctx, max(l.cfg.Window, l.cfg.MaxDelay))
+	return err
+}
+
+// delay returns how long after the last failure an account with the given
+// number of failures must wait.
+func (l *LoginLimiter) delay(failures int) time.Duration {
+	if failures < l.cfg.DelayAfter {
+		return 0
+	}
+	d := time.Second
+	for i := l.cfg.DelayAfter; i < failures && d < l.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, l.cfg.MaxDelay)
+}
+
+// record stores e with the request's client details, logging failures so a
+// broken recorder never blocks sign-in.
+func (l *LoginLimiter) record(r *http.Request, e AuthEvent) {
+	e.IP = clientIP(r)
+	e.UserAgent = r.UserAgent()
+	e.Time = time.Now()
+	if err := l.events.RecordAuthEvent(r.Context(), e); err != nil {
+		log.Printf("auth event error: %v", err)
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func ipKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// clientIP strips the port from r.RemoteAddr. forge's router replaces it with
+// the forwarded client address only for requests from server.trusted_proxies,
+// so a client cannot pick the address it is throttled under.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
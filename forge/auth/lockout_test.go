@@ -0,0 +1,315 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// memLoginAttemptStore is an in-memory auth.LoginAttemptStore.
+type memLoginAttemptStore struct {
+	mu    sync.Mutex
+	first map[string]time.Time
+	state map[string]auth.LoginAttempts
+}
+
+func newMemLoginAttemptStore() *memLoginAttemptStore {
+	return &memLoginAttemptStore{first: map[string]time.Time{}, state: map[string]auth.LoginAttempts{}}
+}
+
+func (s *memLoginAttemptStore) GetLoginAttempts(ctx context.Context, key string) (auth.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state[key], nil
+}
+
+func (s *memLoginAttemptStore) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (auth.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	a := s.state[key]
+	if first, ok := s.first[key]; !ok || now.Sub(first) >= window {
+		s.first[key] = now
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	s.state[key] = a
+	return a, nil
+}
+
+func (s *memLoginAttemptStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.state[key]
+	a.Failures, a.LockedUntil = 0, &until
+	s.state[key] = a
+	s.first[key] = time.Now()
+	return nil
+}
+
+func (s *memLoginAttemptStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state, key)
+	delete(s.first, key)
+	return nil
+}
+
+// PruneLoginAttempts implements auth.LoginAttemptPruner.
+func (s *memLoginAttemptStore) PruneLoginAttempts(ctx context.Context, olderThan time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key, a := range s.state {
+		if time.Since(a.LastFailureAt) > olderThan && (a.LockedUntil == nil || a.LockedUntil.Before(time.Now())) {
+			delete(s.state, key)
+			delete(s.first, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// brokenLoginAttemptStore fails reads while down is set.
+type brokenLoginAttemptStore struct {
+	*memLoginAttemptStore
+	down atomic.Bool
+}
+
+func (s *brokenLoginAttemptStore) GetLoginAttempts(ctx context.Context, key string) (auth.LoginAttempts, error) {
+	if s.down.Load() {
+		return auth.LoginAttempts{}, errors.New("connection refused")
+	}
+	return s.memLoginAttemptStore.GetLoginAttempts(ctx, key)
+}
+
+// memEvents records auth events.
+type memEvents struct {
+	mu     sync.Mutex
+	events []auth.AuthEvent
+}
+
+func (e *memEvents) RecordAuthEvent(ctx context.Context, event auth.AuthEvent) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+	return nil
+}
+
+// count returns how many events of type typ were recorded.
+func (e *memEvents) count(typ string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, ev := range e.events {
+		if ev.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+func newLimitedAccountsApp(t *testing.T, cfg auth.LockoutConfig) (*accountsApp, *memEvents) {
+	t.Helper()
+	events := &memEvents{}
+	limiter := auth.NewLoginLimiter(newMemLoginAttemptStore(), events, cfg)
+	return newAccountsApp(t, auth.AccountsConfig{Limiter: limiter}), events
+}
+
+func TestLoginLimiter_LocksAccount(t *testing.T) {
+	app, events := newLimitedAccountsApp(t, auth.LockoutConfig{MaxAccountFailures: 3, DelayAfter: 100})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	b := app.browser()
+	for range 3 {
+		if status, body := b.login(t, "ada@example.com", "wrong"); status != http.StatusOK || !strings.Contains(body, "Invalid email or password") {
+			t.Fatalf("failed login = %d %q, want the invalid credentials page", status, body)
+		}
+	}
+
+	resp, err := b.client.PostForm(app.server.URL+"/auth/login", url.Values{"email": {"Ada@Example.com"}, "password": {"correct horse"}})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("locked login status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if got := resp.Header.Get("Retry-After"); got == "" || got == "0" {
+		t.Errorf("Retry-After = %q, want the remaining lockout", got)
+	}
+	if b.userID(t) != uuid.Nil.String() {
+		t.Error("locked account signed in with the right password")
+	}
+
+	if got := events.count(auth.AuthEventLoginFailed); got != 3 {
+		t.Errorf("login.failed events = %d, want 3", got)
+	}
+	if got := events.count(auth.AuthEventLoginLocked); got != 1 {
+		t.Errorf("login.locked events = %d, want 1", got)
+	}
+	if got := events.count(auth.AuthEventLoginThrottled); got != 1 {
+		t.Errorf("login.throttled events = %d, want 1", got)
+	}
+}
+
+func TestLoginLimiter_UnknownEmailLooksTheSame(t *testing.T) {
+	app, _ := newLimitedAccountsApp(t, auth.LockoutConfig{MaxAccountFailures: 2, DelayAfter: 100})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	respond := func(email string) []string {
+		b := app.browser()
+		var bodies []string
+		for range 3 {
+			status, body := b.login(t, email, "wrong")
+			bodies = append(bodies, http.StatusText(status)+" "+strings.ReplaceAll(body, email, "EMAIL"))
+		}
+		return bodies
+	}
+	known, unknown := respond("ada@example.com"), respond("nobody@example.com")
+	for i := range known {
+		if known[i] != unknown[i] {
+			t.Errorf("attempt %d differs for a registered email:\n%s\nvs\n%s", i+1, known[i], unknown[i])
+		}
+	}
+	if !strings.HasPrefix(known[2], http.StatusText(http.StatusTooManyRequests)) {
+		t.Errorf("third attempt = %q, want a lockout", known[2])
+	}
+}
+
+func TestLoginLimiter_ProgressiveDelay(t *testing.T) {
+	app, _ := newLimitedAccountsApp(t, auth.LockoutConfig{DelayAfter: 2})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	b := app.browser()
+	b.login(t, "ada@example.com", "wrong")
+	b.login(t, "ada@example.com", "wrong")
+	status, body := b.login(t, "ada@example.com", "correct horse")
+	if status != http.StatusTooManyRequests || !strings.Contains(body, "Try again in 1 second") {
+		t.Fatalf("attempt during delay = %d %q, want a 1 second wait", status, body)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	b.login(t, "ada@example.com", "correct horse")
+	if b.userID(t) == uuid.Nil.String() {
+		t.Error("login after the delay failed")
+	}
+}
+
+func TestLoginLimiter_LocksIP(t *testing.T) {
+	app, events := newLimitedAccountsApp(t, auth.LockoutConfig{MaxIPFailures: 3, DelayAfter: 100})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	b := app.browser()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		b.login(t, email, "wrong")
+	}
+	if status, _ := b.login(t, "ada@example.com", "correct horse"); status != http.StatusTooManyRequests {
+		t.Errorf("login from a locked IP status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if got := events.count(auth.AuthEventLoginLocked); got != 1 {
+		t.Errorf("login.locked events = %d, want 1", got)
+	}
+}
+
+func TestLoginLimiter_FailsClosed(t *testing.T) {
+	store := &brokenLoginAttemptStore{memLoginAttemptStore: newMemLoginAttemptStore()}
+	limiter := auth.NewLoginLimiter(store, nil, auth.LockoutConfig{})
+	app := newAccountsApp(t, auth.AccountsConfig{Limiter: limiter})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	store.down.Store(true)
+	b := app.browser()
+	if status, _ := b.login(t, "ada@example.com", "correct horse"); status != http.StatusServiceUnavailable {
+		t.Errorf("login with an unreadable limiter store status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	if b.userID(t) != uuid.Nil.String() {
+		t.Error("signed in without the limiter being consulted")
+	}
+
+	store.down.Store(false)
+	b.login(t, "ada@example.com", "correct horse")
+	if b.userID(t) == uuid.Nil.String() {
+		t.Error("login failed after the store recovered")
+	}
+}
+
+func TestLoginLimiter_SuccessResetsAccount(t *testing.T) {
+	app, _ := newLimitedAccountsApp(t, auth.LockoutConfig{MaxAccountFailures: 3, DelayAfter: 100})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	b := app.browser()
+	b.login(t, "ada@example.com", "wrong")
+	b.login(t, "ada@example.com", "wrong")
+	b.login(t, "ada@example.com", "correct horse")
+	b.login(t, "ada@example.com", "wrong")
+	b.login(t, "ada@example.com", "wrong")
+	if status, _ := b.login(t, "ada@example.com", "correct horse"); status == http.StatusTooManyRequests {
+		t.Error("failures before a successful login still counted")
+	}
+}
+
+func TestLoginLimiter_PasswordResetUnlocks(t *testing.T) {
+	app, events := newLimitedAccountsApp(t, auth.LockoutConfig{MaxAccountFailures: 2, DelayAfter: 100})
+	app.browser().do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+
+	b := app.browser()
+	b.login(t, "ada@example.com", "wrong")
+	b.login(t, "ada@example.com", "wrong")
+	status, body := b.login(t, "ada@example.com", "correct horse")
+	if status != http.StatusTooManyRequests || !strings.Contains(body, "reset your password") {
+		t.Fatalf("locked login = %d %q, want a lockout offering a reset", status, body)
+	}
+
+	app.mailer.Wait(t, 1)
+	b.do(t, http.MethodPost, "/auth/forgot-password", url.Values{"email": {"ada@example.com"}})
+	app.mailer.Wait(t, 2)
+	token, _ := url.Parse(app.mailer.LastLink(t))
+	b.do(t, http.MethodPost, "/auth/reset-password", url.Values{
+		"token": {token.Query().Get("token")}, "password": {"battery staple"}, "password_confirm": {"battery staple"},
+	})
+
+	b.login(t, "ada@example.com", "battery staple")
+	if b.userID(t) == uuid.Nil.String() {
+		t.Error("password reset did not lift the lockout")
+	}
+	if got := events.count(auth.AuthEventLoginUnlocked); got != 1 {
+		t.Errorf("login.unlocked events = %d, want 1", got)
+	}
+}
+
+func TestLoginLimiter_Prune(t *testing.T) {
+	store := newMemLoginAttemptStore()
+	limiter := auth.NewLoginLimiter(store, &memEvents{}, auth.LockoutConfig{Window: time.Minute, MaxDelay: time.Second})
+	ctx := context.Background()
+	locked := time.Now().Add(time.Hour)
+	store.state = map[string]auth.LoginAttempts{
+		"account:stale@example.com":  {Failures: 2, LastFailureAt: time.Now().Add(-time.Hour)},
+		"account:recent@example.com": {Failures: 2, LastFailureAt: time.Now()},
+		"ip:203.0.113.9":             {LastFailureAt: time.Now().Add(-time.Hour), LockedUntil: &locked},
+	}
+
+	if err := limiter.Prune(ctx); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if _, ok := store.state["account:stale@example.com"]; ok {
+		t.Error("stale counter kept")
+	}
+	if _, ok := store.state["account:recent@example.com"]; !ok {
+		t.Error("counter within the window pruned")
+	}
+	if _, ok := store.state["ip:203.0.113.9"]; !ok {
+		t.Error("locked key pruned")
+	}
+}
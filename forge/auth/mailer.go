@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is an email sent by the account lifecycle, such as a verification
+// or password reset link.
+type Message struct {
+	To      string
+	Subject string
+	// Text is the plain-text body. HTML, when set, is sent as an alternative.
+	Text string
+	HTML string
+}
+
+// Mailer delivers account emails. Implement it to send through a provider's
+// API (SES, Postmark, ...) instead of SMTP.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer writes messages to the log instead of sending them. It is the
+// default when no SMTP server is configured, so links can be followed in
+// development.
+type LogMailer struct{}
+
+// Send logs msg.
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "forge: email not sent (no SMTP server configured)",
+		"to", msg.To, "subject", msg.Subject, "body", msg.Text)
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP server using STARTTLS when the
+// server offers it.
+type SMTPMailer struct {
+	// Addr is the server's host:port, e.g. "smtp.example.com:587".
+	Addr string
+	// From is the sender address, e.g. "Acme <no-reply@acme.com>".
+	From string
+	// Username and Password enable PLAIN authentication when Username is set.
+	Username string
+	Password string
+}
+
+// Send delivers msg to the SMTP server.
+func (m SMTPMailer) Send(ctx context.Context, msg Message) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid address %q: %w", m.Addr, err)
+	}
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("smtp: header values must not contain line breaks")
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, envelopeAddress(m.From), []string{msg.To}, m.message(msg))
+}
+
+// message renders msg as an RFC 5322 message, multipart/alternative when it
+// has an HTML body.
+func (m SMTPMailer) message(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		b.WriteString(msg.Text)
+		return []byte(b.String())
+	}
+
+	boundary := randomBoundary()
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", boundary, msg.Text)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n%s\r\n", boundary, msg.HTML)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return []byte(b.String())
+}
+
+// envelopeAddress returns the bare address of a "Name <addr>" sender.
+func envelopeAddress(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
+
+func randomBoundary() string {
+	b := make([]byte, 12)
+	rand.Read(b) //nolint:errcheck
+	return hex.EncodeToString(b)
+}
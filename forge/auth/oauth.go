@@ -2,10 +2,12 @@ package auth
 
 import (
 	"context"
-	"fmt"
-	"html"
+	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi/v5"
@@ -106,55 +108,224 @@ func HandleOAuthCallback(sm *scs.SessionManager, findOrCreateUser UserFinder) ht
 	}
 }
 
-// RegisterOAuthRoutes mounts the OAuth2 and email/password auth routes onto
-// the given router. The routes are intentionally registered outside any
-// RequireSession middleware group — placing auth routes inside RequireSession
-// would cause an infinite redirect loop for unauthenticated users.
+// OAuthRoutesConfig holds the optional handlers and settings for
+// RegisterOAuthRoutes. Every field may be left zero.
+type OAuthRoutesConfig struct {
+	// FindOrCreateUser maps a user signing in with an OAuth2 or OIDC provider
+	// to an application user ID. Nil omits the provider routes.
+	FindOrCreateUser UserFinder
+
+	// AuthenticateUser checks an email/password sign-in. Nil falls back to
+	// Accounts.Authenticate, and without Accounts omits password login.
+	AuthenticateUser PasswordAuthenticator
+
+	// OIDC serves the OpenID Connect providers. Nil when none are configured.
+	OIDC *OIDC
+
+	// Accounts serves signup, password reset, email verification and password
+	// change. Nil omits the built-in account pages.
+	Accounts *Accounts
+
+	// Limiter throttles password sign-in. Nil leaves it unthrottled.
+	Limiter *LoginLimiter
+
+	// Sessions serves the pages listing and revoking the user's signed-in
+	// sessions. Nil omits them.
+	Sessions *Sessions
+
+	// TwoFactor requires a second factor after the password. Nil signs in
+	// with the password alone.
+	TwoFactor *TwoFactor
+
+	// Impersonation lets admins act as another user. Nil disallows it.
+	Impersonation *Impersonation
+
+	// Pages renders the auth pages. Nil uses DefaultPages.
+	Pages Pages
+}
+
+// RegisterOAuthRoutes mounts the OAuth2, email/password and account auth
+// routes onto the given router. The routes are intentionally registered
+// outside any RequireSession middleware group — placing auth routes inside
+// RequireSession would cause an infinite redirect loop for unauthenticated
+// users.
 //
 // Routes:
 //   - GET  /auth/login          -> HandleLogin (renders login page)
 //   - POST /auth/login          -> HandleLoginSubmit (processes email/password)
-//   - GET  /auth/logout         -> HandleLogout
-//   - GET  /auth/{provider}     -> gothic.BeginAuthHandler (starts OAuth flow)
-//   - GET  /auth/{provider}/callback -> HandleOAuthCallback
-func RegisterOAuthRoutes(
-	router chi.Router,
-	sm *scs.SessionManager,
-	findOrCreateUser UserFinder,
-	authenticateUser PasswordAuthenticator,
-) {
+//   - POST /auth/logout         -> HandleLogout
+//   - GET  /auth/{provider}     -> OIDC.HandleBegin for OIDC providers,
+//     gothic.BeginAuthHandler otherwise (starts OAuth flow)
+//   - GET  /auth/{provider}/callback -> OIDC.HandleCallback or HandleOAuthCallback
+//   - GET/POST /auth/signup, /auth/forgot-password, /auth/reset-password,
+//     /auth/verify-email and /auth/change-password -> the Accounts handlers
+//   - GET  /auth/sessions, POST /auth/sessions/revoke and
+//     /auth/sessions/revoke-all -> the Sessions handlers
+//   - GET/POST /auth/2fa and /auth/2fa/setup, POST /auth/2fa/disable -> the
+//     TwoFactor handlers
+//   - POST /auth/impersonate and /auth/impersonate/stop -> the Impersonation
+//     handlers
+//
+// Routes whose handler is not set in cfg are omitted. The password, session
+// and 2FA settings pages refuse impersonated sessions (DenyImpersonation).
+//
+// Example:
+//
+//	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{
+//	    AuthenticateUser: checkPassword,
+//	    Limiter:          limiter,
+//	})
+func RegisterOAuthRoutes(router chi.Router, sm *scs.SessionManager, cfg OAuthRoutesConfig) {
+	authenticateUser, accounts, pages := cfg.AuthenticateUser, cfg.Accounts, cfg.Pages
+	if pages == nil {
+		pages = DefaultPages{}
+	}
+	if authenticateUser == nil && accounts != nil {
+		authenticateUser = accounts.Authenticate
+	}
+	login := PageData{
+		Password:      authenticateUser != nil,
+		Signup:        accounts != nil && !accounts.cfg.DisableSignup,
+		PasswordReset: accounts != nil,
+	}
+	if cfg.FindOrCreateUser != nil {
+		login.Providers = append(gothLoginProviders(), cfg.OIDC.LoginProviders()...)
+	}
+
 	router.Group(func(r chi.Router) {
-		// Always register login page and logout.
-		r.Get("/auth/login", HandleLogin(sm))
-		r.Get("/auth/logout", HandleLogout(sm))
+		// Always register login page and logout. Logout is a POST so a
+		// cross-site link or image cannot sign the user out.
+		r.Get("/auth/login", HandleLogin(sm, pages, login))
+		r.Post("/auth/logout", HandleLogout(sm))
 
-		// Only register password login if authenticateUser is provided.
+		// Only register password login if a PasswordAuthenticator is available.
 		if authenticateUser != nil {
-			r.Post("/auth/login", HandleLoginSubmit(sm, authenticateUser))
+			r.Post("/auth/login", HandleLoginSubmit(sm, authenticateUser, LoginConfig{
+				Limiter:   cfg.Limiter,
+				TwoFactor: cfg.TwoFactor,
+				Pages:     pages,
+				Data:      login,
+			}))
+		}
+
+		// Only register the account lifecycle if Accounts is provided.
+		if accounts != nil {
+			if !accounts.cfg.DisableSignup {
+				r.Get("/auth/signup", accounts.HandleSignup(sm, pages))
+				r.Post("/auth/signup", accounts.HandleSignup(sm, pages))
+			}
+			r.Get("/auth/forgot-password", accounts.HandleForgotPassword(sm, pages))
+			r.Post("/auth/forgot-password", accounts.HandleForgotPassword(sm, pages))
+			r.Get("/auth/reset-password", accounts.HandleResetPassword(sm, pages))
+			r.Post("/auth/reset-password", accounts.HandleResetPassword(sm, pages))
+			r.Get("/auth/verify-email", accounts.HandleVerifyEmail(sm, pages))
+			r.Post("/auth/verify-email", accounts.HandleVerifyEmail(sm, pages))
+			r.With(DenyImpersonation(sm)).Get("/auth/change-password", accounts.HandleChangePassword(sm, pages))
+			r.With(DenyImpersonation(sm)).Post("/auth/change-password", accounts.HandleChangePassword(sm, pages))
+		}
+
+		// Only register the sessions pages if a session index is provided.
+		if cfg.Sessions != nil {
+			r := r.With(DenyImpersonation(sm))
+			r.Get("/auth/sessions", cfg.Sessions.HandleList(pages))
+			r.Post("/auth/sessions/revoke", cfg.Sessions.HandleRevoke(pages))
+			r.Post("/auth/sessions/revoke-all", cfg.Sessions.HandleRevokeAll())
+		}
+
+		// Only register two-factor authentication if TwoFactor is provided.
+		if cfg.TwoFactor != nil {
+			r.Get("/auth/2fa", cfg.TwoFactor.HandleChallenge(pages))
+			r.Post("/auth/2fa", cfg.TwoFactor.HandleChallenge(pages))
+			r := r.With(DenyImpersonation(sm))
+			r.Get("/auth/2fa/setup", cfg.TwoFactor.HandleSetup(pages))
+			r.Post("/auth/2fa/setup", cfg.TwoFactor.HandleSetup(pages))
+			r.Post("/auth/2fa/disable", cfg.TwoFactor.HandleDisable(pages))
+		}
+
+		// Only register impersonation if Impersonation is provided.
+		if cfg.Impersonation != nil {
+			r.Post("/auth/impersonate", cfg.Impersonation.HandleStart())
+			r.Post("/auth/impersonate/stop", cfg.Impersonation.HandleStop())
 		}
 
-		// Only register OAuth routes if findOrCreateUser is provided.
-		if findOrCreateUser != nil {
-			r.Get("/auth/{provider}", gothic.BeginAuthHandler)
-			r.Get("/auth/{provider}/callback", HandleOAuthCallback(sm, findOrCreateUser))
+		// Only register OAuth routes if FindOrCreateUser is provided.
+		if cfg.FindOrCreateUser != nil {
+			gothCallback := HandleOAuthCallback(sm, cfg.FindOrCreateUser)
+			r.Get("/auth/{provider}", func(w http.ResponseWriter, r *http.Request) {
+				if cfg.OIDC.Has(chi.URLParam(r, "provider")) {
+					cfg.OIDC.HandleBegin(sm)(w, r)
+					return
+				}
+				gothic.BeginAuthHandler(w, r)
+			})
+			r.Get("/auth/{provider}/callback", func(w http.ResponseWriter, r *http.Request) {
+				if cfg.OIDC.Has(chi.URLParam(r, "provider")) {
+					cfg.OIDC.HandleCallback(sm, cfg.FindOrCreateUser)(w, r)
+					return
+				}
+				gothCallback(w, r)
+			})
 		}
 	})
 }
 
-// HandleLogin returns an http.HandlerFunc that renders the login page.
-// The page includes an email/password form and OAuth provider buttons for
-// Google and GitHub.
-func HandleLogin(sm *scs.SessionManager) http.HandlerFunc {
+// gothLoginProviders lists the providers registered with goth, sorted by name.
+func gothLoginProviders() []LoginProvider {
+	labels := map[string]string{"google": "Google", "github": "GitHub"}
+	var providers []LoginProvider
+	for name := range goth.GetProviders() {
+		label := labels[name]
+		if label == "" {
+			label = name
+		}
+		providers = append(providers, LoginProvider{Name: name, Label: label})
+	}
+	slices.SortFunc(providers, func(a, b LoginProvider) int { return strings.Compare(a.Name, b.Name) })
+	return providers
+}
+
+// HandleLogin returns an http.HandlerFunc that renders the login page with
+// pages.Login. data lists the providers and which forms and links to show; a
+// notice left by a previous step, such as a password reset, is added to it.
+func HandleLogin(sm *scs.SessionManager, pages Pages, data PageData) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprint(w, loginPageHTML(""))
+		data := data
+		data.Notice = sm.PopString(r.Context(), sessionKeyFlash)
+		renderPage(w, r, http.StatusOK, pages.Login(data))
 	}
 }
 
+// LoginConfig holds the optional settings for HandleLoginSubmit.
+type LoginConfig struct {
+	// Limiter throttles sign-in. Nil disables throttling. Otherwise attempts
+	// it refuses get a 429 with Retry-After without the password being
+	// checked, and failed attempts are counted against the account and the
+	// client IP. Attempts get a 503 while its store cannot be read.
+	Limiter *LoginLimiter
+
+	// TwoFactor requires a second factor. Nil signs in with the password
+	// alone. Otherwise users enrolled in 2FA, or whose role requires it, are
+	// left in a pending sign-in and redirected to /auth/2fa or
+	// /auth/2fa/setup.
+	TwoFactor *TwoFactor
+
+	// Pages renders the login page. Nil uses DefaultPages.
+	Pages Pages
+
+	// Data lists the providers and which forms and links the re-rendered
+	// login page shows.
+	Data PageData
+}
+
 // HandleLoginSubmit returns an http.HandlerFunc that processes an
 // email/password form POST. On success it stores the session and redirects to
-// "/". On failure it re-renders the login page with an error message.
-func HandleLoginSubmit(sm *scs.SessionManager, authenticateUser PasswordAuthenticator) http.HandlerFunc {
+// "/". On failure it re-renders the login page from cfg.Data with an error
+// message.
+func HandleLoginSubmit(sm *scs.SessionManager, authenticateUser PasswordAuthenticator, cfg LoginConfig) http.HandlerFunc {
+	limiter, twoFactor, pages := cfg.Limiter, cfg.TwoFactor, cfg.Pages
+	if pages == nil {
+		pages = DefaultPages{}
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
@@ -164,13 +335,68 @@ func HandleLoginSubmit(sm *scs.SessionManager, authenticateUser PasswordAuthenti
 		email := r.FormValue("email")
 		password := r.FormValue("password")
 
+		data := cfg.Data
+		data.Email = email
+
+		if limiter != nil {
+			var throttle *ThrottleError
+			if err := limiter.Allow(r, email); errors.As(err, &throttle) {
+				data.Error = "Too many failed sign-in attempts. Try again in " + formatTTL(throttle.RetryAfter) + "."
+				if throttle.Locked && data.PasswordReset {
+					data.Error = "Too many failed sign-in attempts. Try again in " + formatTTL(throttle.RetryAfter) + ", or reset your password to unlock your account now."
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(int(throttle.RetryAfter.Seconds())))
+				renderPage(w, r, http.StatusTooManyRequests, pages.Login(data))
+				return
+			} else if err != nil {
+				// Fail closed: without the counters a lockout cannot be
+				// enforced, so the password is not checked at all.
+				log.Printf("login limiter error: %v", err)
+				http.Error(w, "Sign-in is temporarily unavailable. Please try again.", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		// ErrEmailNotVerified gets the same response and counts as a failure
+		// too, so the sign-in form cannot confirm a guessed password for an
+		// unverified account.
 		userID, err := authenticateUser(r.Context(), email, password)
 		if err != nil {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			fmt.Fprint(w, loginPageHTML("Invalid email or password."))
+			data.Error = "Invalid email or password."
+			if limiter != nil {
+				if err := limiter.Fail(r, email); err != nil {
+					log.Printf("login limiter error: %v", err)
+				}
+			}
+			renderPage(w, r, http.StatusOK, pages.Login(data))
 			return
 		}
 
+		// A user with a second factor is only signed in once the code is
+		// verified; the failure counters stay until then so the code cannot
+		// be guessed between password sign-ins.
+		if twoFactor != nil {
+			next, err := twoFactor.challenge(r.Context(), userID)
+			if err == nil && next != "" {
+				err = twoFactor.startPending(r, userID, email)
+			}
+			if err != nil {
+				log.Printf("two-factor error: %v", err)
+				http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+				return
+			}
+			if next != "" {
+				http.Redirect(w, r, next, http.StatusFound)
+				return
+			}
+		}
+
+		if limiter != nil {
+			if err := limiter.Succeed(r.Context(), email); err != nil {
+				log.Printf("login limiter error: %v", err)
+			}
+		}
+
 		if err := LoginUser(sm, r, userID, email); err != nil {
 			log.Printf("login session error: %v", err)
 			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
@@ -181,8 +407,9 @@ func HandleLoginSubmit(sm *scs.SessionManager, authenticateUser PasswordAuthenti
 	}
 }
 
-// HandleLogout returns an http.HandlerFunc that destroys the session and
-// redirects the user to the login page.
+// HandleLogout returns an http.HandlerFunc for POST /auth/logout that destroys
+// the session and redirects the user to the login page. Submit it from a form
+// carrying the csrf_token field.
 func HandleLogout(sm *scs.SessionManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := LogoutUser(sm, r); err != nil {
@@ -190,31 +417,6 @@ func HandleLogout(sm *scs.SessionManager) http.HandlerFunc {
 			http.Error(w, "Logout failed. Please try again.", http.StatusInternalServerError)
 			return
 		}
-		http.Redirect(w, r, "/auth/login", http.StatusFound)
-	}
-}
-
-// loginPageHTML returns a minimal HTML login page. The errMsg is displayed
-// when non-empty (e.g. "Invalid email or password.").
-func loginPageHTML(errMsg string) string {
-	errSection := ""
-	if errMsg != "" {
-		errSection = fmt.Sprintf(`<p style="color:red">%s</p>`, html.EscapeString(errMsg))
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
 	}
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
-<head><meta charset="UTF-8"><title>Login</title></head>
-<body>
-<h1>Sign in</h1>
-%s
-<form method="POST" action="/auth/login">
-  <label>Email <input type="email" name="email" required></label><br>
-  <label>Password <input type="password" name="password" required></label><br>
-  <button type="submit">Sign in</button>
-</form>
-<hr>
-<a href="/auth/google">Sign in with Google</a><br>
-<a href="/auth/github">Sign in with GitHub</a>
-</body>
-</html>`, errSection)
 }
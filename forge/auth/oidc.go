@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// Session keys holding the in-flight OIDC handshake between the redirect to
+// the provider and its callback.
+const (
+	sessionKeyOIDCProvider = "oidc_provider"
+	sessionKeyOIDCState    = "oidc_state"
+	sessionKeyOIDCNonce    = "oidc_nonce"
+	sessionKeyOIDCVerifier = "oidc_verifier"
+)
+
+// reservedProviderNames are /auth/* routes that cannot be used as provider names.
+var reservedProviderNames = []string{
+	"login", "logout", "signup", "forgot-password", "reset-password", "verify-email", "change-password",
+	"sessions", "2fa", "impersonate",
+}
+
+// OIDCProviderConfig configures one OpenID Connect identity provider such as
+// Okta, Keycloak or Azure AD.
+type OIDCProviderConfig struct {
+	// Name identifies the provider in its routes, /auth/{Name} and
+	// /auth/{Name}/callback, and in goth.User.Provider.
+	Name string
+	// DisplayName labels the provider on the login page. Defaults to Name.
+	DisplayName string
+	// Issuer is the provider's issuer URL. Endpoints and signing keys are
+	// discovered from its /.well-known/openid-configuration on first use.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested in addition to "openid". Defaults to "email" and
+	// "profile".
+	Scopes []string
+	// RolesClaim names the ID token claim holding the user's groups or roles.
+	// Empty maps no roles.
+	RolesClaim string
+	// RoleMap translates RolesClaim values to app roles. Values without an
+	// entry are dropped. When empty, the claim values are used as roles.
+	RoleMap map[string]string
+}
+
+// LoginProvider is a sign-in option listed on the login page.
+type LoginProvider struct {
+	// Name is the provider's route segment: the button links to /auth/{Name}.
+	Name  string
+	Label string
+}
+
+// OIDC signs users in with OpenID Connect providers using the authorization
+// code flow with PKCE. The state, nonce and PKCE verifier of each handshake
+// are kept in the SCS session and checked on the callback; the ID token's
+// signature, issuer, audience and expiry are verified against the provider's
+// discovered keys.
+type OIDC struct {
+	baseURL   string
+	providers map[string]*oidcProvider
+	names     []string
+}
+
+// oidcProvider is a configured provider whose discovery document is loaded on
+// first use, so the app starts even while the provider is unreachable.
+type oidcProvider struct {
+	cfg OIDCProviderConfig
+
+	mu       sync.Mutex
+	endpoint oauth2.Endpoint
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDC validates the provider configs and returns an OIDC for them.
+// baseURL is the app's public URL used for redirect URIs; when empty they are
+// derived from each request's host.
+func NewOIDC(baseURL string, providers []OIDCProviderConfig) (*OIDC, error) {
+	o := &OIDC{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		providers: make(map[string]*oidcProvider, len(providers)),
+	}
+	for _, cfg := range providers {
+		switch {
+		case cfg.Name == "":
+			return nil, errors.New("oidc: provider name is required")
+		case slices.Contains(reservedProviderNames, cfg.Name):
+			return nil, fmt.Errorf("oidc: provider name %q is reserved", cfg.Name)
+		case o.providers[cfg.Name] != nil:
+			return nil, fmt.Errorf("oidc: duplicate provider %q", cfg.Name)
+		case cfg.Issuer == "":
+			return nil, fmt.Errorf("oidc: provider %q: issuer is required", cfg.Name)
+		case cfg.ClientID == "":
+			return nil, fmt.Errorf("oidc: provider %q: client_id is required", cfg.Name)
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"email", "profile"}
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = cfg.Name
+		}
+		o.providers[cfg.Name] = &oidcProvider{cfg: cfg}
+		o.names = append(o.names, cfg.Name)
+	}
+	return o, nil
+}
+
+// Has reports whether name is a configured OIDC provider. It is safe to call
+// on a nil OIDC.
+func (o *OIDC) Has(name string) bool {
+	return o != nil && o.providers[name] != nil
+}
+
+// LoginProviders returns the configured providers in config order for the
+// login page. It is safe to call on a nil OIDC.
+func (o *OIDC) LoginProviders() []LoginProvider {
+	if o == nil {
+		return nil
+	}
+	out := make([]LoginProvider, 0, len(o.names))
+	for _, name := range o.names {
+		out = append(out, LoginProvider{Name: name, Label: o.providers[name].cfg.DisplayName})
+	}
+	return out
+}
+
+// HandleBegin returns an http.HandlerFunc for GET /auth/{provider} that
+// stores a fresh state, nonce and PKCE verifier in the session and redirects
+// the browser to the provider's authorization endpoint.
+func (o *OIDC) HandleBegin(sm *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := o.providers[chi.URLParam(r, "provider")]
+		if p == nil {
+			http.NotFound(w, r)
+			return
+		}
+		endpoint, _, err := p.load(r.Context())
+		if err != nil {
+			log.Printf("oidc discovery error: %v", err)
+			http.Error(w, "Sign-in provider is unavailable. Please try again later.", http.StatusBadGateway)
+			return
+		}
+
+		state, err := GenerateToken()
+		if err != nil {
+			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		nonce, err := GenerateToken()
+		if err != nil {
+			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		verifier := oauth2.GenerateVerifier()
+
+		sm.Put(r.Context(), sessionKeyOIDCProvider, p.cfg.Name)
+		sm.Put(r.Context(), sessionKeyOIDCState, state)
+		sm.Put(r.Context(), sessionKeyOIDCNonce, nonce)
+		sm.Put(r.Context(), sessionKeyOIDCVerifier, verifier)
+
+		conf := o.oauth2Config(r, p, endpoint)
+		http.Redirect(w, r, conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)), http.StatusFound)
+	}
+}
+
+// HandleCallback returns an http.HandlerFunc for GET /auth/{provider}/callback
+// that completes the flow. It:
+//  1. Checks the returned state against the one stored by HandleBegin
+//  2. Exchanges the code, proving possession of the PKCE verifier
+//  3. Verifies the ID token and its nonce
+//  4. Calls findOrCreateUser with the claims as a goth.User (RawData holds all
+//     ID token claims)
+//  5. Stores the user and the mapped roles in the session and redirects to "/"
+func (o *OIDC) HandleCallback(sm *scs.SessionManager, findOrCreateUser UserFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		p := o.providers[chi.URLParam(r, "provider")]
+		if p == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Pop the handshake so a callback URL cannot be replayed.
+		provider := sm.PopString(ctx, sessionKeyOIDCProvider)
+		state := sm.PopString(ctx, sessionKeyOIDCState)
+		nonce := sm.PopString(ctx, sessionKeyOIDCNonce)
+		verifier := sm.PopString(ctx, sessionKeyOIDCVerifier)
+
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			log.Printf("oidc provider %s returned error: %s: %s", p.cfg.Name, e, q.Get("error_description"))
+			http.Error(w, "Authentication failed. Please try again.", http.StatusUnauthorized)
+			return
+		}
+		if provider != p.cfg.Name || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 {
+			http.Error(w, "Sign-in session expired or is invalid. Please try again.", http.StatusBadRequest)
+			return
+		}
+
+		endpoint, idVerifier, err := p.load(ctx)
+		if err != nil {
+			log.Printf("oidc discovery error: %v", err)
+			http.Error(w, "Sign-in provider is unavailable. Please try again later.", http.StatusBadGateway)
+			return
+		}
+		token, err := o.oauth2Config(r, p, endpoint).Exchange(ctx, q.Get("code"), oauth2.VerifierOption(verifier))
+		if err != nil {
+			log.Printf("oidc code exchange error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusUnauthorized)
+			return
+		}
+		rawIDToken, _ := token.Extra("id_token").(string)
+		if rawIDToken == "" {
+			log.Printf("oidc provider %s returned no id_token", p.cfg.Name)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusUnauthorized)
+			return
+		}
+		idToken, err := idVerifier.Verify(ctx, rawIDToken)
+		if err != nil {
+			log.Printf("oidc id_token verification error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusUnauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+			log.Printf("oidc provider %s returned an id_token with a mismatched nonce", p.cfg.Name)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusUnauthorized)
+			return
+		}
+
+		var claims map[string]any
+		if err := idToken.Claims(&claims); err != nil {
+			log.Printf("oidc claims error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusUnauthorized)
+			return
+		}
+		user := oidcUser(p.cfg.Name, idToken, claims, token, rawIDToken)
+
+		userID, err := findOrCreateUser(ctx, user)
+		if err != nil {
+			log.Printf("find or create user error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		if err := LoginUser(sm, r, userID, user.Email); err != nil {
+			log.Printf("login session error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		if roles := p.roles(claims); len(roles) > 0 {
+			sm.Put(ctx, SessionKeyUserRoles, roles)
+		}
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// oauth2Config returns the OAuth2 client for p with the redirect URI for r.
+func (o *OIDC) oauth2Config(r *http.Request, p *oidcProvider, endpoint oauth2.Endpoint) *oauth2.Config {
+	base := o.baseURL
+	if base == "" {
+		base = requestBaseURL(r)
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  base + "/auth/" + p.cfg.Name + "/callback",
+		Scopes:       append([]string{oidc.ScopeOpenID}, p.cfg.Scopes...),
+	}
+}
+
+// requestBaseURL returns the scheme and host r was sent to, honoring
+// X-Forwarded-Proto from a TLS-terminating proxy.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// load discovers the provider's endpoints and keys, once. A failed discovery
+// is retried on the next request.
+func (p *oidcProvider) load(ctx context.Context) (oauth2.Endpoint, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.verifier != nil {
+		return p.endpoint, p.verifier, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return oauth2.Endpoint{}, nil, fmt.Errorf("oidc provider %s: %w", p.cfg.Name, err)
+	}
+	p.endpoint = provider.Endpoint()
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return p.endpoint, p.verifier, nil
+}
+
+// roles maps the provider's roles claim to app roles.
+func (p *oidcProvider) roles(claims map[string]any) []string {
+	if p.cfg.RolesClaim == "" {
+		return nil
+	}
+	var values []string
+	switch v := claims[p.cfg.RolesClaim].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var roles []string
+	for _, value := range values {
+		role := value
+		if len(p.cfg.RoleMap) > 0 {
+			role = p.cfg.RoleMap[value]
+		}
+		if role != "" && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// oidcUser converts verified ID token claims to the goth.User passed to
+// UserFinder, so one finder serves both goth and OIDC providers.
+func oidcUser(provider string, idToken *oidc.IDToken, claims map[string]any, token *oauth2.Token, rawIDToken string) goth.User {
+	str := func(key string) string {
+		s, _ := claims[key].(string)
+		return s
+	}
+	return goth.User{
+		RawData:      claims,
+		Provider:     provider,
+		UserID:       idToken.Subject,
+		Email:        str("email"),
+		Name:         str("name"),
+		FirstName:    str("given_name"),
+		LastName:     str("family_name"),
+		NickName:     str("preferred_username"),
+		AvatarURL:    str("picture"),
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.Expiry,
+		IDToken:      rawIDToken,
+	}
+}
@@ -0,0 +1,270 @@
+package auth_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/markbates/goth"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/forgetest"
+)
+
+// oidcApp is an app with one OIDC provider, "corp", backed by a MockIdP.
+type oidcApp struct {
+	idp    *forgetest.MockIdP
+	server *httptest.Server
+	client *http.Client
+	user   goth.User
+	userID uuid.UUID
+}
+
+func newOIDCApp(t *testing.T) *oidcApp {
+	t.Helper()
+
+	a := &oidcApp{idp: forgetest.NewMockIdP(t), userID: uuid.New()}
+	o, err := auth.NewOIDC("", []auth.OIDCProviderConfig{{
+		Name:         "corp",
+		Issuer:       a.idp.Issuer(),
+		ClientID:     forgetest.MockIdPClientID,
+		ClientSecret: forgetest.MockIdPClientSecret,
+		RolesClaim:   "groups",
+		RoleMap:      map[string]string{"Admins": "admin", "Staff": "member"},
+	}})
+	if err != nil {
+		t.Fatalf("NewOIDC: %v", err)
+	}
+
+	sm := scs.New()
+	r := chi.NewRouter()
+	r.Use(sm.LoadAndSave)
+	finder := func(ctx context.Context, u goth.User) (string, error) {
+		a.user = u
+		return a.userID.String(), nil
+	}
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{FindOrCreateUser: finder, OIDC: o})
+	r.With(auth.SessionUser(sm, nil, nil)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, auth.UserFromContext(r.Context()).String()+" "+strings.Join(auth.RolesFromContext(r.Context()), ","))
+	})
+	a.server = httptest.NewServer(r)
+	t.Cleanup(a.server.Close)
+
+	jar, _ := cookiejar.New(nil)
+	a.client = &http.Client{Jar: jar}
+	return a
+}
+
+// get fetches path on the app, following redirects through the IdP.
+func (a *oidcApp) get(t *testing.T, path string) (int, string) {
+	t.Helper()
+	resp, err := a.client.Get(a.server.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestOIDC_SignIn(t *testing.T) {
+	a := newOIDCApp(t)
+	a.idp.SignInAs(map[string]any{
+		"sub":    "idp-42",
+		"email":  "ada@example.com",
+		"name":   "Ada Lovelace",
+		"groups": []string{"Admins", "Staff", "Unmapped"},
+	})
+
+	status, body := a.get(t, "/auth/corp")
+	if status != http.StatusOK {
+		t.Fatalf("sign-in status = %d, body %q", status, body)
+	}
+	if want := a.userID.String() + " admin,member"; body != want {
+		t.Errorf("session = %q, want %q", body, want)
+	}
+	if a.user.Provider != "corp" || a.user.UserID != "idp-42" || a.user.Email != "ada@example.com" || a.user.Name != "Ada Lovelace" {
+		t.Errorf("finder got %+v", a.user)
+	}
+	if a.user.IDToken == "" || a.user.RawData["groups"] == nil {
+		t.Errorf("finder got no ID token or raw claims: %+v", a.user)
+	}
+}
+
+func TestOIDC_SignInDropsPreviousRoles(t *testing.T) {
+	a := newOIDCApp(t)
+	a.idp.SignInAs(map[string]any{"sub": "idp-42", "email": "ada@example.com", "groups": []string{"Admins"}})
+	if _, body := a.get(t, "/auth/corp"); body != a.userID.String()+" admin" {
+		t.Fatalf("first sign-in session = %q", body)
+	}
+
+	// A later sign-in without groups must not keep the admin role.
+	a.idp.SignInAs(map[string]any{"sub": "idp-43", "email": "bob@example.com"})
+	if _, body := a.get(t, "/auth/corp"); body != a.userID.String()+" " {
+		t.Errorf("second sign-in session = %q, want no roles", body)
+	}
+}
+
+func TestOIDC_RejectsMismatchedNonce(t *testing.T) {
+	a := newOIDCApp(t)
+	a.idp.SignInAs(map[string]any{"sub": "idp-42", "nonce": "forged"})
+
+	status, _ := a.get(t, "/auth/corp")
+	if status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, body := a.get(t, "/"); body != uuid.Nil.String()+" " {
+		t.Errorf("user signed in despite bad nonce: %d %q", status, body)
+	}
+}
+
+func TestOIDC_RejectsWrongAudience(t *testing.T) {
+	a := newOIDCApp(t)
+	a.idp.SignInAs(map[string]any{"sub": "idp-42", "aud": "another-client"})
+
+	if status, _ := a.get(t, "/auth/corp"); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDC_RejectsUnknownState(t *testing.T) {
+	a := newOIDCApp(t)
+
+	// A callback without a handshake in the session, as in a forged request.
+	if status, _ := a.get(t, "/auth/corp/callback?code=abc&state=xyz"); status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestOIDC_CallbackCannotBeReplayed(t *testing.T) {
+	a := newOIDCApp(t)
+
+	// Stop at the callback so its URL can be sent twice.
+	var callback string
+	a.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if strings.HasSuffix(req.URL.Path, "/callback") {
+			callback = req.URL.String()
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	a.get(t, "/auth/corp")
+	a.client.CheckRedirect = nil
+	if callback == "" {
+		t.Fatal("IdP did not redirect to the callback")
+	}
+	u := strings.TrimPrefix(callback, a.server.URL)
+
+	if status, body := a.get(t, u); status != http.StatusOK {
+		t.Fatalf("first callback status = %d, body %q", status, body)
+	}
+	if status, _ := a.get(t, u); status != http.StatusBadRequest {
+		t.Fatalf("replayed callback status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestOIDC_ProviderUnavailable(t *testing.T) {
+	o, err := auth.NewOIDC("", []auth.OIDCProviderConfig{{Name: "down", Issuer: "http://127.0.0.1:1", ClientID: "x"}})
+	if err != nil {
+		t.Fatalf("NewOIDC: %v", err)
+	}
+	r := chi.NewRouter()
+	sm := scs.New()
+	r.Use(sm.LoadAndSave)
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{
+		FindOrCreateUser: func(context.Context, goth.User) (string, error) { return "", nil },
+		OIDC:             o,
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/down", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+}
+
+func TestOIDC_LoginPageListsProviders(t *testing.T) {
+	o, err := auth.NewOIDC("", []auth.OIDCProviderConfig{{Name: "corp", DisplayName: "Acme SSO", Issuer: "https://idp.example.com", ClientID: "x"}})
+	if err != nil {
+		t.Fatalf("NewOIDC: %v", err)
+	}
+	r := chi.NewRouter()
+	sm := scs.New()
+	r.Use(sm.LoadAndSave)
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{
+		FindOrCreateUser: func(context.Context, goth.User) (string, error) { return "", nil },
+		OIDC:             o,
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `href="/auth/corp"`) || !strings.Contains(body, "Acme SSO") {
+		t.Errorf("login page does not link the provider:\n%s", body)
+	}
+}
+
+func TestNewOIDC_Validation(t *testing.T) {
+	valid := auth.OIDCProviderConfig{Name: "corp", Issuer: "https://idp.example.com", ClientID: "x"}
+	tests := []struct {
+		name      string
+		providers []auth.OIDCProviderConfig
+		wantErr   string
+	}{
+		{"missing name", []auth.OIDCProviderConfig{{Issuer: "https://idp.example.com", ClientID: "x"}}, "name is required"},
+		{"reserved name", []auth.OIDCProviderConfig{{Name: "login", Issuer: "https://idp.example.com", ClientID: "x"}}, "reserved"},
+		{"duplicate", []auth.OIDCProviderConfig{valid, valid}, "duplicate"},
+		{"missing issuer", []auth.OIDCProviderConfig{{Name: "corp", ClientID: "x"}}, "issuer is required"},
+		{"missing client id", []auth.OIDCProviderConfig{{Name: "corp", Issuer: "https://idp.example.com"}}, "client_id is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.NewOIDC("", tt.providers)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestNewOIDC_ReservesAuthRoutes checks that no provider can be named after a
+// static route RegisterOAuthRoutes mounts under /auth/, which would shadow
+// /auth/{provider}.
+func TestNewOIDC_ReservesAuthRoutes(t *testing.T) {
+	r := chi.NewRouter()
+	sm := scs.New()
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{
+		FindOrCreateUser: func(context.Context, goth.User) (string, error) { return "", nil },
+		Accounts:         &auth.Accounts{},
+		Sessions:         &auth.Sessions{},
+		TwoFactor:        &auth.TwoFactor{},
+		Impersonation:    &auth.Impersonation{},
+	})
+
+	names := map[string]bool{}
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/auth/"), "/")
+		if strings.HasPrefix(route, "/auth/") && !strings.HasPrefix(segment, "{") {
+			names[segment] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no static /auth/ routes found")
+	}
+	for name := range names {
+		_, err := auth.NewOIDC("", []auth.OIDCProviderConfig{{Name: name, Issuer: "https://idp.example.com", ClientID: "x"}})
+		if err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Errorf("provider name %q: err = %v, want it reserved", name, err)
+		}
+	}
+}
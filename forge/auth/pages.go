@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Component is a renderable page. templ.Component satisfies it, so Pages can
+// be implemented with templ components such as the generated
+// gen/html/authpages.Pages.
+type Component interface {
+	Render(ctx context.Context, w io.Writer) error
+}
+
+// PageData is passed to every account page.
+type PageData struct {
+	// Error describes why a submitted form was rejected.
+	Error string
+	// Notice confirms a completed step, e.g. "Check your email".
+	Notice string
+	// Email prefills the email field after a rejected submission.
+	Email string
+	// Token is the reset token the reset-password form posts back.
+	Token string
+
+	// Providers are the OAuth and OIDC sign-in options for the login page.
+	Providers []LoginProvider
+	// Password reports whether the login page shows the email/password form.
+	Password bool
+	// Signup and PasswordReset report whether the login page links to
+	// /auth/signup and /auth/forgot-password.
+	Signup        bool
+	PasswordReset bool
+
+	// Sessions are the signed-in user's sessions for the sessions page.
+	Sessions []ActiveSession
+
+	// TOTPSecret and TOTPURI enrol an authenticator app on the 2FA setup
+	// page. Render TOTPURI as a QR code and TOTPSecret for manual entry.
+	TOTPSecret string
+	TOTPURI    string
+	// RecoveryCodes are shown once, right after 2FA is enabled.
+	RecoveryCodes []string
+	// TwoFactorEnabled, TwoFactorRequired and RecoveryCodesLeft describe the
+	// user's 2FA on the setup page. Required 2FA cannot be turned off.
+	TwoFactorEnabled  bool
+	TwoFactorRequired bool
+	RecoveryCodesLeft int
+}
+
+// Pages renders the sign-in and account pages. Implement it, or embed
+// DefaultPages or the generated authpages.Pages and override single methods,
+// to change how they look.
+//
+// Forms post back to their own path with these fields, plus the csrf_token
+// field holding CSRFToken(ctx):
+//   - Login, POST /auth/login: email, password
+//   - Signup, POST /auth/signup: email, password, password_confirm
+//   - ForgotPassword, POST /auth/forgot-password: email
+//   - ResetPassword, POST /auth/reset-password: token, password, password_confirm
+//   - VerifyEmail, POST /auth/verify-email: email (resends the link)
+//   - ChangePassword, POST /auth/change-password: current_password, password,
+//     password_confirm
+//   - Sessions, POST /auth/sessions/revoke: id; POST /auth/sessions/revoke-all
+//   - TwoFactor, POST /auth/2fa: code (a TOTP or recovery code)
+//   - TwoFactorSetup, POST /auth/2fa/setup: code; POST /auth/2fa/disable: code
+type Pages interface {
+	Login(data PageData) Component
+	Signup(data PageData) Component
+	ForgotPassword(data PageData) Component
+	ResetPassword(data PageData) Component
+	VerifyEmail(data PageData) Component
+	ChangePassword(data PageData) Component
+	Sessions(data PageData) Component
+	TwoFactor(data PageData) Component
+	TwoFactorSetup(data PageData) Component
+}
+
+// DefaultPages renders minimal, unstyled account pages. It is used when no
+// Pages implementation is configured.
+type DefaultPages struct{}
+
+func (DefaultPages) Login(data PageData) Component          { return defaultPage("login", data) }
+func (DefaultPages) Signup(data PageData) Component         { return defaultPage("signup", data) }
+func (DefaultPages) ForgotPassword(data PageData) Component { return defaultPage("forgot", data) }
+func (DefaultPages) ResetPassword(data PageData) Component  { return defaultPage("reset", data) }
+func (DefaultPages) VerifyEmail(data PageData) Component    { return defaultPage("verify", data) }
+func (DefaultPages) ChangePassword(data PageData) Component { return defaultPage("change", data) }
+func (DefaultPages) Sessions(data PageData) Component       { return defaultPage("sessions", data) }
+func (DefaultPages) TwoFactor(data PageData) Component      { return defaultPage("2fa", data) }
+func (DefaultPages) TwoFactorSetup(data PageData) Component { return defaultPage("2fa_setup", data) }
+
+// renderPage writes page as an HTML response with the given status.
+func renderPage(w http.ResponseWriter, r *http.Request, status int, page Component) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := page.Render(r.Context(), w); err != nil {
+		log.Printf("auth page render error: %v", err)
+	}
+}
+
+// templateComponent renders one of defaultTemplates.
+type templateComponent struct {
+	name string
+	data PageData
+}
+
+func defaultPage(name string, data PageData) Component {
+	return templateComponent{name: name, data: data}
+}
+
+func (c templateComponent) Render(ctx context.Context, w io.Writer) error {
+	return defaultTemplates.ExecuteTemplate(w, c.name, struct {
+		PageData
+		CSRFToken string
+	}{c.data, CSRFToken(ctx)})
+}
+
+var defaultTemplates = template.Must(template.New("").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>{{.}}</title></head>
+<body>
+<h1>{{.}}</h1>
+{{end}}
+
+{{define "csrf"}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">{{end}}
+
+{{define "messages"}}{{if .Error}}<p style="color:red">{{.Error}}</p>
+{{end}}{{if .Notice}}<p style="color:green">{{.Notice}}</p>
+{{end}}{{end}}
+
+{{define "login"}}{{template "head" "Sign in"}}{{template "messages" .}}
+{{if .Password}}<form method="POST" action="/auth/login">
+  {{template "csrf" .}}
+  <label>Email <input type="email" name="email" value="{{.Email}}" required></label><br>
+  <label>Password <input type="password" name="password" required></label><br>
+  <button type="submit">Sign in</button>
+</form>
+{{if .PasswordReset}}<a href="/auth/forgot-password">Forgot your password?</a><br>
+{{end}}{{if .Signup}}<a href="/auth/signup">Create an account</a><br>
+{{end}}{{end}}{{if .Providers}}<hr>
+{{range .Providers}}<a href="/auth/{{.Name}}">Sign in with {{.Label}}</a><br>
+{{end}}{{end}}</body>
+</html>{{end}}
+
+{{define "signup"}}{{template "head" "Create an account"}}{{template "messages" .}}
+<form method="POST" action="/auth/signup">
+  {{template "csrf" .}}
+  <label>Email <input type="email" name="email" value="{{.Email}}" required></label><br>
+  <label>Password <input type="password" name="password" required></label><br>
+  <label>Confirm password <input type="password" name="password_confirm" required></label><br>
+  <button type="submit">Sign up</button>
+</form>
+<a href="/auth/login">Already have an account? Sign in</a>
+</body>
+</html>{{end}}
+
+{{define "forgot"}}{{template "head" "Reset your password"}}{{template "messages" .}}
+<form method="POST" action="/auth/forgot-password">
+  {{template "csrf" .}}
+  <label>Email <input type="email" name="email" value="{{.Email}}" required></label><br>
+  <button type="submit">Send reset link</button>
+</form>
+<a href="/auth/login">Back to sign in</a>
+</body>
+</html>{{end}}
+
+{{define "reset"}}{{template "head" "Choose a new password"}}{{template "messages" .}}
+<form method="POST" action="/auth/reset-password">
+  {{template "csrf" .}}
+  <input type="hidden" name="token" value="{{.Token}}">
+  <label>New password <input type="password" name="password" required></label><br>
+  <label>Confirm password <input type="password" name="password_confirm" required></label><br>
+  <button type="submit">Reset password</button>
+</form>
+</body>
+</html>{{end}}
+
+{{define "verify"}}{{template "head" "Verify your email"}}{{template "messages" .}}
+<form method="POST" action="/auth/verify-email">
+  {{template "csrf" .}}
+  <label>Email <input type="email" name="email" value="{{.Email}}" required></label><br>
+  <button type="submit">Resend verification link</button>
+</form>
+<a href="/auth/login">Back to sign in</a>
+</body>
+</html>{{end}}
+
+{{define "change"}}{{template "head" "Change your password"}}{{template "messages" .}}
+<form method="POST" action="/auth/change-password">
+  {{template "csrf" .}}
+  <label>Current password <input type="password" name="current_password" required></label><br>
+  <label>New password <input type="password" name="password" required></label><br>
+  <label>Confirm password <input type="password" name="password_confirm" required></label><br>
+  <button type="submit">Change password</button>
+</form>
+<a href="/auth/sessions">Your sessions</a>
+</body>
+</html>{{end}}
+
+{{define "sessions"}}{{template "head" "Your sessions"}}{{template "messages" .}}
+<table>
+<tr><th>Device</th><th>IP address</th><th>Signed in</th><th>Last active</th><th></th></tr>
+{{range .Sessions}}<tr>
+  <td>{{.Device}}{{if .Current}} (this browser){{end}}</td>
+  <td>{{.IP}}</td>
+  <td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td>
+  <td>{{.LastSeenAt.Format "2006-01-02 15:04"}}</td>
+  <td><form method="POST" action="/auth/sessions/revoke">
+    {{template "csrf" $}}
+    <input type="hidden" name="id" value="{{.ID}}">
+    <button type="submit">Sign out</button>
+  </form></td>
+</tr>
+{{end}}</table>
+<form method="POST" action="/auth/sessions/revoke-all">
+  {{template "csrf" .}}
+  <button type="submit">Sign out everywhere</button>
+</form>
+</body>
+</html>{{end}}
+
+{{define "2fa"}}{{template "head" "Two-factor authentication"}}{{template "messages" .}}
+<form method="POST" action="/auth/2fa">
+  {{template "csrf" .}}
+  <label>Code from your authenticator app, or a recovery code <input type="text" name="code" autocomplete="one-time-code" required autofocus></label><br>
+  <button type="submit">Verify</button>
+</form>
+<a href="/auth/login">Back to sign in</a>
+</body>
+</html>{{end}}
+
+{{define "2fa_setup"}}{{template "head" "Two-factor authentication"}}{{template "messages" .}}
+{{if .RecoveryCodes}}<ul>
+{{range .RecoveryCodes}}  <li><code>{{.}}</code></li>
+{{end}}</ul>
+<a href="/">Continue</a>
+{{else if .TwoFactorEnabled}}<p>Two-factor authentication is on. You have {{.RecoveryCodesLeft}} recovery codes left.</p>
+{{if not .TwoFactorRequired}}<form method="POST" action="/auth/2fa/disable">
+  {{template "csrf" .}}
+  <label>Code <input type="text" name="code" autocomplete="one-time-code" required></label><br>
+  <button type="submit">Turn off two-factor authentication</button>
+</form>
+{{end}}{{else}}<p>Add this account to your authenticator app with the key below, or turn the URI into a QR code to scan, then enter the code the app shows.</p>
+<p>Key: <code>{{.TOTPSecret}}</code></p>
+<p>URI: <code>{{.TOTPURI}}</code></p>
+<form method="POST" action="/auth/2fa/setup">
+  {{template "csrf" .}}
+  <label>Code <input type="text" name="code" autocomplete="one-time-code" required></label><br>
+  <button type="submit">Turn on two-factor authentication</button>
+</form>
+{{end}}</body>
+</html>{{end}}
+`))
@@ -0,0 +1,154 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// ErrAPIKeyNotFound is returned when no stored API key matches.
+var ErrAPIKeyNotFound = errors.New("invalid API key")
+
+// APIKeyStore is an auth.APIKeyStore backed by the api_keys table.
+type APIKeyStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewAPIKeyStore returns an APIKeyStore using pool.
+func NewAPIKeyStore(pool *pgxpool.Pool) *APIKeyStore {
+	return &APIKeyStore{pool: pool}
+}
+
+// Compile-time checks.
+var (
+	_ auth.APIKeyStore  = (*APIKeyStore)(nil)
+	_ auth.UsageTracker = (*APIKeyStore)(nil)
+)
+
+// apiKeyColumns lists the columns scanned by scanAPIKey, in order.
+const apiKeyColumns = `id, name, key_hash, lookup, prefix, user_id, scopes, expires_at, revoked_at, last_used_at, created_at`
+
+// scanAPIKey scans a row selected with apiKeyColumns.
+func scanAPIKey(row pgx.Row) (*auth.APIKey, error) {
+	var k auth.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.KeyHash, &k.Lookup, &k.Prefix, &k.UserID,
+		&k.Scopes, &k.ExpiresAt, &k.RevokedAt, &k.LastUsedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// GetByKey looks up an API key by its plaintext value. The returned APIKey has
+// KeyHash set and Key empty. Revoked and expired keys are returned so the
+// caller can report why they were rejected.
+func (s *APIKeyStore) GetByKey(ctx context.Context, key string) (*auth.APIKey, error) {
+	k, err := scanAPIKey(s.pool.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE lookup = $1 AND key_hash = $2`,
+		auth.SecretLookup(key), auth.HashSecret(key),
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get API key: %w", err)
+	}
+	return k, nil
+}
+
+// Create generates and stores a new API key for userID with the given prefix
+// (auth.PrefixLive or auth.PrefixTest). The returned APIKey carries the
+// plaintext in Key; it cannot be retrieved again.
+func (s *APIKeyStore) Create(ctx context.Context, userID uuid.UUID, name string, prefix string, scopes []string, expiresAt *time.Time) (*auth.APIKey, error) {
+	plaintext, err := auth.GenerateAPIKey(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("generate API key: %w", err)
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	k := auth.APIKey{
+		Name:      name,
+		Key:       plaintext,
+		KeyHash:   auth.HashSecret(plaintext),
+		Lookup:    auth.SecretLookup(plaintext),
+		Prefix:    prefix,
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	err = s.pool.QueryRow(ctx,
+		`INSERT INTO api_keys (name, key_hash, lookup, prefix, user_id, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		k.Name, k.KeyHash, k.Lookup, k.Prefix, k.UserID, k.Scopes, k.ExpiresAt,
+	).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create API key: %w", err)
+	}
+	return &k, nil
+}
+
+// List returns the keys of userID, or of every user when userID is uuid.Nil,
+// newest first. Revoked and expired keys are included; Key is always empty.
+func (s *APIKeyStore) List(ctx context.Context, userID uuid.UUID) ([]auth.APIKey, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys
+		 WHERE $1::uuid IS NULL OR user_id = $1
+		 ORDER BY created_at DESC`,
+		pgtype.UUID{Bytes: userID, Valid: userID != uuid.Nil},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []auth.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list API keys: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke marks a key as revoked by ID. Revoking an already revoked key keeps
+// its original revocation time. It returns ErrAPIKeyNotFound for unknown IDs.
+func (s *APIKeyStore) Revoke(ctx context.Context, keyID uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`,
+		keyID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// MarkUsed sets last_used_at for the key, at most once per minute.
+func (s *APIKeyStore) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE api_keys SET last_used_at = now()
+		 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '`+usedThrottle+`')`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("mark API key used: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// LoginAttemptStore is an auth.LoginAttemptStore backed by the login_attempts
+// table.
+type LoginAttemptStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewLoginAttemptStore returns a LoginAttemptStore using pool.
+func NewLoginAttemptStore(pool *pgxpool.Pool) *LoginAttemptStore {
+	return &LoginAttemptStore{pool: pool}
+}
+
+// AuthEventStore is an auth.AuthEventRecorder backed by the auth_events
+// table.
+type AuthEventStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewAuthEventStore returns an AuthEventStore using pool.
+func NewAuthEventStore(pool *pgxpool.Pool) *AuthEventStore {
+	return &AuthEventStore{pool: pool}
+}
+
+// Compile-time checks.
+var (
+	_ auth.LoginAttemptStore  = (*LoginAttemptStore)(nil)
+	_ auth.LoginAttemptPruner = (*LoginAttemptStore)(nil)
+	_ auth.AuthEventRecorder  = (*AuthEventStore)(nil)
+)
+
+// GetLoginAttempts returns the failure state of key.
+func (s *LoginAttemptStore) GetLoginAttempts(ctx context.Context, key string) (auth.LoginAttempts, error) {
+	var a auth.LoginAttempts
+	err := s.pool.QueryRow(ctx,
+		`SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1`, key,
+	).Scan(&a.Failures, &a.LastFailureAt, &a.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return auth.LoginAttempts{}, nil
+	}
+	if err != nil {
+		return auth.LoginAttempts{}, fmt.Errorf("get login attempts: %w", err)
+	}
+	return a, nil
+}
+
+// recordFailureSQL counts a failure in one statement, so concurrent attempts
+// cannot lose increments. The count restarts once the first counted failure
+// falls outside the window.
+const recordFailureSQL = `
+INSERT INTO login_attempts (key, failures, first_failure_at, last_failure_at)
+VALUES ($1, 1, now(), now())
+ON CONFLICT (key) DO UPDATE SET
+	failures = CASE
+		WHEN login_attempts.first_failure_at > now() - make_interval(secs => $2)
+		THEN login_attempts.failures + 1
+		ELSE 1
+	END,
+	first_failure_at = CASE
+		WHEN login_attempts.first_failure_at > now() - make_interval(secs => $2)
+		THEN login_attempts.first_failure_at
+		ELSE now()
+	END,
+	last_failure_at = now()
+RETURNING failures, last_failure_at, locked_until`
+
+// RecordLoginFailure counts a failure for key within window.
+func (s *LoginAttemptStore) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (auth.LoginAttempts, error) {
+	var a auth.LoginAttempts
+	err := s.pool.QueryRow(ctx, recordFailureSQL, key, window.Seconds()).
+		Scan(&a.Failures, &a.LastFailureAt, &a.LockedUntil)
+	if err != nil {
+		return auth.LoginAttempts{}, fmt.Errorf("record login failure: %w", err)
+	}
+	return a, nil
+}
+
+// LockLogin locks key until the given time and clears its failure count.
+func (s *LoginAttemptStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE login_attempts SET locked_until = $2, failures = 0, first_failure_at = now() WHERE key = $1`,
+		key, until,
+	)
+	if err != nil {
+		return fmt.Errorf("lock login: %w", err)
+	}
+	return nil
+}
+
+// ResetLoginAttempts deletes the row for key.
+func (s *LoginAttemptStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("reset login attempts: %w", err)
+	}
+	return nil
+}
+
+// PruneLoginAttempts deletes unlocked keys whose last failure is older than
+// olderThan.
+func (s *LoginAttemptStore) PruneLoginAttempts(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM login_attempts
+		 WHERE last_failure_at < now() - make_interval(secs => $1)
+		   AND (locked_until IS NULL OR locked_until < now())`,
+		olderThan.Seconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prune login attempts: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RecordAuthEvent inserts event.
+func (s *AuthEventStore) RecordAuthEvent(ctx context.Context, e auth.AuthEvent) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO auth_events (type, email, ip, user_agent, detail, created_at)
+		 VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6)`,
+		e.Type, e.Email, e.IP, e.UserAgent, e.Detail, e.Time,
+	)
+	if err != nil {
+		return fmt.Errorf("record auth event: %w", err)
+	}
+	return nil
+}
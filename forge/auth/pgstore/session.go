@@ -0,0 +1,79 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// SessionIndex is an auth.SessionIndex backed by the user_sessions table.
+type SessionIndex struct {
+	pool *pgxpool.Pool
+}
+
+// NewSessionIndex returns a SessionIndex using pool.
+func NewSessionIndex(pool *pgxpool.Pool) *SessionIndex {
+	return &SessionIndex{pool: pool}
+}
+
+// Compile-time check.
+var _ auth.SessionIndex = (*SessionIndex)(nil)
+
+// SaveSession records s, keeping created_at of an existing row.
+func (s *SessionIndex) SaveSession(ctx context.Context, session auth.ActiveSession) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO user_sessions (token, user_id, user_agent, ip, created_at, last_seen_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			user_agent = EXCLUDED.user_agent,
+			ip = EXCLUDED.ip,
+			last_seen_at = EXCLUDED.last_seen_at`,
+		session.Token, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt,
+	)
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the sessions of userID that are still in the sessions
+// table and unexpired, most recently seen first.
+func (s *SessionIndex) ListSessions(ctx context.Context, userID string) ([]auth.ActiveSession, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT us.token, us.user_id, coalesce(us.user_agent, ''), coalesce(us.ip, ''), us.created_at, us.last_seen_at
+		 FROM user_sessions us
+		 JOIN sessions ON sessions.token = us.token AND sessions.expiry > now()
+		 WHERE us.user_id = $1
+		 ORDER BY us.last_seen_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []auth.ActiveSession
+	for rows.Next() {
+		var a auth.ActiveSession
+		if err := rows.Scan(&a.Token, &a.UserID, &a.UserAgent, &a.IP, &a.CreatedAt, &a.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("list sessions: %w", err)
+		}
+		sessions = append(sessions, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// DeleteSession removes the row for token.
+func (s *SessionIndex) DeleteSession(ctx context.Context, token string) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM user_sessions WHERE token = $1`, token); err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package pgstore provides PostgreSQL implementations of auth.TokenStore,
+// auth.APIKeyStore, auth.UserStore, auth.LoginAttemptStore,
+// auth.AuthEventRecorder, auth.SessionIndex and auth.TwoFactorStore over the
+// api_tokens, api_keys, users, account_tokens, login_attempts, auth_events,
+// user_sessions, user_totp and recovery_codes tables generated in schema.hcl.
+// Secrets are
+// stored as SHA-256 hashes with a short lookup prefix; the plaintext is
+// returned once, when the credential is created.
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// ErrTokenNotFound is returned when no stored token matches.
+var ErrTokenNotFound = errors.New("invalid bearer token")
+
+// usedThrottle bounds how often MarkUsed writes last_used_at for one
+// credential, so busy clients do not turn every request into a write.
+const usedThrottle = "1 minute"
+
+// TokenStore is an auth.TokenStore backed by the api_tokens table.
+type TokenStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewTokenStore returns a TokenStore using pool.
+func NewTokenStore(pool *pgxpool.Pool) *TokenStore {
+	return &TokenStore{pool: pool}
+}
+
+// Compile-time checks.
+var (
+	_ auth.TokenStore   = (*TokenStore)(nil)
+	_ auth.UsageTracker = (*TokenStore)(nil)
+)
+
+// GetByToken looks up a token by its plaintext value. The returned Token has
+// TokenHash set and Token empty.
+func (s *TokenStore) GetByToken(ctx context.Context, token string) (*auth.Token, error) {
+	var t auth.Token
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, token_hash, user_id, expires_at, last_used_at, created_at
+		 FROM api_tokens WHERE lookup = $1 AND token_hash = $2`,
+		auth.SecretLookup(token), auth.HashSecret(token),
+	).Scan(&t.ID, &t.TokenHash, &t.UserID, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get token: %w", err)
+	}
+	return &t, nil
+}
+
+// Create generates and stores a new bearer token for userID. The returned
+// Token carries the plaintext in Token; it cannot be retrieved again.
+func (s *TokenStore) Create(ctx context.Context, userID uuid.UUID, expiresAt time.Time) (*auth.Token, error) {
+	plaintext, err := auth.GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+	t := auth.Token{
+		Token:     plaintext,
+		TokenHash: auth.HashSecret(plaintext),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	err = s.pool.QueryRow(ctx,
+		`INSERT INTO api_tokens (token_hash, lookup, user_id, expires_at)
+		 VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		t.TokenHash, auth.SecretLookup(plaintext), userID, expiresAt,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+	return &t, nil
+}
+
+// Delete revokes a token by ID. It returns ErrTokenNotFound for unknown IDs.
+func (s *TokenStore) Delete(ctx context.Context, tokenID uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM api_tokens WHERE id = $1`, tokenID)
+	if err != nil {
+		return fmt.Errorf("delete token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// MarkUsed sets last_used_at for the token, at most once per minute.
+func (s *TokenStore) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE api_tokens SET last_used_at = now()
+		 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '`+usedThrottle+`')`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("mark token used: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// TwoFactorStore is an auth.TwoFactorStore backed by the user_totp and
+// recovery_codes tables. TOTP secrets must be readable to check codes, so
+// they are stored as is; recovery codes are stored as SHA-256 hashes.
+type TwoFactorStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewTwoFactorStore returns a TwoFactorStore using pool.
+func NewTwoFactorStore(pool *pgxpool.Pool) *TwoFactorStore {
+	return &TwoFactorStore{pool: pool}
+}
+
+// Compile-time check.
+var _ auth.TwoFactorStore = (*TwoFactorStore)(nil)
+
+// GetTwoFactor returns the user's enrolment and unused recovery code count.
+func (s *TwoFactorStore) GetTwoFactor(ctx context.Context, userID string) (*auth.TwoFactorState, error) {
+	var st auth.TwoFactorState
+	err := s.pool.QueryRow(ctx,
+		`SELECT t.secret, t.enabled_at,
+			(SELECT count(*) FROM recovery_codes c WHERE c.user_id = t.user_id)
+		 FROM user_totp t WHERE t.user_id = $1`, userID,
+	).Scan(&st.Secret, &st.EnabledAt, &st.RecoveryCodesLeft)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, auth.ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get two-factor: %w", err)
+	}
+	return &st, nil
+}
+
+// SaveTOTPSecret stores an unconfirmed secret, leaving an enabled one alone.
+func (s *TwoFactorStore) SaveTOTPSecret(ctx context.Context, userID, secret string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0
+		 WHERE user_totp.enabled_at IS NULL`,
+		userID, secret,
+	)
+	if err != nil {
+		return fmt.Errorf("save TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// EnableTwoFactor confirms the secret and replaces the recovery codes in one
+// transaction.
+func (s *TwoFactorStore) EnableTwoFactor(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	tag, err := tx.Exec(ctx, `UPDATE user_totp SET enabled_at = now() WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return auth.ErrTwoFactorNotEnrolled
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`,
+		userID, recoveryCodeHashes,
+	); err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records step unless it is not later than the last used one.
+func (s *TwoFactorStore) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE user_totp SET last_step = $2 WHERE user_id = $1 AND last_step < $2`,
+		userID, step,
+	)
+	if err != nil {
+		return fmt.Errorf("use TOTP step: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return auth.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// UseRecoveryCode deletes the matching recovery code.
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2`,
+		userID, codeHash,
+	)
+	if err != nil {
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return auth.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// DisableTwoFactor deletes the secret; its recovery codes are deleted by the
+// foreign key's ON DELETE CASCADE.
+func (s *TwoFactorStore) DisableTwoFactor(ctx context.Context, userID string) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("disable two-factor: %w", err)
+	}
+	return nil
+}
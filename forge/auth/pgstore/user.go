@@ -0,0 +1,168 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// uniqueViolation is the PostgreSQL error code for a unique constraint
+// violation.
+const uniqueViolation = "23505"
+
+// UserStore is an auth.UserStore backed by the users and account_tokens
+// tables.
+type UserStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewUserStore returns a UserStore using pool.
+func NewUserStore(pool *pgxpool.Pool) *UserStore {
+	return &UserStore{pool: pool}
+}
+
+// Compile-time check.
+var _ auth.UserStore = (*UserStore)(nil)
+
+// userColumns are scanned by scanUser, in order.
+const userColumns = `id, email, COALESCE(password_hash, ''), email_verified_at, created_at, updated_at`
+
+func scanUser(row pgx.Row) (*auth.User, error) {
+	var u auth.User
+	err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, auth.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateUser inserts a user. It returns auth.ErrEmailTaken when email is
+// already registered.
+func (s *UserStore) CreateUser(ctx context.Context, email, passwordHash string) (*auth.User, error) {
+	u, err := scanUser(s.pool.QueryRow(ctx,
+		`INSERT INTO users (email, password_hash) VALUES ($1, NULLIF($2, ''))
+		 RETURNING `+userColumns,
+		email, passwordHash,
+	))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return nil, auth.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	return u, nil
+}
+
+// GetUserByEmail looks up a user by lower-cased email.
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*auth.User, error) {
+	u, err := scanUser(s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+	if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	return u, err
+}
+
+// GetUserByID looks up a user by ID.
+func (s *UserStore) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	u, err := scanUser(s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	return u, err
+}
+
+// SetPassword replaces the user's password hash and removes their unused
+// password reset tokens.
+func (s *UserStore) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			`UPDATE users SET password_hash = $2, updated_at = now() WHERE id = $1`,
+			userID, passwordHash,
+		)
+		if err != nil {
+			return fmt.Errorf("set password: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return auth.ErrUserNotFound
+		}
+		_, err = tx.Exec(ctx,
+			`DELETE FROM account_tokens WHERE user_id = $1 AND purpose = $2`,
+			userID, auth.AccountTokenResetPassword,
+		)
+		if err != nil {
+			return fmt.Errorf("delete reset tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+// MarkEmailVerified sets email_verified_at, keeping the first verification
+// time.
+func (s *UserStore) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()), updated_at = now()
+		 WHERE id = $1`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("mark email verified: %w", err)
+	}
+	return nil
+}
+
+// CreateAccountToken generates and stores a single-use token for purpose,
+// returning its plaintext. Expired tokens of the user are removed.
+func (s *UserStore) CreateAccountToken(ctx context.Context, userID uuid.UUID, purpose string, expiresAt time.Time) (string, error) {
+	plaintext, err := auth.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM account_tokens WHERE user_id = $1 AND expires_at <= now()`, userID,
+		); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO account_tokens (token_hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)`,
+			auth.HashSecret(plaintext), userID, purpose, expiresAt,
+		)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("create account token: %w", err)
+	}
+	return plaintext, nil
+}
+
+// ConsumeAccountToken deletes an unexpired token of purpose and returns its
+// user. It returns auth.ErrInvalidAccountToken when none matches, so each
+// token works once.
+func (s *UserStore) ConsumeAccountToken(ctx context.Context, token, purpose string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.pool.QueryRow(ctx,
+		`DELETE FROM account_tokens
+		 WHERE token_hash = $1 AND purpose = $2 AND expires_at > now()
+		 RETURNING user_id`,
+		auth.HashSecret(token), purpose,
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, auth.ErrInvalidAccountToken
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("consume account token: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/notify"
+)
+
+// RolesChannel is the notify channel on which role and assignment changes are
+// published. Events are published with uuid.Nil as the tenant so every
+// instance's RBAC receives them.
+const RolesChannel = "roles"
+
+// defaultRBACCacheTTL bounds how long a user's cached grants are trusted when no
+// invalidation arrives, e.g. when no notify hub is wired.
+const defaultRBACCacheTTL = 5 * time.Minute
+
+// RBAC loads roles and permission strings from the generated roles and
+// role_assignments tables. A role holds permission strings such as
+// "product.update" (or the wildcards "product.*" and "*"); assigning it to a
+// user grants them in every tenant when the assignment's tenant_id is NULL, or
+// in that tenant only otherwise.
+//
+// Use Roles as an auth.RoleResolver, Permissions as an auth.PermissionResolver
+// and IsTenantMember as an auth.TenantAuthorizer, or wire all three with
+// forge.App.UseRBAC. Grants are
+// cached per user and tenant; call Listen with a notify hub so changes
+// published via PublishRoleChange clear the cache on every instance.
+type RBAC struct {
+	pool *pgxpool.Pool
+	ttl  time.Duration
+	// load reads the roles assigned to a user globally or in a tenant
+	// (uuid.Nil for none).
+	load func(ctx context.Context, userID, tenantID uuid.UUID) ([]roleGrant, error)
+
+	mu    sync.RWMutex
+	cache map[rbacKey]cachedGrants
+}
+
+// rbacKey identifies a user's grants within a tenant (uuid.Nil without one).
+type rbacKey struct {
+	userID   uuid.UUID
+	tenantID uuid.UUID
+}
+
+// roleGrant is an assigned role and the permission strings it holds.
+type roleGrant struct {
+	name        string
+	permissions []string
+}
+
+// cachedGrants is a user's loaded roles and permissions kept until expires or
+// the next invalidation.
+type cachedGrants struct {
+	roles       []string
+	permissions []string
+	expires     time.Time
+}
+
+// NewRBAC returns an RBAC that reads the roles and role_assignments tables
+// through pool.
+func NewRBAC(pool *pgxpool.Pool) *RBAC {
+	b := &RBAC{
+		pool:  pool,
+		ttl:   defaultRBACCacheTTL,
+		cache: make(map[rbacKey]cachedGrants),
+	}
+	b.load = b.queryGrants
+	return b
+}
+
+// grantsSQL loads every role assigned to the user globally or in the tenant.
+const grantsSQL = `
+SELECT r.name, r.permissions
+FROM role_assignments ra
+JOIN roles r ON r.id = ra.role_id
+WHERE ra.user_id = $1 AND (ra.tenant_id IS NULL OR ra.tenant_id = $2)
+ORDER BY r.name`
+
+// Roles returns the names of the roles assigned to userID in the tenant in
+// ctx. It satisfies RoleResolver.
+func (b *RBAC) Roles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	g, err := b.grants(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return g.roles, nil
+}
+
+// allRolesSQL loads every role assigned to the user, in any tenant or none.
+const allRolesSQL = `
+SELECT DISTINCT r.name
+FROM role_assignments ra
+JOIN roles r ON r.id = ra.role_id
+WHERE ra.user_id = $1
+ORDER BY r.name`
+
+// AllRoles returns the names of the roles assigned to userID in any tenant,
+// ignoring the tenant in ctx. Impersonation uses it to refuse impersonating a
+// tenant's admin. Results are not cached.
+func (b *RBAC) AllRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := b.pool.Query(ctx, allRolesSQL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load roles for user %s: %w", userID, err)
+	}
+	roles, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("load roles for user %s: %w", userID, err)
+	}
+	return roles, nil
+}
+
+// Permissions returns the permission strings granted to userID by its roles in
+// the tenant in ctx. It satisfies PermissionResolver.
+func (b *RBAC) Permissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	g, err := b.grants(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return g.permissions, nil
+}
+
+// IsTenantMember reports whether userID holds a role in tenantID, either
+// assigned in that tenant or assigned without a tenant, which admits the user
+// to every tenant. It satisfies TenantAuthorizer.
+func (b *RBAC) IsTenantMember(ctx context.Context, userID, tenantID uuid.UUID) (bool, error) {
+	g, err := b.grants(WithTenant(ctx, tenantID), userID)
+	if err != nil {
+		return false, err
+	}
+	return len(g.roles) > 0, nil
+}
+
+// Can reports whether userID holds permission in the tenant in ctx. Use it for
+// checks outside generated actions.
+func (b *RBAC) Can(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	permissions, err := b.Permissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return HasPermission(WithPermissions(ctx, permissions...), permission), nil
+}
+
+// grants returns the cached grants for the user and tenant, loading them on a
+// miss. The permissions of every role are merged without duplicates.
+func (b *RBAC) grants(ctx context.Context, userID uuid.UUID) (cachedGrants, error) {
+	tenantID, _ := TenantFromContext(ctx)
+	key := rbacKey{userID: userID, tenantID: tenantID}
+	if g, ok := b.cached(key); ok {
+		return g, nil
+	}
+
+	roles, err := b.load(ctx, userID, tenantID)
+	if err != nil {
+		return cachedGrants{}, fmt.Errorf("load roles for user %s: %w", userID, err)
+	}
+	var g cachedGrants
+	for _, role := range roles {
+		g.roles = append(g.roles, role.name)
+		for _, p := range role.permissions {
+			if !slices.Contains(g.permissions, p) {
+				g.permissions = append(g.permissions, p)
+			}
+		}
+	}
+
+	b.store(key, g)
+	return g, nil
+}
+
+// queryGrants loads the roles assigned to userID globally or in tenantID from
+// the database.
+func (b *RBAC) queryGrants(ctx context.Context, userID, tenantID uuid.UUID) ([]roleGrant, error) {
+	tenant := pgtype.UUID{Bytes: tenantID, Valid: tenantID != uuid.Nil}
+	rows, err := b.pool.Query(ctx, grantsSQL, userID, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []roleGrant
+	for rows.Next() {
+		var role roleGrant
+		if err := rows.Scan(&role.name, &role.permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (b *RBAC) cached(key rbacKey) (cachedGrants, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	g, ok := b.cache[key]
+	if !ok || time.Now().After(g.expires) {
+		return cachedGrants{}, false
+	}
+	return g, true
+}
+
+func (b *RBAC) store(key rbacKey, g cachedGrants) {
+	g.expires = time.Now().Add(b.ttl)
+	b.mu.Lock()
+	b.cache[key] = g
+	b.mu.Unlock()
+}
+
+// Invalidate clears the cached grants of userID, or of every user when userID
+// is uuid.Nil. Listen calls it for each published change; call it directly
+// after changing roles without a notify hub.
+func (b *RBAC) Invalidate(userID uuid.UUID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if userID == uuid.Nil {
+		clear(b.cache)
+		return
+	}
+	for key := range b.cache {
+		if key.userID == userID {
+			delete(b.cache, key)
+		}
+	}
+}
+
+// roleChange is the payload published on RolesChannel.
+type roleChange struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Listen invalidates cached grants whenever a change is published on
+// RolesChannel. It blocks until ctx is cancelled, so run it in its own
+// goroutine. The hub must be started separately.
+func (b *RBAC) Listen(ctx context.Context, hub notify.NotifyHub) {
+	sub := hub.Subscribe(RolesChannel, uuid.Nil)
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			var change roleChange
+			if err := json.Unmarshal(evt.Payload, &change); err != nil {
+				change.UserID = uuid.Nil
+			}
+			b.Invalidate(change.UserID)
+		}
+	}
+}
+
+// PublishRoleChange notifies every RBAC listening on hub that grants changed.
+// Pass the user whose assignments changed, or uuid.Nil after editing a role's
+// permissions or deleting a role, which affects every holder. Call it after
+// committing the change.
+func PublishRoleChange(ctx context.Context, hub notify.NotifyHub, userID uuid.UUID) error {
+	payload, err := json.Marshal(roleChange{UserID: userID})
+	if err != nil {
+		return err
+	}
+	return hub.Publish(ctx, RolesChannel, uuid.Nil, payload)
+}
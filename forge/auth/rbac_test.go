@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeGrants serves role assignments from memory. Roles assigned with
+// uuid.Nil as the tenant apply in every tenant.
+type fakeGrants struct {
+	assigned map[uuid.UUID][]roleGrant // tenant ID -> roles
+	loads    int
+}
+
+func (f *fakeGrants) load(_ context.Context, _, tenantID uuid.UUID) ([]roleGrant, error) {
+	f.loads++
+	roles := slices.Clone(f.assigned[uuid.Nil])
+	if tenantID != uuid.Nil {
+		roles = append(roles, f.assigned[tenantID]...)
+	}
+	return roles, nil
+}
+
+func newTestRBAC(f *fakeGrants) *RBAC {
+	b := NewRBAC(nil)
+	b.load = f.load
+	return b
+}
+
+func TestRBAC_MergesRoles(t *testing.T) {
+	user, tenant, other := uuid.New(), uuid.New(), uuid.New()
+	f := &fakeGrants{assigned: map[uuid.UUID][]roleGrant{
+		uuid.Nil: {{name: "viewer", permissions: []string{"product.read", "order.read"}}},
+		tenant:   {{name: "editor", permissions: []string{"product.read", "product.update", "order.*"}}},
+	}}
+	b := newTestRBAC(f)
+	ctx := WithTenant(context.Background(), tenant)
+
+	roles, err := b.Roles(ctx, user)
+	if err != nil {
+		t.Fatalf("Roles: %v", err)
+	}
+	if want := []string{"viewer", "editor"}; !slices.Equal(roles, want) {
+		t.Errorf("Roles = %v, want %v", roles, want)
+	}
+	permissions, err := b.Permissions(ctx, user)
+	if err != nil {
+		t.Fatalf("Permissions: %v", err)
+	}
+	if want := []string{"product.read", "order.read", "product.update", "order.*"}; !slices.Equal(permissions, want) {
+		t.Errorf("Permissions = %v, want %v without duplicates", permissions, want)
+	}
+
+	tests := []struct {
+		ctx        context.Context
+		permission string
+		want       bool
+	}{
+		{ctx, "product.update", true},
+		{ctx, "order.delete", true}, // order.*
+		{ctx, "product.delete", false},
+		// Outside the tenant only the global role applies
+		{WithTenant(context.Background(), other), "product.update", false},
+		{WithTenant(context.Background(), other), "order.read", true},
+		{context.Background(), "order.delete", false},
+	}
+	for _, tt := range tests {
+		tenantID, _ := TenantFromContext(tt.ctx)
+		if got, err := b.Can(tt.ctx, user, tt.permission); err != nil || got != tt.want {
+			t.Errorf("Can(tenant %s, %q) = %v, %v, want %v", tenantID, tt.permission, got, err, tt.want)
+		}
+	}
+}
+
+func TestRBAC_TenantMembership(t *testing.T) {
+	member, global, outsider := uuid.New(), uuid.New(), uuid.New()
+	tenant := uuid.New()
+	b := NewRBAC(nil)
+	b.load = func(_ context.Context, userID, tenantID uuid.UUID) ([]roleGrant, error) {
+		switch {
+		case userID == global:
+			return []roleGrant{{name: "support"}}, nil
+		case userID == member && tenantID == tenant:
+			return []roleGrant{{name: "editor"}}, nil
+		}
+		return nil, nil
+	}
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		want   bool
+	}{
+		{"tenant role", member, true},
+		{"global role", global, true},
+		{"no role", outsider, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := b.IsTenantMember(context.Background(), tt.userID, tenant); err != nil || got != tt.want {
+				t.Errorf("IsTenantMember = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBAC_Cache(t *testing.T) {
+	user, other := uuid.New(), uuid.New()
+	f := &fakeGrants{assigned: map[uuid.UUID][]roleGrant{
+		uuid.Nil: {{name: "viewer", permissions: []string{"product.read"}}},
+	}}
+	b := newTestRBAC(f)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Permissions(ctx, user); err != nil {
+			t.Fatalf("Permissions: %v", err)
+		}
+	}
+	if _, err := b.Permissions(ctx, other); err != nil {
+		t.Fatalf("Permissions: %v", err)
+	}
+	if f.loads != 2 {
+		t.Errorf("loads = %d, want 2: grants are cached per user", f.loads)
+	}
+
+	// Invalidating one user reloads only that user's grants
+	f.assigned[uuid.Nil][0].permissions = []string{"product.*"}
+	b.Invalidate(user)
+	if ok, _ := b.Can(ctx, user, "product.delete"); !ok {
+		t.Error("Can after Invalidate does not see the new permission")
+	}
+	if _, err := b.Permissions(ctx, other); err != nil {
+		t.Fatalf("Permissions: %v", err)
+	}
+	if f.loads != 3 {
+		t.Errorf("loads = %d, want 3", f.loads)
+	}
+
+	// Load failures are returned and not cached
+	b.load = func(context.Context, uuid.UUID, uuid.UUID) ([]roleGrant, error) {
+		return nil, errors.New("connection refused")
+	}
+	b.Invalidate(uuid.Nil)
+	if _, err := b.Roles(ctx, user); err == nil {
+		t.Error("Roles: want the load error")
+	}
+	if _, ok := b.cached(rbacKey{userID: user}); ok {
+		t.Error("failed load was cached")
+	}
+}
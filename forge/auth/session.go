@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -17,23 +18,50 @@ const (
 	// SessionKeyUserEmail is the key used to store the authenticated user's
 	// email address in the session.
 	SessionKeyUserEmail = "user_email"
+
+	// SessionKeyUserRoles is the key used to store roles granted at sign-in,
+	// such as those mapped from an OIDC provider's groups claim. The value is
+	// a []string.
+	SessionKeyUserRoles = "user_roles"
+
+	// sessionKeyFlash holds a one-time notice shown by the next login page,
+	// e.g. after a password reset.
+	sessionKeyFlash = "flash"
 )
 
+// SessionConfig configures NewSessionManager. Zero values use the defaults.
+type SessionConfig struct {
+	// Lifetime is how long a session lasts after sign-in, however active.
+	// Default: 24 hours.
+	Lifetime time.Duration
+	// IdleTimeout ends a session that makes no request for this long. Zero
+	// disables it.
+	IdleTimeout time.Duration
+	// Secure sends the cookie over HTTPS only. Set it everywhere but local
+	// development.
+	Secure bool
+	// CookieDomain shares the cookie with subdomains, e.g. "example.com".
+	// Empty scopes it to the host that set it.
+	CookieDomain string
+}
+
 // NewSessionManager creates and configures an SCS session manager backed by
-// PostgreSQL via pgxstore. Sessions are scoped to 24 hours. The cookie is
-// named "forge_session" and is marked httpOnly with SameSite=Lax to prevent
-// CSRF via cross-site requests.
-//
-// isDev controls Cookie.Secure: set false during local development (HTTP), true
-// in production (HTTPS only).
-func NewSessionManager(pool *pgxpool.Pool, isDev bool) *scs.SessionManager {
+// PostgreSQL via pgxstore. The cookie is named "forge_session" and is marked
+// httpOnly with SameSite=Lax; the CSRF middleware protects state-changing
+// requests.
+func NewSessionManager(pool *pgxpool.Pool, cfg SessionConfig) *scs.SessionManager {
 	sm := scs.New()
 	sm.Store = pgxstore.New(pool)
 	sm.Lifetime = 24 * time.Hour
+	if cfg.Lifetime > 0 {
+		sm.Lifetime = cfg.Lifetime
+	}
+	sm.IdleTimeout = cfg.IdleTimeout
 	sm.Cookie.Name = "forge_session"
+	sm.Cookie.Domain = cfg.CookieDomain
 	sm.Cookie.HttpOnly = true
 	sm.Cookie.SameSite = http.SameSiteLaxMode
-	sm.Cookie.Secure = !isDev
+	sm.Cookie.Secure = cfg.Secure
 	return sm
 }
 
@@ -43,3 +71,22 @@ func NewSessionManager(pool *pgxpool.Pool, isDev bool) *scs.SessionManager {
 func SessionMiddleware(sm *scs.SessionManager) func(http.Handler) http.Handler {
 	return sm.LoadAndSave
 }
+
+// InvalidateUserSessions destroys every stored session of the user, signing
+// them out on all devices. It iterates the session store, which must
+// implement scs.IterableStore (pgxstore and memstore do). A session loaded in
+// the current request is destroyed in the store but rewritten when the request
+// completes unless it is destroyed or renewed too.
+func InvalidateUserSessions(ctx context.Context, sm *scs.SessionManager, userID string) error {
+	return sm.Iterate(ctx, func(ctx context.Context) error {
+		if sm.GetString(ctx, SessionKeyUserID) != userID {
+			return nil
+		}
+		return sm.Destroy(ctx)
+	})
+}
+
+// setFlash stores a notice for the next login page.
+func setFlash(sm *scs.SessionManager, r *http.Request, msg string) {
+	sm.Put(r.Context(), sessionKeyFlash, msg)
+}
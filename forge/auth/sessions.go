@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// sessionKeySeen holds when Sessions.Track last recorded the session, as Unix
+// seconds, so busy sessions do not turn every request into a write.
+const sessionKeySeen = "session_seen_at"
+
+// seenInterval bounds how often Sessions.Track updates LastSeenAt.
+const seenInterval = time.Minute
+
+// ActiveSession is one signed-in session of a user, as listed on the
+// /auth/sessions page.
+type ActiveSession struct {
+	// ID identifies the session in pages and forms. It is derived from the
+	// token but does not reveal it.
+	ID string
+	// Token is the session token. Never render it: it signs in as the user.
+	Token     string
+	UserID    string
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	// LastSeenAt is accurate to about a minute.
+	LastSeenAt time.Time
+	// Current reports the session making the request.
+	Current bool
+}
+
+// Device summarizes the session's user agent, e.g. "Firefox on macOS".
+func (s ActiveSession) Device() string {
+	ua := s.UserAgent
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"Safari/", "Safari"}, {"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Android", "Android"},
+		{"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			return browser + " on " + o.name
+		}
+	}
+	return browser
+}
+
+// SessionIndex records which sessions belong to which user, so a user's
+// sessions can be listed and revoked without scanning every session.
+// pgstore.SessionIndex uses the generated user_sessions table.
+type SessionIndex interface {
+	// SaveSession records s by token, keeping CreatedAt of an existing entry.
+	SaveSession(ctx context.Context, s ActiveSession) error
+	// ListSessions returns the unexpired sessions of userID, most recently
+	// seen first. ID and Current are filled in by Sessions.
+	ListSessions(ctx context.Context, userID string) ([]ActiveSession, error)
+	// DeleteSession forgets the session with the given token.
+	DeleteSession(ctx context.Context, token string) error
+}
+
+// ErrSessionNotFound is returned by Sessions.Revoke when the user has no
+// session with the given ID.
+var ErrSessionNotFound = errors.New("auth: session not found")
+
+// Sessions indexes the signed-in sessions of each user. Its Track middleware
+// keeps the index current; List, Revoke and RevokeAll let users see their
+// devices and sign them out.
+type Sessions struct {
+	sm    *scs.SessionManager
+	index SessionIndex
+}
+
+// NewSessions returns a Sessions recording the sessions of sm in index.
+func NewSessions(sm *scs.SessionManager, index SessionIndex) *Sessions {
+	return &Sessions{sm: sm, index: index}
+}
+
+// Track is a Chi-compatible middleware that records signed-in sessions with
+// their user agent, client IP and last activity. It must run after the session
+// is loaded (sm.LoadAndSave). Sign-ins, token renewals and sign-outs made by
+// the handler are recorded once it returns. A session used to impersonate
+// another user stays listed under the admin who signed in.
+func (s *Sessions) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, token := sessionOwner(s.sm, ctx), s.sm.Token(ctx)
+		if userID != "" && time.Since(time.Unix(s.sm.GetInt64(ctx, sessionKeySeen), 0)) > seenInterval {
+			s.sm.Put(ctx, sessionKeySeen, time.Now().Unix())
+			s.save(r, userID, token)
+		}
+
+		next.ServeHTTP(w, r)
+
+		afterUserID, afterToken := sessionOwner(s.sm, ctx), s.sm.Token(ctx)
+		if afterUserID == userID && afterToken == token {
+			return
+		}
+		if userID != "" && token != "" {
+			if err := s.index.DeleteSession(ctx, token); err != nil {
+				log.Printf("session index error: %v", err)
+			}
+		}
+		if afterUserID != "" {
+			s.save(r, afterUserID, afterToken)
+		}
+	})
+}
+
+func (s *Sessions) save(r *http.Request, userID, token string) {
+	if token == "" {
+		return
+	}
+	now := time.Now()
+	err := s.index.SaveSession(r.Context(), ActiveSession{
+		Token:      token,
+		UserID:     userID,
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		log.Printf("session index error: %v", err)
+	}
+}
+
+// List returns the sessions of userID, marking the one in ctx as Current.
+func (s *Sessions) List(ctx context.Context, userID string) ([]ActiveSession, error) {
+	sessions, err := s.index.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	current := s.sm.Token(ctx)
+	for i := range sessions {
+		sessions[i].ID = HashSecret(sessions[i].Token)[:16]
+		sessions[i].Current = sessions[i].Token == current
+	}
+	return sessions, nil
+}
+
+// Revoke signs out the session of userID with the given ID. Revoking the
+// current session only deletes it from the store; call LogoutUser to clear
+// the request's session data too.
+func (s *Sessions) Revoke(ctx context.Context, userID, id string) error {
+	sessions, err := s.List(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == id {
+			return s.revoke(ctx, session.Token)
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeAll signs out every indexed session of userID, e.g. after a password
+// change. A session loaded in the current request is deleted from the store
+// but rewritten when the request completes unless it is destroyed or renewed
+// too.
+func (s *Sessions) RevokeAll(ctx context.Context, userID string) error {
+	sessions, err := s.index.ListSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, session := range sessions {
+		errs = append(errs, s.revoke(ctx, session.Token))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sessions) revoke(ctx context.Context, token string) error {
+	var err error
+	if store, ok := s.sm.Store.(scs.CtxStore); ok {
+		err = store.DeleteCtx(ctx, token)
+	} else {
+		err = s.sm.Store.Delete(token)
+	}
+	if err != nil {
+		return err
+	}
+	return s.index.DeleteSession(ctx, token)
+}
+
+// HandleList returns an http.HandlerFunc for GET /auth/sessions that renders
+// the signed-in user's sessions with pages.Sessions. Visitors who are not
+// signed in are redirected to the login page.
+func (s *Sessions) HandleList(pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetSessionUserID(s.sm, r)
+		if userID == "" {
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+			return
+		}
+		s.render(w, r, userID, pages, PageData{Notice: s.sm.PopString(r.Context(), sessionKeyFlash)})
+	}
+}
+
+// HandleRevoke returns an http.HandlerFunc for POST /auth/sessions/revoke,
+// which signs out the session named by the id form field. Revoking the
+// current session signs this browser out.
+func (s *Sessions) HandleRevoke(pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetSessionUserID(s.sm, r)
+		if userID == "" {
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		current := HashSecret(s.sm.Token(r.Context()))[:16]
+		err := s.Revoke(r.Context(), userID, r.PostFormValue("id"))
+		switch {
+		case errors.Is(err, ErrSessionNotFound):
+			s.render(w, r, userID, pages, PageData{Error: "That session has already ended."})
+			return
+		case err != nil:
+			log.Printf("revoke session error: %v", err)
+			http.Error(w, "Could not sign out the session. Please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		if r.PostFormValue("id") == current {
+			if err := LogoutUser(s.sm, r); err != nil {
+				log.Printf("logout error: %v", err)
+			}
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		setFlash(s.sm, r, "The session has been signed out.")
+		http.Redirect(w, r, "/auth/sessions", http.StatusSeeOther)
+	}
+}
+
+// HandleRevokeAll returns an http.HandlerFunc for POST
+// /auth/sessions/revoke-all, which signs the user out everywhere, this
+// browser included.
+func (s *Sessions) HandleRevokeAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetSessionUserID(s.sm, r)
+		if userID == "" {
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		if err := s.RevokeAll(r.Context(), userID); err != nil {
+			log.Printf("revoke sessions error: %v", err)
+			http.Error(w, "Could not sign out everywhere. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		if err := LogoutUser(s.sm, r); err != nil {
+			log.Printf("logout error: %v", err)
+		}
+		setFlash(s.sm, r, "You have been signed out on every device.")
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+	}
+}
+
+func (s *Sessions) render(w http.ResponseWriter, r *http.Request, userID string, pages Pages, data PageData) {
+	sessions, err := s.List(r.Context(), userID)
+	if err != nil {
+		log.Printf("list sessions error: %v", err)
+		http.Error(w, "Could not load your sessions. Please try again.", http.StatusInternalServerError)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Token = ""
+	}
+	data.Sessions = sessions
+	renderPage(w, r, http.StatusOK, pages.Sessions(data))
+}
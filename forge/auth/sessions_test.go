@@ -0,0 +1,201 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/forgetest"
+)
+
+// memSessionIndex is an in-memory auth.SessionIndex. Like the pgstore index,
+// it lists only sessions still present in the session store.
+type memSessionIndex struct {
+	mu       sync.Mutex
+	store    scs.Store
+	sessions map[string]auth.ActiveSession
+}
+
+func (x *memSessionIndex) SaveSession(ctx context.Context, s auth.ActiveSession) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if old, ok := x.sessions[s.Token]; ok {
+		s.CreatedAt = old.CreatedAt
+	}
+	x.sessions[s.Token] = s
+	return nil
+}
+
+func (x *memSessionIndex) ListSessions(ctx context.Context, userID string) ([]auth.ActiveSession, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var list []auth.ActiveSession
+	for token, s := range x.sessions {
+		if _, found, _ := x.store.Find(token); found && s.UserID == userID {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeenAt.After(list[j].LastSeenAt) })
+	return list, nil
+}
+
+func (x *memSessionIndex) DeleteSession(ctx context.Context, token string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.sessions, token)
+	return nil
+}
+
+// newSessionsApp is newAccountsApp with sessions tracked in the returned
+// index.
+func newSessionsApp(t *testing.T) (*accountsApp, *memSessionIndex) {
+	t.Helper()
+
+	sm := scs.New()
+	index := &memSessionIndex{store: sm.Store, sessions: map[string]auth.ActiveSession{}}
+	sessions := auth.NewSessions(sm, index)
+
+	a := &accountsApp{users: newMemUserStore(), mailer: forgetest.NewMailer()}
+	accounts, err := auth.NewAccounts(auth.AccountsConfig{Users: a.users, Mailer: a.mailer, BaseURL: testBaseURL, Sessions: sessions})
+	if err != nil {
+		t.Fatalf("NewAccounts: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(sm.LoadAndSave, sessions.Track)
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{Accounts: accounts, Sessions: sessions})
+	r.With(auth.SessionUser(sm, nil, nil)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.UserFromContext(r.Context()).String()))
+	})
+	a.server = forgetest.NewApp(t, r)
+	return a, index
+}
+
+// sessionID returns the ID under which the browser's session is listed.
+func (b *browser) sessionID(t *testing.T) string {
+	t.Helper()
+	u, _ := url.Parse(b.app.server.URL)
+	for _, c := range b.client.Jar.Cookies(u) {
+		if c.Name == "session" {
+			return auth.HashSecret(c.Value)[:16]
+		}
+	}
+	t.Fatal("browser has no session cookie")
+	return ""
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	app, _ := newSessionsApp(t)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+	stranger := app.browser()
+	stranger.do(t, http.MethodPost, "/auth/signup", signupForm("bob@example.com", "correct horse"))
+
+	_, body := b.do(t, http.MethodGet, "/auth/sessions", nil)
+	if n := strings.Count(body, `action="/auth/sessions/revoke"`); n != 2 {
+		t.Fatalf("listed %d sessions, want 2: %q", n, body)
+	}
+	if !strings.Contains(body, "(this browser)") || !strings.Contains(body, "127.0.0.1") {
+		t.Errorf("sessions page lacks the current session or IP: %q", body)
+	}
+	if !strings.Contains(body, other.sessionID(t)) || strings.Contains(body, stranger.sessionID(t)) {
+		t.Errorf("sessions page lists the wrong sessions: %q", body)
+	}
+
+	// Another user's session cannot be revoked.
+	if _, body := b.do(t, http.MethodPost, "/auth/sessions/revoke", url.Values{"id": {stranger.sessionID(t)}}); !strings.Contains(body, "already ended") {
+		t.Errorf("revoking another user's session: %q", body)
+	}
+	if stranger.userID(t) == uuid.Nil.String() {
+		t.Fatal("another user's session was revoked")
+	}
+
+	userID := b.userID(t)
+	if _, body := b.do(t, http.MethodPost, "/auth/sessions/revoke", url.Values{"id": {other.sessionID(t)}}); !strings.Contains(body, "has been signed out") {
+		t.Errorf("revoke: %q", body)
+	}
+	if other.userID(t) != uuid.Nil.String() {
+		t.Error("revoked session is still signed in")
+	}
+	if b.userID(t) != userID {
+		t.Error("revoking another session signed out the current one")
+	}
+
+	// Revoking the current session signs this browser out.
+	b.do(t, http.MethodPost, "/auth/sessions/revoke", url.Values{"id": {b.sessionID(t)}})
+	if b.userID(t) != uuid.Nil.String() {
+		t.Error("revoking the current session did not sign out")
+	}
+}
+
+func TestSessions_RevokeAll(t *testing.T) {
+	app, index := newSessionsApp(t)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+
+	if _, body := b.do(t, http.MethodPost, "/auth/sessions/revoke-all", nil); !strings.Contains(body, "signed out on every device") {
+		t.Errorf("revoke-all did not show the notice: %q", body)
+	}
+	if b.userID(t) != uuid.Nil.String() || other.userID(t) != uuid.Nil.String() {
+		t.Error("a session survived revoke-all")
+	}
+	if list, _ := index.ListSessions(context.Background(), userID); len(list) != 0 {
+		t.Errorf("index still lists %d sessions", len(list))
+	}
+}
+
+func TestSessions_PasswordChangeRevokesOthers(t *testing.T) {
+	app, index := newSessionsApp(t)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+
+	b.do(t, http.MethodPost, "/auth/change-password", url.Values{
+		"current_password": {"correct horse"}, "password": {"battery staple"}, "password_confirm": {"battery staple"},
+	})
+	if other.userID(t) != uuid.Nil.String() {
+		t.Error("other session survived the password change")
+	}
+	if b.userID(t) != userID {
+		t.Error("changing the password signed out the current session")
+	}
+	list, _ := index.ListSessions(context.Background(), userID)
+	if len(list) != 1 || auth.HashSecret(list[0].Token)[:16] != b.sessionID(t) {
+		t.Errorf("index lists %+v, want only the current session", list)
+	}
+}
+
+func TestActiveSession_Device(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want string
+	}{
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15", "Safari on macOS"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0", "Edge on Windows"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"curl/8.7.1", "curl"},
+		{"", "Unknown browser"},
+	}
+	for _, tt := range tests {
+		if got := (auth.ActiveSession{UserAgent: tt.ua}).Device(); got != tt.want {
+			t.Errorf("Device(%q) = %q, want %q", tt.ua, got, tt.want)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 // tenantContextKey is a private context key type for storing tenant IDs.
@@ -25,12 +30,56 @@ func TenantFromContext(ctx context.Context) (uuid.UUID, bool) {
 	return id, ok
 }
 
+// SetLocalTenant sets app.current_tenant to the tenant in ctx for the rest of
+// tx, so the row-level security policies generated for TenantScoped tables admit
+// that tenant's rows. It is a no-op when ctx carries no tenant: the policies then
+// compare against NULL, so queries see no rows and writes fail the policy check,
+// unless the connection's role has BYPASSRLS.
+func SetLocalTenant(ctx context.Context, tx pgx.Tx) error {
+	id, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	// set_config(..., true) is SET LOCAL with a bind parameter.
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.current_tenant', $1, true)", id.String()); err != nil {
+		return fmt.Errorf("set app.current_tenant: %w", err)
+	}
+	return nil
+}
+
 // TenantResolver extracts a tenant ID from an HTTP request.
 // Implement this interface to define your tenant identification strategy.
 type TenantResolver interface {
 	Resolve(r *http.Request) (uuid.UUID, error)
 }
 
+// TenantAuthorizer reports whether an authenticated user may act within a
+// tenant. The tenant middlewares call it for every request with a user, so
+// nobody reaches another tenant's rows by sending its header, host or path.
+// RBAC.IsTenantMember admits users holding a role in the tenant.
+type TenantAuthorizer func(ctx context.Context, userID, tenantID uuid.UUID) (bool, error)
+
+// ErrNotTenantMember is reported when the TenantAuthorizer rejects the
+// request's user for the resolved tenant. The tenant middlewares respond with
+// 403 Forbidden.
+var ErrNotTenantMember = errors.New("user is not a member of this tenant")
+
+// AuthorizeTenant returns ErrNotTenantMember unless authorize admits userID to
+// tenantID. A nil authorize admits nobody.
+func AuthorizeTenant(ctx context.Context, authorize TenantAuthorizer, userID, tenantID uuid.UUID) error {
+	if authorize == nil {
+		return ErrNotTenantMember
+	}
+	ok, err := authorize(ctx, userID, tenantID)
+	if err != nil {
+		return fmt.Errorf("authorize tenant: %w", err)
+	}
+	if !ok {
+		return ErrNotTenantMember
+	}
+	return nil
+}
+
 // HeaderTenantResolver reads the tenant ID from a request header.
 // The Header field defaults to X-Tenant-ID if not set.
 type HeaderTenantResolver struct {
@@ -110,18 +159,72 @@ func (p PathTenantResolver) Resolve(r *http.Request) (uuid.UUID, error) {
 }
 
 // TenantMiddleware returns an HTTP middleware that resolves the tenant from each
-// request and stores it in the context via WithTenant. If the resolver returns an
-// error, the middleware responds with 401 Unauthorized and does not call next.
-func TenantMiddleware(resolver TenantResolver) func(http.Handler) http.Handler {
+// request and stores it in the context via WithTenant. When the session has a
+// signed-in user, authorize must admit them to the tenant, so a session cookie
+// shared across tenant subdomains only opens the user's own tenants. If the
+// resolver returns an error, the middleware responds with an HTML error page —
+// 403 Forbidden for ErrTenantSuspended, 401 Unauthorized otherwise — and does
+// not call next; a user the authorizer rejects gets 403 Forbidden. The API uses
+// its own Huma middleware that returns problem JSON.
+func TenantMiddleware(sm *scs.SessionManager, resolver TenantResolver, authorize TenantAuthorizer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tenantID, err := resolver.Resolve(r)
 			if err != nil {
-				http.Error(w, "tenant not found", http.StatusUnauthorized)
+				status := http.StatusUnauthorized
+				if errors.Is(err, ErrTenantSuspended) {
+					status = http.StatusForbidden
+				}
+				writeTenantError(w, status, "Tenant not found", "We could not tell which account this request is for.", err)
 				return
 			}
+			if userID, perr := uuid.Parse(sm.GetString(r.Context(), SessionKeyUserID)); perr == nil {
+				err := AuthorizeTenant(r.Context(), authorize, userID, tenantID)
+				if errors.Is(err, ErrNotTenantMember) {
+					writeTenantError(w, http.StatusForbidden, "No access", "Your account does not have access to this tenant.", err)
+					return
+				}
+				if err != nil {
+					log.Printf("tenant authorizer error: %v", err)
+					http.Error(w, "Could not check tenant access.", http.StatusInternalServerError)
+					return
+				}
+			}
 			ctx := WithTenant(r.Context(), tenantID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// NewTenantResolver returns the built-in resolver named by kind: "header",
+// "subdomain" or "path". header names the request header for the "header"
+// resolver and defaults to X-Tenant-ID when empty. The database-backed "slug"
+// resolver is created with NewSlugTenantResolver instead.
+func NewTenantResolver(kind, header string) (TenantResolver, error) {
+	switch kind {
+	case "header":
+		return HeaderTenantResolver{Header: header}, nil
+	case "subdomain":
+		return SubdomainTenantResolver{}, nil
+	case "path":
+		return PathTenantResolver{}, nil
+	default:
+		return nil, fmt.Errorf("unknown tenant resolver %q (want header, subdomain or path)", kind)
+	}
+}
+
+// writeTenantError writes a minimal HTML error page with status. reason is
+// the resolver's or authorizer's error message.
+func writeTenantError(w http.ResponseWriter, status int, title, message string, reason error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>%[1]s</title></head>
+<body>
+<h1>%[1]s</h1>
+<p>%[2]s</p>
+<p style="color:gray">%[3]s</p>
+</body>
+</html>`, html.EscapeString(title), html.EscapeString(message), html.EscapeString(reason.Error()))
+}
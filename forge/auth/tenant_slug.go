@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alternayte/forge/forge/notify"
+)
+
+// Tenant statuses stored in the tenants.status column.
+const (
+	TenantStatusActive    = "active"
+	TenantStatusSuspended = "suspended"
+)
+
+// TenantsChannel is the notify channel on which tenant changes are published.
+// Events are published with uuid.Nil as the tenant so every instance's
+// SlugTenantResolver receives them.
+const TenantsChannel = "tenants"
+
+// ErrTenantSuspended is returned by SlugTenantResolver for tenants whose status
+// is "suspended". The tenant middlewares respond with 403 Forbidden for it.
+var ErrTenantSuspended = errors.New("tenant is suspended")
+
+// defaultTenantCacheTTL bounds how long a cached lookup is trusted when no
+// invalidation arrives, e.g. when no notify hub is wired.
+const defaultTenantCacheTTL = 5 * time.Minute
+
+// maxTenantCacheEntries bounds the lookup cache. Clients choose the Host
+// header, so unknown hosts must not grow it without limit.
+const maxTenantCacheEntries = 10000
+
+// SlugTenantResolver maps the request host to a tenant using the generated
+// tenants table. A host listed in a tenant's domains (e.g. "app.acme.com")
+// wins; otherwise a host directly under the base domain is matched against
+// the tenant slug (e.g. "acme" for "acme.example.com" with base domain
+// "example.com"). Without a base domain only custom domains match.
+//
+// Lookups are cached per host, including hosts that match no tenant, up to
+// maxTenantCacheEntries. Call Listen with a notify hub so changes published
+// via PublishTenantChange clear the cache on every instance.
+type SlugTenantResolver struct {
+	// lookup finds the tenant for host, by custom domain or slug ("" when
+	// the host names none). It returns pgx.ErrNoRows when no tenant matches.
+	lookup     func(ctx context.Context, host, slug string) (cachedTenant, error)
+	baseDomain string
+	ttl        time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cachedTenant
+}
+
+// cachedTenant is a resolved host kept until expires or the next invalidation.
+// A zero id records a host that matches no tenant.
+type cachedTenant struct {
+	id      uuid.UUID
+	status  string
+	expires time.Time
+}
+
+// NewSlugTenantResolver returns a SlugTenantResolver that reads the tenants
+// table through pool and matches slugs on subdomains of baseDomain (e.g.
+// "example.com"). An empty baseDomain matches custom domains only.
+func NewSlugTenantResolver(pool *pgxpool.Pool, baseDomain string) *SlugTenantResolver {
+	return &SlugTenantResolver{
+		lookup: func(ctx context.Context, host, slug string) (cachedTenant, error) {
+			var t cachedTenant
+			err := pool.QueryRow(ctx, lookupTenantSQL, host, slug).Scan(&t.id, &t.status)
+			return t, err
+		},
+		baseDomain: strings.Trim(strings.ToLower(baseDomain), "."),
+		ttl:        defaultTenantCacheTTL,
+		cache:      make(map[string]cachedTenant),
+	}
+}
+
+// lookupTenantSQL matches a custom domain first, then the slug when one is
+// given.
+const lookupTenantSQL = `
+SELECT id, status FROM tenants
+WHERE $1 = ANY(domains) OR ($2 <> '' AND slug = $2)
+ORDER BY $1 = ANY(domains) DESC
+LIMIT 1`
+
+// Resolve returns the ID of the tenant for the request host. It returns
+// ErrTenantSuspended (wrapped) for suspended tenants.
+func (s *SlugTenantResolver) Resolve(r *http.Request) (uuid.UUID, error) {
+	host := strings.ToLower(r.Host)
+	// Strip port if present.
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return uuid.Nil, fmt.Errorf("no host in request")
+	}
+
+	t, ok := s.cached(host)
+	if !ok {
+		var err error
+		t, err = s.lookup(r.Context(), host, s.slug(host))
+		if errors.Is(err, pgx.ErrNoRows) {
+			t = cachedTenant{}
+		} else if err != nil {
+			return uuid.Nil, fmt.Errorf("look up tenant for host %q: %w", host, err)
+		}
+		s.store(host, t)
+	}
+
+	if t.id == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("no tenant for host %q", host)
+	}
+	if t.status == TenantStatusSuspended {
+		return uuid.Nil, fmt.Errorf("host %q: %w", host, ErrTenantSuspended)
+	}
+	return t.id, nil
+}
+
+// slug returns the tenant slug named by host: its label directly under the
+// base domain, or "" for any other host.
+func (s *SlugTenantResolver) slug(host string) string {
+	if s.baseDomain == "" {
+		return ""
+	}
+	slug, ok := strings.CutSuffix(host, "."+s.baseDomain)
+	if !ok || slug == "" || strings.Contains(slug, ".") {
+		return ""
+	}
+	return slug
+}
+
+func (s *SlugTenantResolver) cached(host string) (cachedTenant, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.cache[host]
+	if !ok || time.Now().After(t.expires) {
+		return cachedTenant{}, false
+	}
+	return t, true
+}
+
+// store caches t for host. A full cache first drops its expired entries, and
+// is cleared when none have expired.
+func (s *SlugTenantResolver) store(host string, t cachedTenant) {
+	now := time.Now()
+	t.expires = now.Add(s.ttl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cache) >= maxTenantCacheEntries {
+		for h, cached := range s.cache {
+			if now.After(cached.expires) {
+				delete(s.cache, h)
+			}
+		}
+		if len(s.cache) >= maxTenantCacheEntries {
+			clear(s.cache)
+		}
+	}
+	s.cache[host] = t
+}
+
+// Invalidate clears every cached lookup. Listen calls it for each tenant
+// change; call it directly after changing tenants without a notify hub.
+func (s *SlugTenantResolver) Invalidate() {
+	s.mu.Lock()
+	clear(s.cache)
+	s.mu.Unlock()
+}
+
+// Listen clears the cache whenever a change is published on TenantsChannel.
+// It blocks until ctx is cancelled, so run it in its own goroutine. The hub
+// must be started separately.
+func (s *SlugTenantResolver) Listen(ctx context.Context, hub notify.NotifyHub) {
+	sub := hub.Subscribe(TenantsChannel, uuid.Nil)
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			s.Invalidate()
+		}
+	}
+}
+
+// PublishTenantChange notifies every SlugTenantResolver listening on hub that
+// the tenant with id changed (renamed slug, new domain, suspended, ...). Call
+// it after committing the change.
+func PublishTenantChange(ctx context.Context, hub notify.NotifyHub, id uuid.UUID) error {
+	payload, err := json.Marshal(map[string]string{"id": id.String()})
+	if err != nil {
+		return err
+	}
+	return hub.Publish(ctx, TenantsChannel, uuid.Nil, payload)
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeTenantLookup serves tenants by slug and records every lookup.
+type fakeTenantLookup struct {
+	tenants map[string]uuid.UUID
+	calls   []tenantLookup
+}
+
+type tenantLookup struct{ host, slug string }
+
+func (f *fakeTenantLookup) lookup(_ context.Context, host, slug string) (cachedTenant, error) {
+	f.calls = append(f.calls, tenantLookup{host, slug})
+	id, ok := f.tenants[slug]
+	if !ok {
+		return cachedTenant{}, pgx.ErrNoRows
+	}
+	return cachedTenant{id: id, status: TenantStatusActive}, nil
+}
+
+func newTestSlugResolver(baseDomain string, f *fakeTenantLookup) *SlugTenantResolver {
+	s := NewSlugTenantResolver(nil, baseDomain)
+	s.lookup = f.lookup
+	return s
+}
+
+func TestSlugTenantResolver_Slug(t *testing.T) {
+	acme := uuid.New()
+	f := &fakeTenantLookup{tenants: map[string]uuid.UUID{"acme": acme}}
+	s := newTestSlugResolver("Example.com.", f)
+
+	tests := []struct {
+		host     string
+		wantSlug string
+		wantID   uuid.UUID
+	}{
+		{"acme.example.com", "acme", acme},
+		{"ACME.example.com:8080", "acme", acme},
+		// Hosts outside the base domain never match a slug
+		{"acme.other.com", "", uuid.Nil},
+		{"acme.notexample.com", "", uuid.Nil},
+		{"acme", "", uuid.Nil},
+		// Only a single label directly under the base domain is a slug
+		{"www.acme.example.com", "", uuid.Nil},
+		{"example.com", "", uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			s.Invalidate()
+			f.calls = nil
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			id, err := s.Resolve(r)
+			if id != tt.wantID || (err == nil) != (tt.wantID != uuid.Nil) {
+				t.Errorf("Resolve = %v, %v, want %v", id, err, tt.wantID)
+			}
+			if len(f.calls) != 1 || f.calls[0].slug != tt.wantSlug {
+				t.Errorf("lookups = %v, want one with slug %q", f.calls, tt.wantSlug)
+			}
+		})
+	}
+
+	// Without a base domain only custom domains are looked up
+	f.calls = nil
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = "acme.example.com"
+	if _, err := newTestSlugResolver("", f).Resolve(r); err == nil {
+		t.Error("Resolve without a base domain matched a slug")
+	}
+	if want := (tenantLookup{"acme.example.com", ""}); len(f.calls) != 1 || f.calls[0] != want {
+		t.Errorf("lookups = %v, want %v", f.calls, want)
+	}
+}
+
+func TestSlugTenantResolver_CachesUnknownHosts(t *testing.T) {
+	f := &fakeTenantLookup{}
+	s := newTestSlugResolver("example.com", f)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = "nobody.example.com"
+	for i := 0; i < 3; i++ {
+		if _, err := s.Resolve(r); err == nil {
+			t.Fatalf("Resolve %d: want an error for an unknown host", i)
+		}
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("lookups = %d, want 1: unknown hosts are cached", len(f.calls))
+	}
+
+	// A new tenant is found once the cache is invalidated
+	id := uuid.New()
+	f.tenants = map[string]uuid.UUID{"nobody": id}
+	s.Invalidate()
+	if got, err := s.Resolve(r); err != nil || got != id {
+		t.Errorf("Resolve after Invalidate = %v, %v, want %v", got, err, id)
+	}
+}
+
+func TestSlugTenantResolver_CacheBound(t *testing.T) {
+	s := newTestSlugResolver("example.com", &fakeTenantLookup{})
+	fill := func(n int, ttl time.Duration, prefix string) {
+		s.ttl = ttl
+		for i := 0; i < n; i++ {
+			s.store(fmt.Sprintf("%s%d.example.com", prefix, i), cachedTenant{})
+		}
+	}
+
+	// A full cache drops its expired entries and keeps the live ones
+	fill(maxTenantCacheEntries/2, -time.Second, "expired")
+	fill(maxTenantCacheEntries/2, time.Minute, "live")
+	s.store("new.example.com", cachedTenant{})
+	if got, want := len(s.cache), maxTenantCacheEntries/2+1; got != want {
+		t.Errorf("cache size after evicting expired entries = %d, want %d", got, want)
+	}
+	if _, ok := s.cached("live0.example.com"); !ok {
+		t.Error("live entry was evicted")
+	}
+
+	// A cache full of live entries is cleared
+	s.Invalidate()
+	fill(maxTenantCacheEntries, time.Minute, "live")
+	s.store("new.example.com", cachedTenant{})
+	if got := len(s.cache); got != 1 {
+		t.Errorf("cache size after clearing = %d, want 1", got)
+	}
+	if _, ok := s.cached("new.example.com"); !ok {
+		t.Error("new entry was not cached")
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"time"
 
@@ -11,11 +12,15 @@ import (
 
 // Token represents a bearer token stored in the database.
 type Token struct {
-	ID        uuid.UUID
-	Token     string
-	UserID    uuid.UUID
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ID    uuid.UUID
+	Token string // plaintext; stores that keep only TokenHash set it on Create alone
+	// TokenHash is HashSecret(Token). When set, AuthMiddleware compares the
+	// presented token against it instead of Token.
+	TokenHash  string
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time // nullable
+	CreatedAt  time.Time
 }
 
 // TokenStore defines the interface for bearer token persistence.
@@ -29,6 +34,38 @@ type TokenStore interface {
 	Delete(ctx context.Context, tokenID uuid.UUID) error
 }
 
+// UsageTracker is implemented by TokenStore and APIKeyStore implementations
+// that record when a credential was last used. AuthMiddleware calls MarkUsed
+// with the token or key ID after the credential validates.
+type UsageTracker interface {
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+}
+
+// secretLookupLen is the number of leading characters of a token or key (after
+// any API key prefix) that SecretLookup keeps.
+const secretLookupLen = 8
+
+// HashSecret returns the hex-encoded SHA-256 digest of a bearer token or API
+// key. Stores persist the digest rather than the secret, so a leaked table does
+// not leak usable credentials.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// SecretLookup returns the short, non-secret lookup prefix of a bearer token or
+// API key: its API key prefix, if any, plus the next 8 characters, e.g.
+// "forg_live_a1b2c3d4". Stores keep it in clear text to identify keys in
+// listings and narrow lookups.
+func SecretLookup(secret string) string {
+	prefix, _ := ValidateKeyPrefix(secret)
+	rest := secret[len(prefix):]
+	if len(rest) > secretLookupLen {
+		rest = rest[:secretLookupLen]
+	}
+	return prefix + rest
+}
+
 // GenerateToken generates a cryptographically random 64-character hex string
 // (32 random bytes hex-encoded) suitable for use as a bearer token.
 func GenerateToken() (string, error) {
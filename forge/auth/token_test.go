@@ -0,0 +1,39 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+func TestHashSecret(t *testing.T) {
+	// Stores compare against persisted digests, so the encoding must not change
+	const want = "301efcc199798d20a0b90324c91df70a6cd2d1f506edf2d35f088f46f6b125b2"
+	if got := auth.HashSecret("forg_live_secret"); got != want {
+		t.Errorf("HashSecret = %q, want the hex SHA-256 %q", got, want)
+	}
+	if auth.HashSecret("forg_live_secret") == auth.HashSecret("forg_live_secreT") {
+		t.Error("HashSecret collides for different secrets")
+	}
+}
+
+func TestSecretLookup(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{auth.PrefixLive + "a1b2c3d4e5f6a1b2c3d4e5f6", auth.PrefixLive + "a1b2c3d4"},
+		{auth.PrefixTest + "a1b2c3d4e5f6a1b2c3d4e5f6", auth.PrefixTest + "a1b2c3d4"},
+		// Bearer tokens have no prefix
+		{"0123456789abcdef0123456789abcdef", "01234567"},
+		// Short secrets are kept whole
+		{auth.PrefixLive + "abc", auth.PrefixLive + "abc"},
+		{"abc", "abc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := auth.SecretLookup(tt.secret); got != tt.want {
+			t.Errorf("SecretLookup(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/google/uuid"
+)
+
+// TOTP parameters understood by every authenticator app: RFC 6238 with
+// HMAC-SHA1, six digits and a 30-second step.
+const (
+	totpDigits = 6
+	totpPeriod = 30
+)
+
+// Session keys of a sign-in that passed the password check and waits for a
+// second factor. The user ID is only moved to SessionKeyUserID once the code
+// is verified.
+const (
+	sessionKeyPendingUserID = "2fa_pending_user_id"
+	sessionKeyPendingEmail  = "2fa_pending_email"
+	sessionKeyPendingAt     = "2fa_pending_at" // Unix seconds
+)
+
+var (
+	// ErrTwoFactorNotEnrolled is returned by TwoFactorStore.GetTwoFactor for
+	// users without a TOTP secret.
+	ErrTwoFactorNotEnrolled = errors.New("auth: two-factor authentication not enrolled")
+	// ErrInvalidTwoFactorCode is returned for a wrong, reused or expired TOTP
+	// code or an unknown recovery code.
+	ErrInvalidTwoFactorCode = errors.New("auth: invalid two-factor code")
+)
+
+// TwoFactorState is a user's TOTP enrolment.
+type TwoFactorState struct {
+	// Secret is the base32 TOTP secret shared with the authenticator app.
+	Secret string
+	// EnabledAt is nil until the user confirms enrolment with a code.
+	EnabledAt *time.Time
+	// RecoveryCodesLeft counts the unused recovery codes.
+	RecoveryCodesLeft int
+}
+
+// TwoFactorStore persists TOTP secrets and recovery codes.
+// pgstore.TwoFactorStore uses the generated user_totp and recovery_codes
+// tables.
+type TwoFactorStore interface {
+	// GetTwoFactor returns the user's enrolment, or ErrTwoFactorNotEnrolled.
+	GetTwoFactor(ctx context.Context, userID string) (*TwoFactorState, error)
+	// SaveTOTPSecret stores an unconfirmed secret for the user. It replaces
+	// an unconfirmed secret but never an enabled one.
+	SaveTOTPSecret(ctx context.Context, userID, secret string) error
+	// EnableTwoFactor confirms the user's secret and replaces their recovery
+	// codes with the given SHA-256 hashes.
+	EnableTwoFactor(ctx context.Context, userID string, recoveryCodeHashes []string) error
+	// UseTOTPStep records that the code of a time step was accepted, returning
+	// ErrInvalidTwoFactorCode unless step is later than the last one used, so
+	// a code works only once.
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	// UseRecoveryCode deletes the recovery code with the given hash, returning
+	// ErrInvalidTwoFactorCode if the user has no such code.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+	// DisableTwoFactor deletes the user's secret and recovery codes.
+	DisableTwoFactor(ctx context.Context, userID string) error
+}
+
+// TwoFactorConfig configures TwoFactor.
+type TwoFactorConfig struct {
+	Store TwoFactorStore
+	// Issuer names the app in authenticator apps. Defaults to "forge".
+	Issuer string
+	// RequiredRoles lists roles that must use two-factor authentication, e.g.
+	// "admin". Users holding one are made to enrol before their first
+	// password sign-in completes, and cannot turn 2FA off.
+	RequiredRoles []string
+	// Roles loads the roles checked against RequiredRoles.
+	Roles RoleResolver
+	// Limiter, when set, throttles wrong codes like wrong passwords.
+	Limiter *LoginLimiter
+	// RecoveryCodes is how many recovery codes enrolment issues. Default: 10.
+	RecoveryCodes int
+	// PendingTTL bounds how long a sign-in waits for its second factor.
+	// Default: 5 minutes.
+	PendingTTL time.Duration
+	// Now returns the current time. Tests set it to a fake clock. Default:
+	// time.Now.
+	Now func() time.Time
+}
+
+// TwoFactor adds TOTP two-factor authentication to password sign-in. Users
+// enrol at /auth/2fa/setup by scanning a provisioning URI into an
+// authenticator app and confirming a code, and receive one-time recovery
+// codes for when the app is lost. A password sign-in of an enrolled user then
+// leaves the session in a pending state until /auth/2fa accepts a code.
+// Only SHA-256 hashes of recovery codes are stored.
+type TwoFactor struct {
+	sm  *scs.SessionManager
+	cfg TwoFactorConfig
+}
+
+// NewTwoFactor validates cfg, applies defaults and returns a TwoFactor using
+// sm for pending sign-ins.
+func NewTwoFactor(sm *scs.SessionManager, cfg TwoFactorConfig) (*TwoFactor, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("two_factor: store is required")
+	}
+	if len(cfg.RequiredRoles) > 0 && cfg.Roles == nil {
+		return nil, errors.New("two_factor: required roles need a role resolver")
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = "forge"
+	}
+	if cfg.RecoveryCodes <= 0 {
+		cfg.RecoveryCodes = 10
+	}
+	if cfg.PendingTTL <= 0 {
+		cfg.PendingTTL = 5 * time.Minute
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &TwoFactor{sm: sm, cfg: cfg}, nil
+}
+
+// totpEncoding encodes TOTP secrets the way authenticator apps expect them.
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret returns a random 160-bit secret, the size RFC 4226
+// recommends.
+func newTOTPSecret() string {
+	key := make([]byte, 20)
+	rand.Read(key)
+	return totpEncoding.EncodeToString(key)
+}
+
+// TOTPCode returns the code an authenticator app shows for secret at t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return totpCode(secret, t.Unix()/totpPeriod)
+}
+
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decode TOTP secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1_000_000), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI that enrols secret for account
+// in an authenticator app. Render it as a QR code to be scanned.
+func (t *TwoFactor) ProvisioningURI(secret, account string) string {
+	q := url.Values{
+		"secret":    {secret},
+		"issuer":    {t.cfg.Issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	return "otpauth://totp/" + url.PathEscape(t.cfg.Issuer+":"+account) + "?" + q.Encode()
+}
+
+// Enrolled reports whether userID has confirmed a TOTP secret.
+func (t *TwoFactor) Enrolled(ctx context.Context, userID string) (bool, error) {
+	state, err := t.cfg.Store.GetTwoFactor(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return state.EnabledAt != nil, nil
+}
+
+// Required reports whether a role of userID is listed in RequiredRoles.
+func (t *TwoFactor) Required(ctx context.Context, userID string) (bool, error) {
+	if len(t.cfg.RequiredRoles) == 0 {
+		return false, nil
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return false, nil
+	}
+	roles, err := t.cfg.Roles(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if slices.Contains(t.cfg.RequiredRoles, role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Verify checks a TOTP code, or else a recovery code, of an enrolled user.
+// Each accepted code is used up. Codes from the previous and next time step
+// are accepted to allow for clock drift.
+func (t *TwoFactor) Verify(ctx context.Context, userID, code string) error {
+	state, err := t.cfg.Store.GetTwoFactor(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotEnrolled) || (err == nil && state.EnabledAt == nil) {
+		return ErrInvalidTwoFactorCode
+	}
+	if err != nil {
+		return err
+	}
+	code = normalizeCode(code)
+	if len(code) != totpDigits {
+		return t.cfg.Store.UseRecoveryCode(ctx, userID, HashSecret(code))
+	}
+	step, ok, err := t.matchStep(state.Secret, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return t.cfg.Store.UseTOTPStep(ctx, userID, step)
+}
+
+// matchStep finds the time step, within one step of now, whose code is code.
+func (t *TwoFactor) matchStep(secret, code string) (int64, bool, error) {
+	now := t.cfg.Now().Unix() / totpPeriod
+	for _, step := range []int64{now, now - 1, now + 1} {
+		want, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// normalizeCode strips the spaces and dashes users type into codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// newRecoveryCodes returns fresh recovery codes formatted as "xxxxx-xxxxx"
+// and their hashes.
+func (t *TwoFactor) newRecoveryCodes() (codes, hashes []string) {
+	for range t.cfg.RecoveryCodes {
+		code := strings.ToLower(rand.Text()[:10])
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, HashSecret(code))
+	}
+	return codes, hashes
+}
+
+// challenge returns where a password sign-in of userID must continue before
+// it completes: /auth/2fa for enrolled users, /auth/2fa/setup for users whose
+// role requires 2FA, or "" when no second factor is needed.
+func (t *TwoFactor) challenge(ctx context.Context, userID string) (string, error) {
+	enrolled, err := t.Enrolled(ctx, userID)
+	if err != nil || enrolled {
+		return "/auth/2fa", err
+	}
+	required, err := t.Required(ctx, userID)
+	if err != nil || required {
+		return "/auth/2fa/setup", err
+	}
+	return "", nil
+}
+
+// startPending records a sign-in that awaits its second factor. The token is
+// renewed as at sign-in.
+func (t *TwoFactor) startPending(r *http.Request, userID, email string) error {
+	ctx := r.Context()
+	if err := t.sm.RenewToken(ctx); err != nil {
+		return err
+	}
+	t.sm.Put(ctx, sessionKeyPendingUserID, userID)
+	t.sm.Put(ctx, sessionKeyPendingEmail, email)
+	t.sm.Put(ctx, sessionKeyPendingAt, t.cfg.Now().Unix())
+	return nil
+}
+
+// pending returns the user ID and email of an unexpired pending sign-in.
+func (t *TwoFactor) pending(r *http.Request) (userID, email string) {
+	ctx := r.Context()
+	userID = t.sm.GetString(ctx, sessionKeyPendingUserID)
+	if userID == "" {
+		return "", ""
+	}
+	if t.cfg.Now().Sub(time.Unix(t.sm.GetInt64(ctx, sessionKeyPendingAt), 0)) > t.cfg.PendingTTL {
+		t.clearPending(r)
+		return "", ""
+	}
+	return userID, t.sm.GetString(ctx, sessionKeyPendingEmail)
+}
+
+func (t *TwoFactor) clearPending(r *http.Request) {
+	t.sm.Remove(r.Context(), sessionKeyPendingUserID)
+	t.sm.Remove(r.Context(), sessionKeyPendingEmail)
+	t.sm.Remove(r.Context(), sessionKeyPendingAt)
+}
+
+// completePending signs in the pending user.
+func (t *TwoFactor) completePending(r *http.Request, userID, email string) error {
+	t.clearPending(r)
+	if t.cfg.Limiter != nil {
+		if err := t.cfg.Limiter.Succeed(r.Context(), email); err != nil {
+			log.Printf("login limiter error: %v", err)
+		}
+	}
+	return LoginUser(t.sm, r, userID, email)
+}
+
+// HandleChallenge returns an http.HandlerFunc for GET and POST /auth/2fa,
+// where a pending password sign-in enters a TOTP or recovery code. Without a
+// pending sign-in it redirects to the login page.
+func (t *TwoFactor) HandleChallenge(pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, email := t.pending(r)
+		if userID == "" {
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			renderPage(w, r, http.StatusOK, pages.TwoFactor(PageData{}))
+			return
+		}
+
+		if t.cfg.Limiter != nil {
+			var throttle *ThrottleError
+			if err := t.cfg.Limiter.Allow(r, email); errors.As(err, &throttle) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(throttle.RetryAfter.Seconds())))
+				renderPage(w, r, http.StatusTooManyRequests, pages.TwoFactor(PageData{
+					Error: "Too many failed attempts. Try again in " + formatTTL(throttle.RetryAfter) + ".",
+				}))
+				return
+			} else if err != nil {
+				log.Printf("login limiter error: %v", err)
+			}
+		}
+
+		err := t.Verify(r.Context(), userID, r.PostFormValue("code"))
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if t.cfg.Limiter != nil {
+				if err := t.cfg.Limiter.Fail(r, email); err != nil {
+					log.Printf("login limiter error: %v", err)
+				}
+			}
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.TwoFactor(PageData{Error: "That code is not valid. Try again."}))
+			return
+		}
+		if err != nil {
+			log.Printf("two-factor verify error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		if err := t.completePending(r, userID, email); err != nil {
+			log.Printf("login session error: %v", err)
+			http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// HandleSetup returns an http.HandlerFunc for GET and POST /auth/2fa/setup.
+// GET shows a new secret and its provisioning URI, or the 2FA status of an
+// enrolled user. POST with a code from the authenticator app enables 2FA and
+// shows the recovery codes once. It serves signed-in users and pending
+// sign-ins whose role requires 2FA; the latter are signed in once enrolled.
+func (t *TwoFactor) HandleSetup(pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, email := GetSessionUserID(t.sm, r), GetSessionUserEmail(t.sm, r)
+		pendingID, pendingEmail := t.pending(r)
+		if userID == "" {
+			userID, email = pendingID, pendingEmail
+		}
+		if userID == "" {
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+			return
+		}
+
+		required, err := t.Required(ctx, userID)
+		if err != nil {
+			log.Printf("two-factor roles error: %v", err)
+			http.Error(w, "Could not load two-factor settings. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		state, err := t.cfg.Store.GetTwoFactor(ctx, userID)
+		if err != nil && !errors.Is(err, ErrTwoFactorNotEnrolled) {
+			log.Printf("two-factor store error: %v", err)
+			http.Error(w, "Could not load two-factor settings. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		data := PageData{Email: email, TwoFactorRequired: required, Notice: t.sm.PopString(ctx, sessionKeyFlash)}
+		if state != nil && state.EnabledAt != nil {
+			data.TwoFactorEnabled = true
+			data.RecoveryCodesLeft = state.RecoveryCodesLeft
+			renderPage(w, r, http.StatusOK, pages.TwoFactorSetup(data))
+			return
+		}
+
+		// Keep the unconfirmed secret across reloads so a scanned code stays
+		// valid until it is confirmed.
+		if state == nil {
+			state = &TwoFactorState{Secret: newTOTPSecret()}
+			if err := t.cfg.Store.SaveTOTPSecret(ctx, userID, state.Secret); err != nil {
+				log.Printf("two-factor store error: %v", err)
+				http.Error(w, "Could not start two-factor setup. Please try again.", http.StatusInternalServerError)
+				return
+			}
+		}
+		data.TOTPSecret = state.Secret
+		data.TOTPURI = t.ProvisioningURI(state.Secret, email)
+		if required && userID == pendingID {
+			data.Notice = "Your account requires two-factor authentication. Set it up to finish signing in."
+		}
+		if r.Method != http.MethodPost {
+			renderPage(w, r, http.StatusOK, pages.TwoFactorSetup(data))
+			return
+		}
+
+		step, ok, err := t.matchStep(state.Secret, normalizeCode(r.PostFormValue("code")))
+		if err == nil && ok {
+			err = t.cfg.Store.UseTOTPStep(ctx, userID, step)
+		}
+		if errors.Is(err, ErrInvalidTwoFactorCode) || (err == nil && !ok) {
+			data.Error = "That code is not valid. Check your authenticator app's clock and try again."
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.TwoFactorSetup(data))
+			return
+		}
+		if err != nil {
+			log.Printf("two-factor verify error: %v", err)
+			http.Error(w, "Could not enable two-factor authentication. Please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		codes, hashes := t.newRecoveryCodes()
+		if err := t.cfg.Store.EnableTwoFactor(ctx, userID, hashes); err != nil {
+			log.Printf("two-factor store error: %v", err)
+			http.Error(w, "Could not enable two-factor authentication. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		if userID == pendingID {
+			if err := t.completePending(r, userID, email); err != nil {
+				log.Printf("login session error: %v", err)
+				http.Error(w, "Authentication failed. Please try again.", http.StatusInternalServerError)
+				return
+			}
+		}
+		renderPage(w, r, http.StatusOK, pages.TwoFactorSetup(PageData{
+			Notice:           "Two-factor authentication is on. Save these recovery codes somewhere safe: each signs you in once if you lose your authenticator app.",
+			Email:            email,
+			TwoFactorEnabled: true,
+			RecoveryCodes:    codes,
+		}))
+	}
+}
+
+// HandleDisable returns an http.HandlerFunc for POST /auth/2fa/disable, which
+// turns 2FA off for the signed-in user after checking a current code. Users
+// whose role requires 2FA cannot turn it off.
+func (t *TwoFactor) HandleDisable(pages Pages) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := GetSessionUserID(t.sm, r)
+		if userID == "" {
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+		data := PageData{Email: GetSessionUserEmail(t.sm, r), TwoFactorEnabled: true}
+
+		required, err := t.Required(ctx, userID)
+		if err == nil && required {
+			data.TwoFactorRequired = true
+			data.Error = "Your account requires two-factor authentication."
+			renderPage(w, r, http.StatusForbidden, pages.TwoFactorSetup(data))
+			return
+		}
+		if err == nil {
+			err = t.Verify(ctx, userID, r.PostFormValue("code"))
+		}
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			data.Error = "That code is not valid. Try again."
+			renderPage(w, r, http.StatusUnprocessableEntity, pages.TwoFactorSetup(data))
+			return
+		}
+		if err == nil {
+			err = t.cfg.Store.DisableTwoFactor(ctx, userID)
+		}
+		if err != nil {
+			log.Printf("two-factor disable error: %v", err)
+			http.Error(w, "Could not turn off two-factor authentication. Please try again.", http.StatusInternalServerError)
+			return
+		}
+		setFlash(t.sm, r, "Two-factor authentication is off.")
+		http.Redirect(w, r, "/auth/2fa/setup", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,324 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/forgetest"
+)
+
+// memTwoFactorStore is an in-memory auth.TwoFactorStore.
+type memTwoFactorStore struct {
+	mu    sync.Mutex
+	users map[string]*memTwoFactor
+}
+
+type memTwoFactor struct {
+	state    auth.TwoFactorState
+	lastStep int64
+	codes    map[string]bool
+}
+
+func newMemTwoFactorStore() *memTwoFactorStore {
+	return &memTwoFactorStore{users: map[string]*memTwoFactor{}}
+}
+
+func (s *memTwoFactorStore) GetTwoFactor(ctx context.Context, userID string) (*auth.TwoFactorState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, auth.ErrTwoFactorNotEnrolled
+	}
+	state := u.state
+	state.RecoveryCodesLeft = len(u.codes)
+	return &state, nil
+}
+
+func (s *memTwoFactorStore) SaveTOTPSecret(ctx context.Context, userID, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[userID]; ok && u.state.EnabledAt != nil {
+		return nil
+	}
+	s.users[userID] = &memTwoFactor{state: auth.TwoFactorState{Secret: secret}}
+	return nil
+}
+
+func (s *memTwoFactorStore) EnableTwoFactor(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return auth.ErrTwoFactorNotEnrolled
+	}
+	now := time.Now()
+	u.state.EnabledAt = &now
+	u.codes = map[string]bool{}
+	for _, h := range recoveryCodeHashes {
+		u.codes[h] = true
+	}
+	return nil
+}
+
+func (s *memTwoFactorStore) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok || step <= u.lastStep {
+		return auth.ErrInvalidTwoFactorCode
+	}
+	u.lastStep = step
+	return nil
+}
+
+func (s *memTwoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok || !u.codes[codeHash] {
+		return auth.ErrInvalidTwoFactorCode
+	}
+	delete(u.codes, codeHash)
+	return nil
+}
+
+func (s *memTwoFactorStore) DisableTwoFactor(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, userID)
+	return nil
+}
+
+// fakeClock is a settable clock for TwoFactorConfig.Now.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTwoFactorApp is newAccountsApp with two-factor authentication on a fake
+// clock. roles, when set, are granted to every user.
+func newTwoFactorApp(t *testing.T, requiredRoles []string, roles ...string) (*accountsApp, *fakeClock) {
+	t.Helper()
+
+	sm := scs.New()
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	twoFactor, err := auth.NewTwoFactor(sm, auth.TwoFactorConfig{
+		Store:         newMemTwoFactorStore(),
+		Issuer:        "Acme",
+		RequiredRoles: requiredRoles,
+		Roles:         func(context.Context, uuid.UUID) ([]string, error) { return roles, nil },
+		Now:           clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("NewTwoFactor: %v", err)
+	}
+
+	a := &accountsApp{users: newMemUserStore(), mailer: forgetest.NewMailer()}
+	accounts, err := auth.NewAccounts(auth.AccountsConfig{Users: a.users, Mailer: a.mailer, BaseURL: testBaseURL})
+	if err != nil {
+		t.Fatalf("NewAccounts: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(sm.LoadAndSave)
+	auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{Accounts: accounts, TwoFactor: twoFactor})
+	r.With(auth.SessionUser(sm, nil, nil)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.UserFromContext(r.Context()).String()))
+	})
+	a.server = forgetest.NewApp(t, r)
+	return a, clock
+}
+
+var (
+	totpSecretPattern   = regexp.MustCompile(`Key: <code>([A-Z2-7]+)</code>`)
+	recoveryCodePattern = regexp.MustCompile(`<li><code>([a-z2-7]{5}-[a-z2-7]{5})</code></li>`)
+)
+
+// enrol turns on 2FA from the setup page and returns the TOTP secret and
+// recovery codes.
+func (b *browser) enrol(t *testing.T, clock *fakeClock) (string, []string) {
+	t.Helper()
+	_, body := b.do(t, http.MethodGet, "/auth/2fa/setup", nil)
+	m := totpSecretPattern.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("setup page shows no secret: %q", body)
+	}
+	if !strings.Contains(body, "otpauth://totp/Acme:ada@example.com?") {
+		t.Errorf("setup page shows no provisioning URI: %q", body)
+	}
+	code, _ := auth.TOTPCode(m[1], clock.Now())
+	_, body = b.do(t, http.MethodPost, "/auth/2fa/setup", url.Values{"code": {code}})
+	var codes []string
+	for _, c := range recoveryCodePattern.FindAllStringSubmatch(body, -1) {
+		codes = append(codes, c[1])
+	}
+	if len(codes) != 10 {
+		t.Fatalf("enrolment showed %d recovery codes: %q", len(codes), body)
+	}
+	return m[1], codes
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got, err := auth.TOTPCode(secret, time.Unix(tt.unix, 0)); err != nil || got != tt.want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", tt.unix, got, err, tt.want)
+		}
+	}
+}
+
+func TestTwoFactor_EnrolAndSignIn(t *testing.T) {
+	app, clock := newTwoFactorApp(t, nil)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+	secret, _ := b.enrol(t, clock)
+
+	// The password alone leaves the sign-in pending.
+	other := app.browser()
+	if _, body := other.login(t, "ada@example.com", "correct horse"); !strings.Contains(body, `action="/auth/2fa"`) {
+		t.Fatalf("login did not ask for a code: %q", body)
+	}
+	if other.userID(t) != uuid.Nil.String() {
+		t.Fatal("signed in before entering a code")
+	}
+
+	if status, _ := other.do(t, http.MethodPost, "/auth/2fa", url.Values{"code": {"000000"}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("wrong code status = %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	// The code used at enrolment cannot be replayed.
+	code, _ := auth.TOTPCode(secret, clock.Now())
+	if status, _ := other.do(t, http.MethodPost, "/auth/2fa", url.Values{"code": {code}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("replayed code status = %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+
+	clock.Advance(30 * time.Second)
+	code, _ = auth.TOTPCode(secret, clock.Now())
+	other.do(t, http.MethodPost, "/auth/2fa", url.Values{"code": {code[:3] + " " + code[3:]}})
+	if other.userID(t) != userID {
+		t.Error("valid code did not complete the sign-in")
+	}
+}
+
+func TestTwoFactor_RecoveryCodes(t *testing.T) {
+	app, clock := newTwoFactorApp(t, nil)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+	_, codes := b.enrol(t, clock)
+
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+	other.do(t, http.MethodPost, "/auth/2fa", url.Values{"code": {strings.ToUpper(codes[0])}})
+	if other.userID(t) != userID {
+		t.Fatal("recovery code did not complete the sign-in")
+	}
+
+	// Each recovery code works once.
+	third := app.browser()
+	third.login(t, "ada@example.com", "correct horse")
+	if status, _ := third.do(t, http.MethodPost, "/auth/2fa", url.Values{"code": {codes[0]}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("reused recovery code status = %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	if _, body := b.do(t, http.MethodGet, "/auth/2fa/setup", nil); !strings.Contains(body, "9 recovery codes left") {
+		t.Errorf("setup page does not count used codes: %q", body)
+	}
+}
+
+func TestTwoFactor_PendingExpires(t *testing.T) {
+	app, clock := newTwoFactorApp(t, nil)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	secret, _ := b.enrol(t, clock)
+
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+	clock.Advance(6 * time.Minute)
+	code, _ := auth.TOTPCode(secret, clock.Now())
+	if _, body := other.do(t, http.MethodPost, "/auth/2fa", url.Values{"code": {code}}); !strings.Contains(body, `action="/auth/login"`) {
+		t.Errorf("expired pending sign-in was not sent back to login: %q", body)
+	}
+	if other.userID(t) != uuid.Nil.String() {
+		t.Error("expired pending sign-in completed")
+	}
+}
+
+func TestTwoFactor_Disable(t *testing.T) {
+	app, clock := newTwoFactorApp(t, nil)
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+	secret, _ := b.enrol(t, clock)
+
+	clock.Advance(30 * time.Second)
+	code, _ := auth.TOTPCode(secret, clock.Now())
+	if _, body := b.do(t, http.MethodPost, "/auth/2fa/disable", url.Values{"code": {code}}); !strings.Contains(body, "Two-factor authentication is off") {
+		t.Fatalf("disable: %q", body)
+	}
+
+	other := app.browser()
+	other.login(t, "ada@example.com", "correct horse")
+	if other.userID(t) != userID {
+		t.Error("password sign-in still asks for a code after 2FA was turned off")
+	}
+}
+
+func TestTwoFactor_RequiredRole(t *testing.T) {
+	app, clock := newTwoFactorApp(t, []string{"admin"}, "admin")
+	b := app.browser()
+	b.do(t, http.MethodPost, "/auth/signup", signupForm("ada@example.com", "correct horse"))
+	userID := b.userID(t)
+
+	// An admin without 2FA must enrol before the sign-in completes.
+	other := app.browser()
+	if _, body := other.login(t, "ada@example.com", "correct horse"); !strings.Contains(body, "requires two-factor authentication") {
+		t.Fatalf("login did not require enrolment: %q", body)
+	}
+	if other.userID(t) != uuid.Nil.String() {
+		t.Fatal("signed in before enrolling")
+	}
+	secret, _ := other.enrol(t, clock)
+	if other.userID(t) != userID {
+		t.Fatal("enrolling did not complete the sign-in")
+	}
+
+	clock.Advance(30 * time.Second)
+	code, _ := auth.TOTPCode(secret, clock.Now())
+	if status, _ := other.do(t, http.MethodPost, "/auth/2fa/disable", url.Values{"code": {code}}); status != http.StatusForbidden {
+		t.Errorf("disabling required 2FA status = %d, want %d", status, http.StatusForbidden)
+	}
+}
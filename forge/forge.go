@@ -14,10 +14,15 @@ import (
 	"github.com/alexedwards/scs/v2"
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/alternayte/forge/forge/audit"
 	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/auth/pgstore"
+	"github.com/alternayte/forge/forge/notify"
 	internalapi "github.com/alternayte/forge/internal/api"
+	apimiddleware "github.com/alternayte/forge/internal/api/middleware"
 	"github.com/alternayte/forge/internal/config"
 )
 
@@ -43,10 +48,28 @@ type App struct {
 	tokenStore       auth.TokenStore
 	apiKeyStore      auth.APIKeyStore
 	oauthConfig      *auth.OAuthConfig
+	oidc             *auth.OIDC
 	findOrCreateUser auth.UserFinder
 	authenticateUser auth.PasswordAuthenticator
+	useAccounts      bool
+	accounts         *auth.Accounts
+	userStore        auth.UserStore
+	mailer           auth.Mailer
+	authPages        auth.Pages
+	authEvents       auth.AuthEventRecorder
+	loginLimiter     *auth.LoginLimiter
+	sessions         *auth.Sessions
+	twoFactor        *auth.TwoFactor
+	impersonation    *auth.Impersonation
 	requireAuth      bool
 	publicRoutesFn   func(chi.Router)
+	csrfExempt       []string
+	tenantResolver   auth.TenantResolver
+	tenantAuthorizer auth.TenantAuthorizer
+	notifyHub        notify.NotifyHub
+	roleResolver     auth.RoleResolver
+	permResolver     auth.PermissionResolver
+	rbac             *auth.RBAC
 }
 
 // New creates a new App from configuration loaded via LoadConfig.
@@ -78,13 +101,15 @@ func (a *App) UseRecovery(mw func(http.Handler) http.Handler) *App {
 	return a
 }
 
-// UseTokenStore sets the bearer token store for API authentication.
+// UseTokenStore sets the bearer token store for API authentication. Without
+// it, the built-in pgstore.TokenStore is used unless [api] auth = "none".
 func (a *App) UseTokenStore(ts auth.TokenStore) *App {
 	a.tokenStore = ts
 	return a
 }
 
-// UseAPIKeyStore sets the API key store for API authentication.
+// UseAPIKeyStore sets the API key store for API authentication. Without it,
+// the built-in pgstore.APIKeyStore is used unless [api] auth = "none".
 func (a *App) UseAPIKeyStore(ks auth.APIKeyStore) *App {
 	a.apiKeyStore = ks
 	return a
@@ -105,12 +130,61 @@ func (a *App) UseOAuth(cfg auth.OAuthConfig, findOrCreateUser auth.UserFinder) *
 	return a
 }
 
+// UseOIDC enables sign-in with the OpenID Connect providers listed under
+// [[auth.oidc]] in forge.toml. findOrCreateUser receives the verified ID token
+// claims as a goth.User (Provider is the provider name, UserID the subject and
+// RawData every claim); it is shared with UseOAuth when both are used.
+func (a *App) UseOIDC(findOrCreateUser auth.UserFinder) *App {
+	a.findOrCreateUser = findOrCreateUser
+	return a
+}
+
 // UsePasswordAuth configures email/password authentication for HTML session auth.
 func (a *App) UsePasswordAuth(authenticateUser auth.PasswordAuthenticator) *App {
 	a.authenticateUser = authenticateUser
 	return a
 }
 
+// UseAccounts enables the built-in password account lifecycle on the generated
+// users table: password login plus signup, email verification, forgot and
+// reset password, and change password pages under /auth. Tune it under
+// [auth.accounts] and set the SMTP server under [mail]; without one, emails
+// are logged. [auth] base_url is required, as emailed links point to it. A
+// PasswordAuthenticator set with UsePasswordAuth still takes over password
+// login.
+func (a *App) UseAccounts() *App {
+	a.useAccounts = true
+	return a
+}
+
+// UseUserStore replaces the built-in pgstore.UserStore used by UseAccounts.
+func (a *App) UseUserStore(us auth.UserStore) *App {
+	a.userStore = us
+	return a
+}
+
+// UseMailer sets how account emails are delivered, replacing the SMTP or log
+// mailer configured under [mail].
+func (a *App) UseMailer(m auth.Mailer) *App {
+	a.mailer = m
+	return a
+}
+
+// UseAuthPages sets how the login and account pages are rendered, e.g. with
+// the generated authpages.Pages templ components. Without it, minimal
+// auth.DefaultPages are used.
+func (a *App) UseAuthPages(p auth.Pages) *App {
+	a.authPages = p
+	return a
+}
+
+// UseAuthEventRecorder sets where failed, throttled and locked-out sign-ins
+// are recorded, replacing the auth_events table.
+func (a *App) UseAuthEventRecorder(r auth.AuthEventRecorder) *App {
+	a.authEvents = r
+	return a
+}
+
 // RequireAuth enables session enforcement on HTML routes.
 // Unauthenticated users are redirected to /auth/login.
 func (a *App) RequireAuth() *App {
@@ -118,6 +192,62 @@ func (a *App) RequireAuth() *App {
 	return a
 }
 
+// UseTenantResolver sets how the tenant of each API and HTML request is identified,
+// overriding [tenant] resolver in forge.toml. Use the auth package's Header,
+// Subdomain or Path resolvers, or supply your own.
+func (a *App) UseTenantResolver(r auth.TenantResolver) *App {
+	a.tenantResolver = r
+	return a
+}
+
+// UseTenantAuthorizer sets the function that decides whether an authenticated
+// user may act within the resolved tenant. Requests from users it rejects get
+// 403 Forbidden. UseRBAC sets it to RBAC.IsTenantMember unless it is set here;
+// with tenant resolution enabled, one of the two is required.
+func (a *App) UseTenantAuthorizer(fn auth.TenantAuthorizer) *App {
+	a.tenantAuthorizer = fn
+	return a
+}
+
+// UseRoleResolver sets the function that loads the roles of the authenticated
+// user on every API and HTML request (bearer token, API key owner or session
+// user), so schema Permission rules apply. Without it, no roles are set and
+// every permission-restricted operation returns 403 Forbidden.
+func (a *App) UseRoleResolver(fn auth.RoleResolver) *App {
+	a.roleResolver = fn
+	return a
+}
+
+// UsePermissionResolver sets the function that loads the permission strings of
+// the authenticated user (e.g. "product.update") on every API and HTML request.
+// Generated Permission checks admit users holding the operation's permission
+// string in addition to those holding one of its roles.
+func (a *App) UsePermissionResolver(fn auth.PermissionResolver) *App {
+	a.permResolver = fn
+	return a
+}
+
+// UseRBAC loads roles and permissions from the generated roles and
+// role_assignments tables, replacing any role or permission resolver, and
+// admits users to the tenants they hold a role in unless UseTenantAuthorizer
+// is set. When a notify hub is set, rbac's cache is cleared on every instance
+// as roles change.
+func (a *App) UseRBAC(rbac *auth.RBAC) *App {
+	a.rbac = rbac
+	a.roleResolver = rbac.Roles
+	a.permResolver = rbac.Permissions
+	return a
+}
+
+// UseNotifyHub sets the notify hub used for cross-instance cache invalidation,
+// such as clearing the slug tenant resolver's or RBAC cache when tenants or
+// roles change.
+// The hub must be started by the caller.
+func (a *App) UseNotifyHub(hub notify.NotifyHub) *App {
+	a.notifyHub = hub
+	return a
+}
+
 // RegisterPublicRoutes sets a function that registers routes outside the
 // RequireSession middleware group. Use this for custom unauthenticated pages.
 func (a *App) RegisterPublicRoutes(fn func(chi.Router)) *App {
@@ -125,6 +255,15 @@ func (a *App) RegisterPublicRoutes(fn func(chi.Router)) *App {
 	return a
 }
 
+// ExemptFromCSRF lets POST, PUT, PATCH and DELETE requests to the given HTML
+// paths through without a CSRF token, e.g. a webhook registered with
+// RegisterPublicRoutes that verifies its own signature. A path ending in "/*"
+// exempts everything under it.
+func (a *App) ExemptFromCSRF(paths ...string) *App {
+	a.csrfExempt = append(a.csrfExempt, paths...)
+	return a
+}
+
 // Listen starts the HTTP server and blocks until SIGTERM/SIGINT.
 // On shutdown: stops accepting connections, drains in-flight requests,
 // closes the DB pool (if created internally).
@@ -144,19 +283,129 @@ func (a *App) Listen(addr string) error {
 
 	// Set up session manager.
 	// NOTE: auth.NewSessionManager already sets sm.Store = pgxstore.New(pool) internally.
-	isDev := a.cfg.Server.Host == "localhost"
-	sm := auth.NewSessionManager(a.pool, isDev)
+	sm, err := a.buildSessionManager()
+	if err != nil {
+		return err
+	}
+	a.sessions = auth.NewSessions(sm, pgstore.NewSessionIndex(a.pool))
 
 	// Set up OAuth providers if configured.
 	if a.oauthConfig != nil {
-		auth.SetupOAuth(*a.oauthConfig)
+		oauthConfig := *a.oauthConfig
+		if oauthConfig.SessionSecret == "" {
+			oauthConfig.SessionSecret = a.cfg.Session.Secret
+		}
+		auth.SetupOAuth(oauthConfig)
+	}
+	if len(a.cfg.Auth.OIDC) > 0 {
+		if a.findOrCreateUser == nil {
+			return fmt.Errorf("forge: auth.oidc: providers are configured but no user finder is set; call UseOIDC")
+		}
+		providers := make([]auth.OIDCProviderConfig, len(a.cfg.Auth.OIDC))
+		for i, p := range a.cfg.Auth.OIDC {
+			providers[i] = auth.OIDCProviderConfig{
+				Name:         p.Name,
+				DisplayName:  p.DisplayName,
+				Issuer:       p.Issuer,
+				ClientID:     p.ClientID,
+				ClientSecret: p.ClientSecret,
+				Scopes:       p.Scopes,
+				RolesClaim:   p.RolesClaim,
+				RoleMap:      p.RoleMap,
+			}
+		}
+		oidc, err := auth.NewOIDC(a.cfg.Auth.BaseURL, providers)
+		if err != nil {
+			return fmt.Errorf("forge: auth.%w", err)
+		}
+		a.oidc = oidc
+	}
+
+	if (a.authenticateUser != nil || a.useAccounts) && !a.cfg.Auth.Lockout.Disabled {
+		limiter, err := a.buildLoginLimiter()
+		if err != nil {
+			return err
+		}
+		a.loginLimiter = limiter
+		go prunePeriodically(ctx, "login attempts", limiter.Prune)
+	}
+
+	if a.cfg.Auth.TwoFactor.Enabled {
+		if a.authenticateUser == nil && !a.useAccounts {
+			return fmt.Errorf("forge: auth.two_factor: enabled but password login is not configured; call UsePasswordAuth or UseAccounts")
+		}
+		twoFactor, err := a.buildTwoFactor(sm)
+		if err != nil {
+			return err
+		}
+		a.twoFactor = twoFactor
+	}
+
+	if a.cfg.Auth.Impersonation.Enabled {
+		impersonation, err := a.buildImpersonation(sm)
+		if err != nil {
+			return err
+		}
+		a.impersonation = impersonation
+	}
+
+	if a.useAccounts {
+		accounts, err := a.buildAccounts()
+		if err != nil {
+			return err
+		}
+		a.accounts = accounts
+	}
+
+	// Take the client address from proxy headers only when they come from
+	// server.trusted_proxies, then record the request ID, client IP and user
+	// agent for audit entries written by API and HTML handlers alike.
+	trustedProxies, err := apimiddleware.ParseTrustedProxies(a.cfg.Server.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("forge: server.trusted_proxies: %w", err)
 	}
+	a.router.Use(
+		apimiddleware.RealIP(trustedProxies),
+		chimiddleware.RequestID,
+		audit.Middleware(audit.Config{BestEffort: a.cfg.Audit.BestEffort}),
+	)
 
 	// Serve static files from public/ directory.
 	// Files in public/ are served at the root path (e.g., public/css/output.css -> /css/output.css).
 	// If the file doesn't exist, the request passes through to application routes.
 	a.router.Use(staticFiles("public"))
 
+	// Resolve tenants from forge.toml unless a resolver was set in code.
+	tenantResolver := a.tenantResolver
+	switch {
+	case tenantResolver != nil:
+	case a.cfg.Tenant.Resolver == "slug":
+		tenantResolver = auth.NewSlugTenantResolver(a.pool, a.cfg.Tenant.BaseDomain)
+	case a.cfg.Tenant.Resolver != "":
+		r, err := auth.NewTenantResolver(a.cfg.Tenant.Resolver, a.cfg.Tenant.Header)
+		if err != nil {
+			return fmt.Errorf("forge: tenant.resolver: %w", err)
+		}
+		tenantResolver = r
+	}
+	// Every principal must belong to the tenant it resolves to, or a user could
+	// read another tenant's rows by naming it.
+	tenantAuthorizer := a.tenantAuthorizer
+	if tenantAuthorizer == nil && a.rbac != nil {
+		tenantAuthorizer = a.rbac.IsTenantMember
+	}
+	if tenantResolver != nil && tenantAuthorizer == nil {
+		return fmt.Errorf("forge: tenant: resolver is set but nothing checks tenant membership; call UseRBAC or UseTenantAuthorizer")
+	}
+	// Clear cached slug lookups on every instance when tenants change.
+	if slugResolver, ok := tenantResolver.(*auth.SlugTenantResolver); ok && a.notifyHub != nil {
+		go slugResolver.Listen(ctx, a.notifyHub)
+	}
+	// Clear cached role grants on every instance when roles change.
+	if a.rbac != nil && a.notifyHub != nil {
+		go a.rbac.Listen(ctx, a.notifyHub)
+	}
+
 	// Recovery middleware fallback
 	recoveryMw := a.recoveryMw
 	if recoveryMw == nil {
@@ -165,26 +414,61 @@ func (a *App) Listen(addr string) error {
 
 	// Wire API routes
 	if a.apiRoutesFn != nil {
-		_, err := internalapi.SetupAPI(
-			a.router,
-			a.cfg.API,
-			a.tokenStore,
-			a.apiKeyStore,
-			recoveryMw,
-			a.apiRoutesFn,
-		)
+		// Share rate limit counters across instances when configured; nil keeps
+		// them in process memory.
+		var rateLimitStore apimiddleware.RateLimitStore
+		switch a.cfg.API.RateLimit.Store {
+		case "", "memory":
+		case "postgres":
+			rateLimitStore = apimiddleware.NewPostgresRateLimitStore(a.pool)
+		default:
+			return fmt.Errorf("forge: api.rate_limit.store: unknown store %q; want \"memory\" or \"postgres\"", a.cfg.API.RateLimit.Store)
+		}
+		if pruner, ok := rateLimitStore.(apimiddleware.RateLimitPruner); ok {
+			go prunePeriodically(ctx, "rate limits", pruner.PruneRateLimits)
+		}
+		// Fall back to the hashed Postgres credential stores for any store not
+		// set in code, unless the API is configured to be public.
+		tokenStore, apiKeyStore := a.tokenStore, a.apiKeyStore
+		if a.cfg.API.Auth != "none" {
+			if tokenStore == nil {
+				tokenStore = pgstore.NewTokenStore(a.pool)
+			}
+			if apiKeyStore == nil {
+				apiKeyStore = pgstore.NewAPIKeyStore(a.pool)
+			}
+		}
+		_, err := internalapi.SetupAPI(a.router, internalapi.APIServerConfig{
+			APIConfig:          a.cfg.API,
+			TokenStore:         tokenStore,
+			APIKeyStore:        apiKeyStore,
+			RateLimitStore:     rateLimitStore,
+			TenantResolver:     tenantResolver,
+			TenantAuthorizer:   tenantAuthorizer,
+			RoleResolver:       a.roleResolver,
+			PermissionResolver: a.permResolver,
+			RecoveryMiddleware: recoveryMw,
+			RegisterRoutes:     a.apiRoutesFn,
+		})
 		if err != nil {
 			return fmt.Errorf("forge: setup API: %w", err)
 		}
 	}
 
 	// Wire HTML routes
-	if a.htmlRoutesFn != nil || a.requireAuth || a.findOrCreateUser != nil || a.authenticateUser != nil {
+	if a.htmlRoutesFn != nil || a.requireAuth || a.findOrCreateUser != nil || a.authenticateUser != nil || a.accounts != nil {
 		err := internalapi.SetupHTML(a.router, internalapi.HTMLServerConfig{
 			SessionManager:       sm,
 			RegisterRoutes:       a.htmlRoutesFn,
 			RequireAuth:          a.requireAuth,
 			RegisterPublicRoutes: a.buildPublicRoutesFn(sm),
+			TenantResolver:       tenantResolver,
+			TenantAuthorizer:     tenantAuthorizer,
+			RoleResolver:         a.roleResolver,
+			PermissionResolver:   a.permResolver,
+			CSRFExemptPaths:      a.csrfExempt,
+			Sessions:             a.sessions,
+			Impersonation:        a.impersonation,
 		})
 		if err != nil {
 			return fmt.Errorf("forge: setup HTML: %w", err)
@@ -219,12 +503,23 @@ func (a *App) Listen(addr string) error {
 // buildPublicRoutesFn composes auth routes and custom public routes into a
 // single function for the public (unauthenticated) route group.
 func (a *App) buildPublicRoutesFn(sm *scs.SessionManager) func(chi.Router) {
-	if a.findOrCreateUser == nil && a.authenticateUser == nil && a.publicRoutesFn == nil {
+	hasAuthRoutes := a.findOrCreateUser != nil || a.authenticateUser != nil || a.accounts != nil || a.impersonation != nil
+	if !hasAuthRoutes && a.publicRoutesFn == nil {
 		return nil
 	}
 	return func(r chi.Router) {
-		if a.findOrCreateUser != nil || a.authenticateUser != nil {
-			auth.RegisterOAuthRoutes(r, sm, a.findOrCreateUser, a.authenticateUser)
+		if hasAuthRoutes {
+			auth.RegisterOAuthRoutes(r, sm, auth.OAuthRoutesConfig{
+				FindOrCreateUser: a.findOrCreateUser,
+				AuthenticateUser: a.authenticateUser,
+				OIDC:             a.oidc,
+				Accounts:         a.accounts,
+				Limiter:          a.loginLimiter,
+				Sessions:         a.sessions,
+				TwoFactor:        a.twoFactor,
+				Impersonation:    a.impersonation,
+				Pages:            a.authPages,
+			})
 		}
 		if a.publicRoutesFn != nil {
 			a.publicRoutesFn(r)
@@ -232,6 +527,206 @@ func (a *App) buildPublicRoutesFn(sm *scs.SessionManager) func(chi.Router) {
 	}
 }
 
+// buildSessionManager creates the session manager from [session]. Cookies are
+// secure unless session.secure says otherwise or server.host is "localhost".
+func (a *App) buildSessionManager() (*scs.SessionManager, error) {
+	cfg := a.cfg.Session
+	lifetime, err := parseOptionalDuration(cfg.Lifetime)
+	if err != nil {
+		return nil, fmt.Errorf("forge: session.lifetime: %w", err)
+	}
+	idleTimeout, err := parseOptionalDuration(cfg.IdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("forge: session.idle_timeout: %w", err)
+	}
+	secure := a.cfg.Server.Host != "localhost"
+	if cfg.Secure != nil {
+		secure = *cfg.Secure
+	}
+	return auth.NewSessionManager(a.pool, auth.SessionConfig{
+		Lifetime:     lifetime,
+		IdleTimeout:  idleTimeout,
+		Secure:       secure,
+		CookieDomain: cfg.CookieDomain,
+	}), nil
+}
+
+// buildAccounts creates the account lifecycle from [auth.accounts] and
+// [mail], using the stores and mailer set in code when present.
+func (a *App) buildAccounts() (*auth.Accounts, error) {
+	cfg := a.cfg.Auth.Accounts
+	resetTTL, err := parseOptionalDuration(cfg.ResetTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.accounts.reset_token_ttl: %w", err)
+	}
+	verifyTTL, err := parseOptionalDuration(cfg.VerifyTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.accounts.verify_token_ttl: %w", err)
+	}
+
+	users := a.userStore
+	if users == nil {
+		users = pgstore.NewUserStore(a.pool)
+	}
+	mailer := a.mailer
+	switch {
+	case mailer != nil:
+	case a.cfg.Mail.SMTPAddr != "":
+		if a.cfg.Mail.From == "" {
+			return nil, fmt.Errorf("forge: mail.from is required with mail.smtp_addr")
+		}
+		mailer = auth.SMTPMailer{
+			Addr:     a.cfg.Mail.SMTPAddr,
+			From:     a.cfg.Mail.From,
+			Username: a.cfg.Mail.Username,
+			Password: a.cfg.Mail.Password,
+		}
+	default:
+		mailer = auth.LogMailer{}
+	}
+
+	accounts, err := auth.NewAccounts(auth.AccountsConfig{
+		Users:                users,
+		Mailer:               mailer,
+		BaseURL:              a.cfg.Auth.BaseURL,
+		AppName:              a.cfg.Project.Name,
+		DisableSignup:        cfg.DisableSignup,
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
+		ResetTokenTTL:        resetTTL,
+		VerifyTokenTTL:       verifyTTL,
+		MinPasswordLength:    cfg.MinPasswordLength,
+		Limiter:              a.loginLimiter,
+		Sessions:             a.sessions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.%w", err)
+	}
+	return accounts, nil
+}
+
+// buildTwoFactor creates TOTP two-factor authentication from
+// [auth.two_factor], storing secrets and recovery codes in Postgres.
+func (a *App) buildTwoFactor(sm *scs.SessionManager) (*auth.TwoFactor, error) {
+	cfg := a.cfg.Auth.TwoFactor
+	pendingTTL, err := parseOptionalDuration(cfg.PendingTTL)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.two_factor.pending_ttl: %w", err)
+	}
+	issuer := cfg.Issuer
+	if issuer == "" {
+		issuer = a.cfg.Project.Name
+	}
+	twoFactor, err := auth.NewTwoFactor(sm, auth.TwoFactorConfig{
+		Store:         pgstore.NewTwoFactorStore(a.pool),
+		Issuer:        issuer,
+		RequiredRoles: cfg.RequiredRoles,
+		Roles:         a.roleResolver,
+		Limiter:       a.loginLimiter,
+		RecoveryCodes: cfg.RecoveryCodes,
+		PendingTTL:    pendingTTL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.%w", err)
+	}
+	return twoFactor, nil
+}
+
+// buildImpersonation creates admin impersonation from [auth.impersonation],
+// recording its events like sign-in events.
+func (a *App) buildImpersonation(sm *scs.SessionManager) (*auth.Impersonation, error) {
+	cfg := a.cfg.Auth.Impersonation
+	maxDuration, err := parseOptionalDuration(cfg.MaxDuration)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.impersonation.max_duration: %w", err)
+	}
+	users := a.userStore
+	if users == nil {
+		users = pgstore.NewUserStore(a.pool)
+	}
+	events := a.authEvents
+	if events == nil {
+		events = pgstore.NewAuthEventStore(a.pool)
+	}
+	// Refuse impersonating a user who is an admin in any tenant, not just the
+	// current one.
+	var allRoles auth.RoleResolver
+	if a.rbac != nil {
+		allRoles = a.rbac.AllRoles
+	}
+	impersonation, err := auth.NewImpersonation(sm, auth.ImpersonationConfig{
+		AllowedRoles: cfg.Roles,
+		Roles:        a.roleResolver,
+		AllRoles:     allRoles,
+		Users:        users,
+		Events:       events,
+		MaxDuration:  maxDuration,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.%w", err)
+	}
+	return impersonation, nil
+}
+
+// pruneInterval is how often prunePeriodically deletes expired rows.
+const pruneInterval = 10 * time.Minute
+
+// prunePeriodically calls prune every pruneInterval until ctx is done, so
+// counter tables such as login_attempts do not grow without bound. Errors are
+// logged and retried on the next tick.
+func prunePeriodically(ctx context.Context, name string, prune func(context.Context) error) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := prune(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("forge: prune "+name, "err", err)
+			}
+		}
+	}
+}
+
+// buildLoginLimiter creates the password login limiter from [auth.lockout],
+// storing counters and events in Postgres unless a recorder was set in code.
+func (a *App) buildLoginLimiter() (*auth.LoginLimiter, error) {
+	cfg := a.cfg.Auth.Lockout
+	window, err := parseOptionalDuration(cfg.Window)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.lockout.window: %w", err)
+	}
+	lockoutDuration, err := parseOptionalDuration(cfg.LockoutDuration)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.lockout.lockout_duration: %w", err)
+	}
+	maxDelay, err := parseOptionalDuration(cfg.MaxDelay)
+	if err != nil {
+		return nil, fmt.Errorf("forge: auth.lockout.max_delay: %w", err)
+	}
+
+	events := a.authEvents
+	if events == nil {
+		events = pgstore.NewAuthEventStore(a.pool)
+	}
+	return auth.NewLoginLimiter(pgstore.NewLoginAttemptStore(a.pool), events, auth.LockoutConfig{
+		MaxAccountFailures: cfg.MaxAccountFailures,
+		MaxIPFailures:      cfg.MaxIPFailures,
+		Window:             window,
+		LockoutDuration:    lockoutDuration,
+		DelayAfter:         cfg.DelayAfter,
+		MaxDelay:           maxDelay,
+	}), nil
+}
+
+// parseOptionalDuration parses a Go duration string, returning 0 for "".
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
 // Pool returns the database connection pool.
 // Only valid after Listen() has been called (for test infrastructure, use forge/forgetest).
 func (a *App) Pool() *pgxpool.Pool {
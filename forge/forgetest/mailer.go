@@ -0,0 +1,81 @@
+package forgetest
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// Mailer is an auth.Mailer that records messages instead of sending them, so
+// tests can follow verification and password reset links. Account emails are
+// sent after the response, so wait for them first.
+//
+//	mailer := forgetest.NewMailer()
+//	app.UseMailer(mailer)
+//	// ... submit /auth/forgot-password ...
+//	mailer.Wait(t, 1)
+//	link := mailer.LastLink(t)
+type Mailer struct {
+	mu       sync.Mutex
+	messages []auth.Message
+}
+
+// NewMailer returns an empty Mailer.
+func NewMailer() *Mailer {
+	return &Mailer{}
+}
+
+// Send records msg.
+func (m *Mailer) Send(ctx context.Context, msg auth.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (m *Mailer) Messages() []auth.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]auth.Message(nil), m.messages...)
+}
+
+// Wait returns the messages sent so far once there are at least n, failing
+// the test when they do not arrive within five seconds.
+func (m *Mailer) Wait(t *testing.T, n int) []auth.Message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		msgs := m.Messages()
+		if len(msgs) >= n {
+			return msgs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("forgetest.Mailer: %d messages sent, want %d", len(msgs), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// LastLink returns the first URL in the text of the most recent message,
+// failing the test when there is none.
+func (m *Mailer) LastLink(t *testing.T) string {
+	t.Helper()
+
+	msgs := m.Messages()
+	if len(msgs) == 0 {
+		t.Fatal("forgetest.Mailer: no messages sent")
+	}
+	link := linkPattern.FindString(msgs[len(msgs)-1].Text)
+	if link == "" {
+		t.Fatalf("forgetest.Mailer: no link in message %q", msgs[len(msgs)-1].Subject)
+	}
+	return link
+}
@@ -0,0 +1,228 @@
+package forgetest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Client credentials accepted by every MockIdP.
+const (
+	MockIdPClientID     = "forge-test-client"
+	MockIdPClientSecret = "forge-test-secret"
+)
+
+// mockIdPKeyID is the kid of the MockIdP signing key.
+const mockIdPKeyID = "forgetest"
+
+// MockIdP is an in-process OpenID Connect provider for testing sign-in flows
+// without a network. It serves discovery, JWKS, authorization and token
+// endpoints, approves every authorization request without a login page, and
+// enforces PKCE (S256), the client credentials and the redirect URI.
+//
+//	idp := forgetest.NewMockIdP(t)
+//	idp.SignInAs(map[string]any{"sub": "user-1", "email": "ada@example.com"})
+//	// configure an OIDC provider with Issuer: idp.Issuer(),
+//	// ClientID: forgetest.MockIdPClientID, ClientSecret: forgetest.MockIdPClientSecret
+type MockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]any
+	codes  map[string]mockAuthRequest
+}
+
+// mockAuthRequest is an approved authorization request awaiting its code
+// exchange.
+type mockAuthRequest struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	claims        map[string]any
+}
+
+// NewMockIdP starts a MockIdP and registers cleanup to stop it when the test
+// completes. Until SignInAs is called, sign-ins use the subject "mock-user".
+func NewMockIdP(t *testing.T) *MockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("forgetest.NewMockIdP: failed to generate key: %v", err)
+	}
+	m := &MockIdP{
+		key:    key,
+		claims: map[string]any{"sub": "mock-user"},
+		codes:  make(map[string]mockAuthRequest),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("GET /jwks", m.handleJWKS)
+	mux.HandleFunc("GET /authorize", m.handleAuthorize)
+	mux.HandleFunc("POST /token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+// Issuer returns the issuer URL to configure the OIDC provider with.
+func (m *MockIdP) Issuer() string {
+	return m.server.URL
+}
+
+// SignInAs sets the claims of the ID tokens issued from now on. They are added
+// to the standard iss, aud, exp, iat and nonce claims and override them, so a
+// test can also issue invalid tokens (e.g. {"nonce": "wrong"}).
+func (m *MockIdP) SignInAs(claims map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claims = claims
+}
+
+func (m *MockIdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *MockIdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": mockIdPKeyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// handleAuthorize approves the request and redirects back with a code, or
+// with an error when the request is malformed or lacks a PKCE challenge.
+func (m *MockIdP) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{"state": {q.Get("state")}}
+	switch {
+	case q.Get("client_id") != MockIdPClientID:
+		params.Set("error", "unauthorized_client")
+	case q.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "PKCE S256 code_challenge required")
+	default:
+		code := randomHex()
+		m.mu.Lock()
+		m.codes[code] = mockAuthRequest{
+			redirectURI:   redirectURI.String(),
+			codeChallenge: q.Get("code_challenge"),
+			nonce:         q.Get("nonce"),
+			claims:        m.claims,
+		}
+		m.mu.Unlock()
+		params.Set("code", code)
+	}
+
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// handleToken exchanges a code for tokens after checking the client
+// credentials, redirect URI and PKCE verifier.
+func (m *MockIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != MockIdPClientID || clientSecret != MockIdPClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	req, found := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if !found || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != req.redirectURI ||
+		subtle.ConstantTimeCompare([]byte(challenge), []byte(req.codeChallenge)) != 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": m.server.URL,
+		"aud": MockIdPClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if req.nonce != "" {
+		claims["nonce"] = req.nonce
+	}
+	for k, v := range req.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mockIdPKeyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomHex(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+func randomHex() string {
+	b := make([]byte, 16)
+	rand.Read(b) //nolint:errcheck
+	return hex.EncodeToString(b)
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
+
+	"github.com/alternayte/forge/forge/auth"
 )
 
 // DB is an interface compatible with pgx transaction types.
@@ -22,8 +24,13 @@ type TransactionFunc func(ctx context.Context, tx pgx.Tx) error
 
 // Transaction wraps pgx.BeginFunc to provide a clean transaction API.
 // The transaction commits if fn returns nil, and rolls back if fn returns an error.
+// When ctx carries a tenant (auth.WithTenant), app.current_tenant is set for the
+// transaction so row-level security admits that tenant's rows.
 func Transaction(ctx context.Context, pool *pgxpool.Pool, fn TransactionFunc) error {
 	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if err := auth.SetLocalTenant(ctx, tx); err != nil {
+			return err
+		}
 		return fn(ctx, tx)
 	})
 }
@@ -31,8 +38,12 @@ func Transaction(ctx context.Context, pool *pgxpool.Pool, fn TransactionFunc) er
 // TransactionWithJobs wraps a transaction and provides a River client for transactional job enqueueing.
 // Jobs enqueued with client.InsertTx will only be visible after the transaction commits.
 // The transaction commits if fn returns nil, and rolls back if fn returns an error.
+// Like Transaction, it sets app.current_tenant from the tenant in ctx.
 func TransactionWithJobs(ctx context.Context, pool *pgxpool.Pool, client *river.Client[pgx.Tx], fn func(ctx context.Context, tx pgx.Tx, jobs *river.Client[pgx.Tx]) error) error {
 	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if err := auth.SetLocalTenant(ctx, tx); err != nil {
+			return err
+		}
 		return fn(ctx, tx, client)
 	})
 }
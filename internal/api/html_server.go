@@ -31,6 +31,40 @@ type HTMLServerConfig struct {
 	// public (unauthenticated) group. Auth routes (login, logout, OAuth callbacks)
 	// are registered here to avoid RequireSession redirect loops.
 	RegisterPublicRoutes func(chi.Router)
+
+	// TenantResolver identifies the tenant of each resource route request and
+	// stores it in context for TenantScoped resources. Requests whose tenant
+	// cannot be resolved get an error page. Nil disables tenant resolution.
+	TenantResolver auth.TenantResolver
+
+	// TenantAuthorizer admits the session user to the resolved tenant; users it
+	// rejects get 403 Forbidden. Nil rejects every signed-in user, so set it
+	// whenever TenantResolver is set.
+	TenantAuthorizer auth.TenantAuthorizer
+
+	// RoleResolver loads the roles of the session user on resource routes so
+	// generated Permission rules apply. Nil stores the user ID without roles.
+	RoleResolver auth.RoleResolver
+
+	// PermissionResolver loads the permission strings of the session user on
+	// resource routes. Nil stores no permissions.
+	PermissionResolver auth.PermissionResolver
+
+	// CSRFExemptPaths lists paths whose POST, PUT, PATCH and DELETE requests
+	// skip the CSRF check, e.g. webhooks registered with RegisterPublicRoutes
+	// that verify their own signatures. A path ending in "/*" exempts
+	// everything under it.
+	CSRFExemptPaths []string
+
+	// Sessions, when set, indexes each user's signed-in sessions with their
+	// device, IP and last activity so users can list and revoke them. Nil
+	// leaves sessions unindexed.
+	Sessions *auth.Sessions
+
+	// Impersonation, when set, ends expired impersonations and stores the
+	// impersonating admin in the request context. Nil disallows
+	// impersonation.
+	Impersonation *auth.Impersonation
 }
 
 // SetupHTML wires session middleware and HTML route groups onto a Chi router.
@@ -43,6 +77,13 @@ type HTMLServerConfig struct {
 //     (which lives in the public group) cannot write session data.
 //     (Pitfall 9: Session LoadAndSave must wrap ALL routes including auth routes)
 //
+//     auth.CSRF runs next, on every route: state-changing requests must carry
+//     the session's CSRF token, including the login form, so a cross-site page
+//     can neither act as the user nor sign them in to another account.
+//     cfg.Sessions.Track, when set, then records the request's session in the
+//     user's session index, and cfg.Impersonation.Enforce ends impersonations
+//     that ran out of time.
+//
 //  2. Public group (no auth required): intended for OAuth callback routes,
 //     /auth/login, /auth/logout, and any other unauthenticated pages.
 //     These must be registered BEFORE the protected RequireSession group to avoid
@@ -52,6 +93,11 @@ type HTMLServerConfig struct {
 //  3. Protected group (RequireSession enforced): resource HTML routes live here.
 //     cfg.RegisterRoutes is called on this group so every resource route
 //     automatically inherits session auth without each handler checking itself.
+//     When cfg.TenantResolver is set, the group also resolves the request tenant
+//     and checks the session user's access to it with cfg.TenantAuthorizer.
+//     The session user, its roles (cfg.RoleResolver) and permissions
+//     (cfg.PermissionResolver) are then loaded into the forge/auth context for
+//     generated actions.
 //
 // Example wiring in a generated project's main.go:
 //
@@ -67,6 +113,13 @@ func SetupHTML(router chi.Router, cfg HTMLServerConfig) error {
 		// Session LoadAndSave must wrap ALL routes including auth routes so the
 		// OAuth callback can commit sessions (Pitfall 9).
 		r.Use(cfg.SessionManager.LoadAndSave)
+		r.Use(auth.CSRF(cfg.SessionManager, cfg.CSRFExemptPaths...))
+		if cfg.Sessions != nil {
+			r.Use(cfg.Sessions.Track)
+		}
+		if cfg.Impersonation != nil {
+			r.Use(cfg.Impersonation.Enforce)
+		}
 
 		// Public group — no session authentication required.
 		// Register OAuth callbacks, login/logout handlers here to prevent the
@@ -84,6 +137,10 @@ func SetupHTML(router chi.Router, cfg HTMLServerConfig) error {
 			if cfg.RequireAuth {
 				rr.Use(auth.RequireSession(cfg.SessionManager))
 			}
+			if cfg.TenantResolver != nil {
+				rr.Use(auth.TenantMiddleware(cfg.SessionManager, cfg.TenantResolver, cfg.TenantAuthorizer))
+			}
+			rr.Use(auth.SessionUser(cfg.SessionManager, cfg.RoleResolver, cfg.PermissionResolver))
 			if cfg.RegisterRoutes != nil {
 				cfg.RegisterRoutes(rr)
 			}
@@ -1,12 +1,16 @@
 package middleware
 
 import (
+	"context"
 	"crypto/subtle"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+
 	"github.com/alternayte/forge/forge/auth"
 )
 
@@ -21,6 +25,9 @@ const (
 	ContextKeyAPIKeyID contextKey = "api_key_id"
 	// ContextKeyAPIKeyScopes is set in context with the API key's scopes.
 	ContextKeyAPIKeyScopes contextKey = "api_key_scopes"
+	// ContextKeyTokenScopes is set in context with a JWT's scopes when the
+	// token carries a scope claim.
+	ContextKeyTokenScopes contextKey = "token_scopes"
 )
 
 // AuthMiddleware validates bearer tokens and API keys on every request.
@@ -30,16 +37,19 @@ type AuthMiddleware struct {
 	api         huma.API
 	tokenStore  auth.TokenStore
 	apiKeyStore auth.APIKeyStore
+	jwt         *JWTVerifier
 }
 
 // NewAuthMiddleware creates an AuthMiddleware that uses the given stores.
 // The huma.API is required so the middleware can write structured error
-// responses via huma.WriteErr.
-func NewAuthMiddleware(api huma.API, tokenStore auth.TokenStore, apiKeyStore auth.APIKeyStore) *AuthMiddleware {
+// responses via huma.WriteErr. When jwt is non-nil, bearer tokens shaped like
+// a JWT are validated by it instead of the token store.
+func NewAuthMiddleware(api huma.API, tokenStore auth.TokenStore, apiKeyStore auth.APIKeyStore, jwt *JWTVerifier) *AuthMiddleware {
 	return &AuthMiddleware{
 		api:         api,
 		tokenStore:  tokenStore,
 		apiKeyStore: apiKeyStore,
+		jwt:         jwt,
 	}
 }
 
@@ -47,12 +57,11 @@ func NewAuthMiddleware(api huma.API, tokenStore auth.TokenStore, apiKeyStore aut
 // header for a bearer token or an API key and rejects requests without a valid
 // credential with HTTP 401.
 //
-// When both tokenStore and apiKeyStore are nil (no auth configured), the
-// middleware passes through without checking credentials. This allows freshly
-// generated projects to work out of the box before auth is set up.
+// When tokenStore, apiKeyStore and jwt are all nil (no auth configured), the
+// middleware passes through without checking credentials.
 func (m *AuthMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
-	// No auth stores configured — pass through (dev mode / no auth)
-	if m.tokenStore == nil && m.apiKeyStore == nil {
+	// No auth configured — pass through (dev mode / no auth)
+	if m.tokenStore == nil && m.apiKeyStore == nil && m.jwt == nil {
 		next(ctx)
 		return
 	}
@@ -68,11 +77,19 @@ func (m *AuthMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
 	switch {
 	case strings.HasPrefix(authHeader, "Bearer "):
 		token := strings.TrimPrefix(authHeader, "Bearer ")
-		updatedCtx, err = m.validateBearerToken(ctx, token)
+		if m.jwt != nil && isJWT(token) {
+			updatedCtx, err = m.validateJWT(ctx, token)
+		} else {
+			updatedCtx, err = m.validateBearerToken(ctx, token)
+		}
 		if err != nil {
 			huma.WriteErr(m.api, ctx, http.StatusUnauthorized, "Unauthorized", err) //nolint:errcheck
 			return
 		}
+		if err := checkTokenScopes(updatedCtx); err != nil {
+			huma.WriteErr(m.api, ctx, http.StatusForbidden, "Forbidden", err) //nolint:errcheck
+			return
+		}
 
 	case auth.IsAPIKey(authHeader):
 		updatedCtx, err = m.validateAPIKey(ctx, authHeader)
@@ -80,6 +97,10 @@ func (m *AuthMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
 			huma.WriteErr(m.api, ctx, http.StatusUnauthorized, "Unauthorized", err) //nolint:errcheck
 			return
 		}
+		if err := checkAPIKeyScopes(updatedCtx); err != nil {
+			huma.WriteErr(m.api, ctx, http.StatusForbidden, "Forbidden", err) //nolint:errcheck
+			return
+		}
 
 	case apiKeyHeader != "":
 		updatedCtx, err = m.validateAPIKey(ctx, apiKeyHeader)
@@ -87,6 +108,10 @@ func (m *AuthMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
 			huma.WriteErr(m.api, ctx, http.StatusUnauthorized, "Unauthorized", err) //nolint:errcheck
 			return
 		}
+		if err := checkAPIKeyScopes(updatedCtx); err != nil {
+			huma.WriteErr(m.api, ctx, http.StatusForbidden, "Forbidden", err) //nolint:errcheck
+			return
+		}
 
 	default:
 		huma.WriteErr(m.api, ctx, http.StatusUnauthorized, "Authorization header required") //nolint:errcheck
@@ -98,40 +123,48 @@ func (m *AuthMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
 
 // validateBearerToken validates the provided raw token value. It uses
 // constant-time comparison to prevent timing attacks. On success it returns an
-// updated huma.Context with ContextKeyUserID set.
+// updated huma.Context with ContextKeyUserID and the forge/auth user set.
 func (m *AuthMiddleware) validateBearerToken(ctx huma.Context, provided string) (huma.Context, error) {
+	if m.tokenStore == nil {
+		return ctx, &authError{"invalid bearer token"}
+	}
 	stored, err := m.tokenStore.GetByToken(ctx.Context(), provided)
 	if err != nil {
 		return ctx, err
 	}
 
-	// Constant-time comparison to prevent timing attacks (NEVER use ==).
-	if subtle.ConstantTimeCompare([]byte(provided), []byte(stored.Token)) != 1 {
+	if !secretMatches(provided, stored.Token, stored.TokenHash) {
 		return ctx, &authError{"invalid bearer token"}
 	}
 
 	if stored.ExpiresAt.Before(time.Now()) {
 		return ctx, &authError{"bearer token has expired"}
 	}
+	markUsed(ctx.Context(), m.tokenStore, stored.ID)
 
-	return huma.WithValue(ctx, ContextKeyUserID, stored.UserID), nil
+	ctx = huma.WithValue(ctx, ContextKeyUserID, stored.UserID)
+	return huma.WithContext(ctx, auth.WithUser(ctx.Context(), stored.UserID)), nil
 }
 
 // validateAPIKey validates the provided raw API key value. It uses
 // constant-time comparison to prevent timing attacks. On success it returns an
-// updated huma.Context with ContextKeyAPIKeyID and ContextKeyAPIKeyScopes set.
+// updated huma.Context with ContextKeyAPIKeyID and ContextKeyAPIKeyScopes set,
+// the key ID stored with auth.WithAPIKey for audit entries,
+// and the key's owner as the forge/auth user so roles resolve for it.
 func (m *AuthMiddleware) validateAPIKey(ctx huma.Context, provided string) (huma.Context, error) {
 	if _, ok := auth.ValidateKeyPrefix(provided); !ok {
 		return ctx, &authError{"invalid API key prefix"}
 	}
 
+	if m.apiKeyStore == nil {
+		return ctx, &authError{"invalid API key"}
+	}
 	stored, err := m.apiKeyStore.GetByKey(ctx.Context(), provided)
 	if err != nil {
 		return ctx, err
 	}
 
-	// Constant-time comparison to prevent timing attacks (NEVER use ==).
-	if subtle.ConstantTimeCompare([]byte(provided), []byte(stored.Key)) != 1 {
+	if !secretMatches(provided, stored.Key, stored.KeyHash) {
 		return ctx, &authError{"invalid API key"}
 	}
 
@@ -142,10 +175,97 @@ func (m *AuthMiddleware) validateAPIKey(ctx huma.Context, provided string) (huma
 	if stored.ExpiresAt != nil && stored.ExpiresAt.Before(time.Now()) {
 		return ctx, &authError{"API key has expired"}
 	}
+	markUsed(ctx.Context(), m.apiKeyStore, stored.ID)
 
 	ctx = huma.WithValue(ctx, ContextKeyAPIKeyID, stored.ID)
 	ctx = huma.WithValue(ctx, ContextKeyAPIKeyScopes, stored.Scopes)
-	return ctx, nil
+	c := auth.WithScopes(auth.WithUser(ctx.Context(), stored.UserID), stored.Scopes...)
+	c = auth.WithAPIKey(c, stored.ID)
+	return huma.WithContext(ctx, c), nil
+}
+
+// validateJWT validates a JWT bearer token with the configured JWTVerifier. On
+// success it returns an updated huma.Context with ContextKeyUserID and the
+// forge/auth user, roles, tenant and scopes named by the token's claims.
+func (m *AuthMiddleware) validateJWT(ctx huma.Context, provided string) (huma.Context, error) {
+	p, err := m.jwt.Verify(ctx.Context(), provided)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx = huma.WithValue(ctx, ContextKeyUserID, p.UserID)
+	if p.HasScopes {
+		ctx = huma.WithValue(ctx, ContextKeyTokenScopes, p.Scopes)
+	}
+	c := auth.WithUser(ctx.Context(), p.UserID)
+	if len(p.Roles) > 0 {
+		c = auth.WithUserRoles(c, p.UserID, p.Roles...)
+	}
+	if p.TenantID != uuid.Nil {
+		c = auth.WithTenant(c, p.TenantID)
+	}
+	if p.HasScopes {
+		c = auth.WithScopes(c, p.Scopes...)
+	}
+	return huma.WithContext(ctx, c), nil
+}
+
+// secretMatches reports whether the provided credential matches the stored one,
+// comparing SHA-256 digests when the store keeps a hash and the plaintext
+// otherwise. Both comparisons are constant-time to prevent timing attacks
+// (NEVER use ==).
+func secretMatches(provided, plaintext, hash string) bool {
+	if hash != "" {
+		return subtle.ConstantTimeCompare([]byte(auth.HashSecret(provided)), []byte(hash)) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(plaintext)) == 1
+}
+
+// markUsed records the credential's use when store implements
+// auth.UsageTracker. Failures are logged and do not reject the request.
+func markUsed(ctx context.Context, store any, id uuid.UUID) {
+	tracker, ok := store.(auth.UsageTracker)
+	if !ok {
+		return
+	}
+	if err := tracker.MarkUsed(ctx, id); err != nil {
+		slog.Warn("failed to record credential use", "id", id, "err", err)
+	}
+}
+
+// checkAPIKeyScopes verifies that the validated API key in ctx holds every scope
+// the operation requires under the auth.SecuritySchemeAPIKey security requirement.
+// Operations without such a requirement accept any valid key.
+func checkAPIKeyScopes(ctx huma.Context) error {
+	granted, _ := ctx.Context().Value(ContextKeyAPIKeyScopes).([]string)
+	return checkScopes(ctx, granted, "API key")
+}
+
+// checkTokenScopes applies the API key scope requirements to a JWT carrying a
+// scope claim. Opaque tokens and JWTs without the claim are not restricted.
+func checkTokenScopes(ctx huma.Context) error {
+	granted, ok := ctx.Context().Value(ContextKeyTokenScopes).([]string)
+	if !ok {
+		return nil
+	}
+	return checkScopes(ctx, granted, "token")
+}
+
+// checkScopes verifies that granted holds every scope the operation requires
+// under the auth.SecuritySchemeAPIKey security requirement.
+func checkScopes(ctx huma.Context, granted []string, credential string) error {
+	op := ctx.Operation()
+	if op == nil {
+		return nil
+	}
+	var required []string
+	for _, req := range op.Security {
+		required = append(required, req[auth.SecuritySchemeAPIKey]...)
+	}
+	if !auth.HasScopes(granted, required) {
+		return &authError{credential + " is missing required scope: " + strings.Join(required, ", ")}
+	}
+	return nil
 }
 
 // authError is a simple error type for authentication failures.
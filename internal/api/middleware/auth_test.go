@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// fakeAPIKeyStore serves API keys from memory by their full value.
+type fakeAPIKeyStore map[string]*auth.APIKey
+
+func (s fakeAPIKeyStore) GetByKey(_ context.Context, key string) (*auth.APIKey, error) {
+	stored, ok := s[key]
+	if !ok {
+		return nil, errors.New("api key not found")
+	}
+	return stored, nil
+}
+
+func (s fakeAPIKeyStore) Create(context.Context, uuid.UUID, string, string, []string, *time.Time) (*auth.APIKey, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s fakeAPIKeyStore) Revoke(context.Context, uuid.UUID) error {
+	return errors.New("not implemented")
+}
+
+// fakeTokenStore serves bearer tokens from memory by their full value.
+type fakeTokenStore map[string]*auth.Token
+
+func (s fakeTokenStore) GetByToken(_ context.Context, token string) (*auth.Token, error) {
+	stored, ok := s[token]
+	if !ok {
+		return nil, errors.New("token not found")
+	}
+	return stored, nil
+}
+
+func (s fakeTokenStore) Create(context.Context, uuid.UUID, time.Time) (*auth.Token, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s fakeTokenStore) Delete(context.Context, uuid.UUID) error {
+	return errors.New("not implemented")
+}
+
+func TestSecretMatches(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name      string
+		provided  string
+		plaintext string
+		hash      string
+		want      bool
+	}{
+		{"hashed row", secret, "", auth.HashSecret(secret), true},
+		{"hashed row, wrong secret", secret + "x", "", auth.HashSecret(secret), false},
+		// A hashed row is never compared against its (empty) plaintext
+		{"hashed row, empty secret", "", "", auth.HashSecret(secret), false},
+		{"hashed row ignores plaintext", secret, secret, auth.HashSecret("other"), false},
+		{"legacy plaintext row", secret, secret, "", true},
+		{"legacy plaintext row, wrong secret", secret + "x", secret, "", false},
+		{"legacy plaintext row, prefix of secret", secret[:8], secret, "", false},
+		// Presenting the stored digest itself does not authenticate
+		{"digest as secret", auth.HashSecret(secret), "", auth.HashSecret(secret), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secretMatches(tt.provided, tt.plaintext, tt.hash); got != tt.want {
+				t.Errorf("secretMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_HashedAndLegacyCredentials(t *testing.T) {
+	const (
+		hashedToken = "hashed00000000000000000000000000"
+		legacyToken = "legacy00000000000000000000000000"
+		hashedKey   = auth.PrefixLive + "hashed000000000000000000"
+		legacyKey   = auth.PrefixLive + "legacy000000000000000000"
+	)
+	expires := time.Now().Add(time.Hour)
+	tokens := fakeTokenStore{
+		hashedToken: {ID: uuid.New(), TokenHash: auth.HashSecret(hashedToken), UserID: testUserID, ExpiresAt: expires},
+		legacyToken: {ID: uuid.New(), Token: legacyToken, UserID: testUserID, ExpiresAt: expires},
+		// A row whose digest belongs to another secret is rejected even when
+		// the store returns it for the presented one
+		"mismatched": {ID: uuid.New(), TokenHash: auth.HashSecret("other"), UserID: testUserID, ExpiresAt: expires},
+	}
+	keys := fakeAPIKeyStore{
+		hashedKey: {ID: uuid.New(), KeyHash: auth.HashSecret(hashedKey), UserID: testUserID},
+		legacyKey: {ID: uuid.New(), Key: legacyKey, UserID: testUserID},
+	}
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(NewAuthMiddleware(api, tokens, keys, nil).Handle)
+	huma.Register(api, huma.Operation{
+		OperationID: "getStatus",
+		Method:      http.MethodGet,
+		Path:        "/status",
+	}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"hashed token", "Authorization: Bearer " + hashedToken, http.StatusNoContent},
+		{"legacy token", "Authorization: Bearer " + legacyToken, http.StatusNoContent},
+		{"mismatched token", "Authorization: Bearer mismatched", http.StatusUnauthorized},
+		{"hashed key", "X-API-Key: " + hashedKey, http.StatusNoContent},
+		{"legacy key", "X-API-Key: " + legacyKey, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := api.Get("/status", tt.header); resp.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", resp.Code, tt.want, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_APIKeyScopes(t *testing.T) {
+	const (
+		readKey  = auth.PrefixTest + "read0000000000000000000000"
+		otherKey = auth.PrefixTest + "other000000000000000000000"
+		bareKey  = auth.PrefixTest + "bare0000000000000000000000"
+	)
+	store := fakeAPIKeyStore{
+		readKey:  {ID: uuid.New(), Key: readKey, UserID: testUserID, Scopes: []string{"products:read"}},
+		otherKey: {ID: uuid.New(), Key: otherKey, UserID: testUserID, Scopes: []string{"orders:read"}},
+		bareKey:  {ID: uuid.New(), Key: bareKey, UserID: testUserID},
+	}
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(NewAuthMiddleware(api, nil, store, nil).Handle)
+	huma.Register(api, huma.Operation{
+		OperationID: "listProducts",
+		Method:      http.MethodGet,
+		Path:        "/products",
+		Security:    []map[string][]string{{auth.SecuritySchemeAPIKey: {"products:read"}}},
+	}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+	huma.Register(api, huma.Operation{
+		OperationID: "getStatus",
+		Method:      http.MethodGet,
+		Path:        "/status",
+	}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+
+	tests := []struct {
+		name string
+		path string
+		key  string
+		want int
+	}{
+		{"sufficient scope", "/products", readKey, http.StatusNoContent},
+		{"missing scope", "/products", otherKey, http.StatusForbidden},
+		{"no scopes", "/products", bareKey, http.StatusForbidden},
+		{"no requirement", "/status", bareKey, http.StatusNoContent},
+		{"no requirement, other scopes", "/status", otherKey, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Keys are accepted in both the X-API-Key and Authorization headers
+			for _, header := range []string{"X-API-Key: " + tt.key, "Authorization: " + tt.key} {
+				if resp := api.Get(tt.path, header); resp.Code != tt.want {
+					t.Errorf("%s: status = %d, want %d: %s", header, resp.Code, tt.want, resp.Body.String())
+				}
+			}
+		})
+	}
+}
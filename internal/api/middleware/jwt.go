@@ -0,0 +1,385 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/internal/config"
+)
+
+// Default claim names used when config.JWTClaimsConfig leaves them empty.
+const (
+	defaultUserClaim   = "sub"
+	defaultRolesClaim  = "roles"
+	defaultTenantClaim = "tenant_id"
+	defaultScopesClaim = "scope"
+)
+
+const (
+	// jwksRefreshInterval is how long fetched JWKS keys are used before the
+	// document is fetched again.
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval bounds how often a token with an unknown kid can
+	// trigger a fetch, so forged kids cannot hammer the JWKS endpoint.
+	jwksMinRefreshInterval = time.Minute
+)
+
+// JWTPrincipal is the identity carried by a validated JWT.
+type JWTPrincipal struct {
+	UserID uuid.UUID
+	Roles  []string
+	// TenantID is uuid.Nil when the token has no tenant claim.
+	TenantID uuid.UUID
+	// Scopes is only meaningful when HasScopes is true, i.e. the token carries
+	// a scope claim. Tokens without one are not restricted by scopes.
+	Scopes    []string
+	HasScopes bool
+}
+
+// JWTVerifier validates JWT bearer tokens against the keys configured in
+// config.JWTConfig, without a database round-trip. It checks the signature,
+// exp (required), nbf, iss and aud, and maps the configured claims to a
+// JWTPrincipal.
+type JWTVerifier struct {
+	parser *jwt.Parser
+	secret []byte
+	key    crypto.PublicKey
+	jwks   *jwkSet
+	claims config.JWTClaimsConfig
+}
+
+// NewJWTVerifier builds a JWTVerifier from cfg. It returns nil when JWT is not
+// configured (cfg.Enabled is false). Exactly one key source must be set.
+func NewJWTVerifier(cfg config.JWTConfig) (*JWTVerifier, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	sources := 0
+	for _, s := range []string{cfg.Secret, cfg.PublicKeyFile, cfg.JWKSURL, cfg.JWKSFile} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("jwt: set only one of secret, public_key_file, jwks_url and jwks_file")
+	}
+
+	v := &JWTVerifier{claims: cfg.Claims}
+	if v.claims.User == "" {
+		v.claims.User = defaultUserClaim
+	}
+	if v.claims.Roles == "" {
+		v.claims.Roles = defaultRolesClaim
+	}
+	if v.claims.Tenant == "" {
+		v.claims.Tenant = defaultTenantClaim
+	}
+	if v.claims.Scopes == "" {
+		v.claims.Scopes = defaultScopesClaim
+	}
+
+	var methods []string
+	switch {
+	case cfg.Secret != "":
+		v.secret = []byte(cfg.Secret)
+		methods = []string{jwt.SigningMethodHS256.Alg()}
+	case cfg.PublicKeyFile != "":
+		key, err := loadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("jwt.public_key_file: %w", err)
+		}
+		v.key = key
+		methods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	case cfg.JWKSFile != "":
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("jwt.jwks_file: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt.jwks_file: %w", err)
+		}
+		v.jwks = &jwkSet{keys: keys}
+		methods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	default:
+		v.jwks = &jwkSet{url: cfg.JWKSURL, client: &http.Client{Timeout: 10 * time.Second}}
+		methods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	}
+
+	if cfg.Algorithm != "" {
+		var alg jwt.SigningMethod
+		switch strings.ToUpper(cfg.Algorithm) {
+		case "HS256":
+			alg = jwt.SigningMethodHS256
+		case "RS256":
+			alg = jwt.SigningMethodRS256
+		case "EDDSA":
+			alg = jwt.SigningMethodEdDSA
+		default:
+			return nil, fmt.Errorf("jwt.algorithm: unsupported algorithm %q (expected HS256, RS256 or EdDSA)", cfg.Algorithm)
+		}
+		if (v.secret != nil) != (alg == jwt.SigningMethodHS256) {
+			return nil, fmt.Errorf("jwt.algorithm: %s cannot be used with the configured key", cfg.Algorithm)
+		}
+		methods = []string{alg.Alg()}
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Leeway != "" {
+		leeway, err := parseDuration(cfg.Leeway)
+		if err != nil {
+			return nil, fmt.Errorf("jwt.leeway: %w", err)
+		}
+		opts = append(opts, jwt.WithLeeway(leeway))
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify validates raw and returns the principal named by its claims.
+func (v *JWTVerifier) Verify(ctx context.Context, raw string) (*JWTPrincipal, error) {
+	token, err := v.parser.Parse(raw, func(t *jwt.Token) (any, error) {
+		switch {
+		case v.secret != nil:
+			return v.secret, nil
+		case v.key != nil:
+			return v.key, nil
+		default:
+			kid, _ := t.Header["kid"].(string)
+			return v.jwks.key(ctx, kid)
+		}
+	})
+	if err != nil {
+		return nil, &authError{"invalid bearer token: " + err.Error()}
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, &authError{"invalid bearer token: unexpected claims"}
+	}
+
+	var p JWTPrincipal
+	sub, _ := claims[v.claims.User].(string)
+	if p.UserID, err = uuid.Parse(sub); err != nil {
+		return nil, &authError{fmt.Sprintf("invalid bearer token: %q claim is not a user ID", v.claims.User)}
+	}
+	p.Roles, _ = stringsClaim(claims, v.claims.Roles)
+	if tenant, ok := claims[v.claims.Tenant].(string); ok && tenant != "" {
+		if p.TenantID, err = uuid.Parse(tenant); err != nil {
+			return nil, &authError{fmt.Sprintf("invalid bearer token: %q claim is not a tenant ID", v.claims.Tenant)}
+		}
+	}
+	p.Scopes, p.HasScopes = stringsClaim(claims, v.claims.Scopes)
+
+	return &p, nil
+}
+
+// isJWT reports whether a bearer token has the three dot-separated segments
+// of a compact JWT. Opaque tokens from auth.GenerateToken never contain dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// stringsClaim reads a claim holding either a list of strings or a single
+// space-separated string (as in the OAuth "scope" claim). ok is false when the
+// claim is absent.
+func stringsClaim(claims jwt.MapClaims, name string) (values []string, ok bool) {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(v), true
+	case []any:
+		values = make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values, true
+	default:
+		return nil, false
+	}
+}
+
+// loadPublicKey reads a PEM-encoded RSA or Ed25519 public key, in PKIX,
+// PKCS #1 or certificate form.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key crypto.PublicKey
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (expected RSA or Ed25519)", key)
+	}
+}
+
+// jwkSet holds the signing keys of a JWKS document keyed by kid. Keys loaded
+// from a file are fixed; keys from a URL are refetched periodically and when
+// a token names an unknown kid.
+type jwkSet struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// key returns the key for kid. A token without a kid is accepted only when the
+// set holds exactly one key.
+func (s *jwkSet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.url != "" {
+		_, known := s.keys[kid]
+		age := time.Since(s.fetched)
+		if s.keys == nil || age > jwksRefreshInterval || (!known && age > jwksMinRefreshInterval) {
+			keys, err := s.fetch(ctx)
+			switch {
+			case err == nil:
+				s.keys, s.fetched = keys, time.Now()
+			case s.keys == nil:
+				return nil, err
+			default:
+				// Keep serving the previous keys through a JWKS outage.
+				slog.Warn("failed to refresh JWKS", "url", s.url, "err", err)
+			}
+		}
+	}
+
+	if k, ok := s.keys[kid]; ok {
+		return k, nil
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetch downloads and parses the JWKS document.
+func (s *jwkSet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return parseJWKS(data)
+}
+
+// jsonWebKey is the subset of RFC 7517 fields needed for RSA and Ed25519
+// signature keys.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+}
+
+// parseJWKS decodes the RSA and Ed25519 signing keys of a JWKS document.
+// Encryption keys and other key types are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 {
+				return nil, fmt.Errorf("parse JWKS: invalid RSA key %q", k.Kid)
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("parse JWKS: invalid Ed25519 key %q", k.Kid)
+			}
+			keys[k.Kid] = ed25519.PublicKey(x)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("parse JWKS: no RSA or Ed25519 signing keys")
+	}
+	return keys, nil
+}
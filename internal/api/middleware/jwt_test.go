@@ -0,0 +1,424 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+var (
+	testUserID   = uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	testTenantID = uuid.MustParse("3f2b8c1e-5d4a-4b7e-9c6f-1a2b3c4d5e6f")
+)
+
+// validClaims returns claims accepted by a verifier configured with issuer
+// "https://issuer.test" and audience "forge-api".
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":       testUserID.String(),
+		"iss":       "https://issuer.test",
+		"aud":       "forge-api",
+		"iat":       now.Unix(),
+		"nbf":       now.Add(-time.Minute).Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"roles":     []string{"admin", "editor"},
+		"tenant_id": testTenantID.String(),
+		"scope":     "products:read products:write",
+	}
+}
+
+func signHS256(t *testing.T, claims jwt.MapClaims, secret string) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func newVerifier(t *testing.T, cfg config.JWTConfig) *JWTVerifier {
+	t.Helper()
+	v, err := NewJWTVerifier(cfg)
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	return v
+}
+
+func writePEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func ed25519JWK(kid string, pub ed25519.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"kid": kid,
+		"x":   base64.RawURLEncoding.EncodeToString(pub),
+	}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestJWTVerifier_HS256Claims(t *testing.T) {
+	v := newVerifier(t, config.JWTConfig{
+		Secret:   testSecret,
+		Issuer:   "https://issuer.test",
+		Audience: "forge-api",
+	})
+
+	p, err := v.Verify(context.Background(), signHS256(t, validClaims(), testSecret))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.UserID != testUserID {
+		t.Errorf("UserID = %s, want %s", p.UserID, testUserID)
+	}
+	if !slices.Equal(p.Roles, []string{"admin", "editor"}) {
+		t.Errorf("Roles = %v", p.Roles)
+	}
+	if p.TenantID != testTenantID {
+		t.Errorf("TenantID = %s, want %s", p.TenantID, testTenantID)
+	}
+	if !p.HasScopes || !slices.Equal(p.Scopes, []string{"products:read", "products:write"}) {
+		t.Errorf("Scopes = %v (HasScopes %v)", p.Scopes, p.HasScopes)
+	}
+}
+
+func TestJWTVerifier_CustomClaims(t *testing.T) {
+	v := newVerifier(t, config.JWTConfig{
+		Secret: testSecret,
+		Claims: config.JWTClaimsConfig{User: "uid", Roles: "role", Tenant: "org", Scopes: "scp"},
+	})
+	claims := jwt.MapClaims{
+		"uid":  testUserID.String(),
+		"role": "admin",
+		"org":  testTenantID.String(),
+		"scp":  []string{"products:read"},
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}
+
+	p, err := v.Verify(context.Background(), signHS256(t, claims, testSecret))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.UserID != testUserID || p.TenantID != testTenantID {
+		t.Errorf("principal = %+v", p)
+	}
+	if !slices.Equal(p.Roles, []string{"admin"}) || !slices.Equal(p.Scopes, []string{"products:read"}) {
+		t.Errorf("Roles = %v, Scopes = %v", p.Roles, p.Scopes)
+	}
+}
+
+func TestJWTVerifier_Rejects(t *testing.T) {
+	v := newVerifier(t, config.JWTConfig{
+		Secret:   testSecret,
+		Issuer:   "https://issuer.test",
+		Audience: "forge-api",
+	})
+
+	tests := []struct {
+		name   string
+		mutate func(jwt.MapClaims)
+		secret string
+	}{
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, testSecret},
+		{"missing exp", func(c jwt.MapClaims) { delete(c, "exp") }, testSecret},
+		{"not yet valid", func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, testSecret},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.test" }, testSecret},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other-api" }, testSecret},
+		{"wrong secret", func(jwt.MapClaims) {}, "another-secret-another-secret-xx"},
+		{"subject not a UUID", func(c jwt.MapClaims) { c["sub"] = "alice" }, testSecret},
+		{"tenant not a UUID", func(c jwt.MapClaims) { c["tenant_id"] = "acme" }, testSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+			if _, err := v.Verify(context.Background(), signHS256(t, claims, tt.secret)); err == nil {
+				t.Fatal("Verify succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_Leeway(t *testing.T) {
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-10 * time.Second).Unix()
+	token := signHS256(t, claims, testSecret)
+
+	strict := newVerifier(t, config.JWTConfig{Secret: testSecret})
+	if _, err := strict.Verify(context.Background(), token); err == nil {
+		t.Error("Verify without leeway succeeded for an expired token")
+	}
+	lenient := newVerifier(t, config.JWTConfig{Secret: testSecret, Leeway: "30s"})
+	if _, err := lenient.Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify with leeway: %v", err)
+	}
+}
+
+func TestJWTVerifier_RS256PublicKeyFile(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writePEM(t, &priv.PublicKey)
+	v := newVerifier(t, config.JWTConfig{PublicKeyFile: path, Algorithm: "RS256"})
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, validClaims(), "", priv)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// A token HMAC-signed with the public key must not be accepted
+	// (algorithm confusion).
+	pemBytes, _ := os.ReadFile(path)
+	forged := sign(t, jwt.SigningMethodHS256, validClaims(), "", pemBytes)
+	if _, err := v.Verify(context.Background(), forged); err == nil {
+		t.Error("Verify accepted an HS256 token signed with the public key")
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, validClaims(), "", other)); err == nil {
+		t.Error("Verify accepted a token signed by another key")
+	}
+}
+
+func TestJWTVerifier_EdDSAPublicKeyFile(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVerifier(t, config.JWTConfig{PublicKeyFile: writePEM(t, pub)})
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodEdDSA, validClaims(), "", priv)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestJWTVerifier_JWKSFile(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(t, rsaJWK("k1", &priv.PublicKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v := newVerifier(t, config.JWTConfig{JWKSFile: path})
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, validClaims(), "k1", priv)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	// A single-key set also accepts tokens without a kid.
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, validClaims(), "", priv)); err != nil {
+		t.Fatalf("Verify without kid: %v", err)
+	}
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, validClaims(), "k2", priv)); err == nil {
+		t.Error("Verify accepted a token with an unknown kid")
+	}
+}
+
+func TestJWTVerifier_JWKSServerRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		fetches atomic.Int32
+		rotated atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []map[string]string{rsaJWK("rsa-1", &rsaKey.PublicKey)}
+		if rotated.Load() {
+			keys = append(keys, ed25519JWK("ed-1", edPub))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jwksJSON(t, keys...)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	v := newVerifier(t, config.JWTConfig{JWKSURL: srv.URL})
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, sign(t, jwt.SigningMethodRS256, validClaims(), "rsa-1", rsaKey)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := v.Verify(ctx, sign(t, jwt.SigningMethodRS256, validClaims(), "rsa-1", rsaKey)); err != nil {
+		t.Fatalf("Verify (cached): %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	// A new key published by the issuer is picked up once the minimum refresh
+	// interval has passed.
+	rotated.Store(true)
+	edToken := sign(t, jwt.SigningMethodEdDSA, validClaims(), "ed-1", edPriv)
+	if _, err := v.Verify(ctx, edToken); err == nil {
+		t.Fatal("Verify accepted an unknown kid before the refresh interval")
+	}
+	v.jwks.mu.Lock()
+	v.jwks.fetched = time.Now().Add(-2 * jwksMinRefreshInterval)
+	v.jwks.mu.Unlock()
+	if _, err := v.Verify(ctx, edToken); err != nil {
+		t.Fatalf("Verify after rotation: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestNewJWTVerifier_Config(t *testing.T) {
+	if v, err := NewJWTVerifier(config.JWTConfig{}); v != nil || err != nil {
+		t.Errorf("NewJWTVerifier(empty) = %v, %v; want nil, nil", v, err)
+	}
+
+	invalid := []config.JWTConfig{
+		{Secret: testSecret, JWKSURL: "https://issuer.test/jwks.json"},
+		{Secret: testSecret, Algorithm: "RS256"},
+		{JWKSURL: "https://issuer.test/jwks.json", Algorithm: "HS256"},
+		{Secret: testSecret, Algorithm: "none"},
+		{Secret: testSecret, Leeway: "soon"},
+		{PublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewJWTVerifier(cfg); err == nil {
+			t.Errorf("NewJWTVerifier(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestAuthMiddleware_JWT(t *testing.T) {
+	_, api := humatest.New(t)
+	v := newVerifier(t, config.JWTConfig{Secret: testSecret})
+	api.UseMiddleware(NewAuthMiddleware(api, nil, nil, v).Handle)
+
+	type whoami struct {
+		Body struct {
+			UserID string   `json:"user_id"`
+			Roles  []string `json:"roles"`
+			Tenant string   `json:"tenant"`
+			Scopes []string `json:"scopes"`
+		}
+	}
+	huma.Register(api, huma.Operation{
+		OperationID: "list-products",
+		Method:      http.MethodGet,
+		Path:        "/products",
+		Security:    []map[string][]string{{auth.SecuritySchemeAPIKey: {"products:read"}}},
+	}, func(ctx context.Context, _ *struct{}) (*whoami, error) {
+		out := &whoami{}
+		out.Body.UserID = auth.UserFromContext(ctx).String()
+		out.Body.Roles = auth.RolesFromContext(ctx)
+		tenant, _ := auth.TenantFromContext(ctx)
+		out.Body.Tenant = tenant.String()
+		out.Body.Scopes = auth.ScopesFromContext(ctx)
+		return out, nil
+	})
+
+	resp := api.Get("/products", "Authorization: Bearer "+signHS256(t, validClaims(), testSecret))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.Code, resp.Body.String())
+	}
+	body := resp.Body.String()
+	for _, want := range []string{testUserID.String(), testTenantID.String(), `"admin"`, `"products:read"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response %s missing %s", body, want)
+		}
+	}
+
+	// A token whose scope claim lacks the operation's scope is forbidden.
+	claims := validClaims()
+	claims["scope"] = "orders:read"
+	if resp := api.Get("/products", "Authorization: Bearer "+signHS256(t, claims, testSecret)); resp.Code != http.StatusForbidden {
+		t.Errorf("missing scope: status = %d, want 403", resp.Code)
+	}
+
+	// Tokens without a scope claim are not restricted by scopes.
+	delete(claims, "scope")
+	if resp := api.Get("/products", "Authorization: Bearer "+signHS256(t, claims, testSecret)); resp.Code != http.StatusOK {
+		t.Errorf("no scope claim: status = %d, want 200", resp.Code)
+	}
+
+	// Opaque tokens are rejected when no token store is configured.
+	if resp := api.Get("/products", "Authorization: Bearer opaque-token"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("opaque token: status = %d, want 401", resp.Code)
+	}
+
+	claims = validClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	if resp := api.Get("/products", "Authorization: Bearer "+signHS256(t, claims, testSecret)); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expired token: status = %d, want 401", resp.Code)
+	}
+}
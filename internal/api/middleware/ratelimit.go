@@ -1,47 +1,240 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sethvargo/go-limiter"
 	"github.com/sethvargo/go-limiter/httplimit"
 	"github.com/sethvargo/go-limiter/memorystore"
+
 	"github.com/alternayte/forge/internal/config"
 )
 
-// RateLimitMiddleware returns an HTTP middleware that enforces per-IP token
-// bucket rate limiting using go-limiter. If config.Enabled is false, a no-op
-// pass-through middleware is returned.
-//
-// The middleware automatically sets X-RateLimit-Limit, X-RateLimit-Remaining,
-// and X-RateLimit-Reset response headers. Requests that exceed the limit
-// receive HTTP 429 Too Many Requests.
-func RateLimitMiddleware(cfg config.RateLimitConfig) (func(http.Handler) http.Handler, error) {
+// RateLimitTier is a parsed config.TierConfig: Tokens requests per Interval.
+type RateLimitTier struct {
+	Tokens   uint64
+	Interval time.Duration
+}
+
+// RateLimitStore records requests against a key and reports whether the key is
+// still within its tier. Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take consumes one request for key under tier. It returns the number of
+	// requests remaining in the current window, when the window resets, and
+	// whether the request is allowed.
+	Take(ctx context.Context, key string, tier RateLimitTier) (remaining uint64, reset time.Time, ok bool, err error)
+}
+
+// RateLimitPruner is implemented by a RateLimitStore whose expired counters
+// must be deleted explicitly. forge calls PruneRateLimits periodically.
+type RateLimitPruner interface {
+	PruneRateLimits(ctx context.Context) error
+}
+
+// RateLimiter enforces rate limits on Huma operations in two stages. HandleIP
+// runs before AuthMiddleware and limits every request per client IP under the
+// IP tier, so requests with invalid credentials are bounded too. Handle runs
+// after it: requests authenticated with an API key are limited per key under
+// the APIKey tier, bearer-token requests per user under the Authenticated
+// tier, and all other requests per client IP under the Default tier.
+// Operations listed in config.RateLimitConfig.Operations use their own tier
+// and counter.
+type RateLimiter struct {
+	api        huma.API
+	store      RateLimitStore
+	enabled    bool
+	ip         RateLimitTier
+	def        RateLimitTier
+	authed     RateLimitTier
+	apiKey     RateLimitTier
+	operations map[string]RateLimitTier
+}
+
+// NewRateLimiter parses the configured tiers and returns a RateLimiter backed
+// by store. A nil store falls back to NewMemoryRateLimitStore. Tiers left unset
+// in forge.toml use the values from config.DefaultAPIConfig.
+func NewRateLimiter(api huma.API, cfg config.RateLimitConfig, store RateLimitStore) (*RateLimiter, error) {
+	rl := &RateLimiter{api: api, store: store, enabled: cfg.Enabled}
 	if !cfg.Enabled {
-		noop := func(next http.Handler) http.Handler { return next }
-		return noop, nil
+		return rl, nil
+	}
+	if rl.store == nil {
+		rl.store = NewMemoryRateLimitStore()
 	}
 
-	interval, err := parseDuration(cfg.Default.Interval)
-	if err != nil {
-		return nil, fmt.Errorf("rate_limit.default.interval: %w", err)
+	defaults := config.DefaultAPIConfig().RateLimit
+	var err error
+	if rl.ip, err = parseTier(cfg.IP, defaults.IP); err != nil {
+		return nil, fmt.Errorf("rate_limit.ip: %w", err)
+	}
+	if rl.def, err = parseTier(cfg.Default, defaults.Default); err != nil {
+		return nil, fmt.Errorf("rate_limit.default: %w", err)
+	}
+	if rl.authed, err = parseTier(cfg.Authenticated, defaults.Authenticated); err != nil {
+		return nil, fmt.Errorf("rate_limit.authenticated: %w", err)
+	}
+	if rl.apiKey, err = parseTier(cfg.APIKey, defaults.APIKey); err != nil {
+		return nil, fmt.Errorf("rate_limit.api_key: %w", err)
+	}
+	rl.operations = make(map[string]RateLimitTier, len(cfg.Operations))
+	for opID, tc := range cfg.Operations {
+		tier, err := parseTier(tc, defaults.Default)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit.operations.%s: %w", opID, err)
+		}
+		rl.operations[opID] = tier
 	}
+	return rl, nil
+}
 
-	store, err := memorystore.New(&memorystore.Config{
-		Tokens:   cfg.Default.Tokens,
-		Interval: interval,
-	})
+// ValidateOperations returns an error for any operation override naming an
+// operation that is not registered on the API, so a misspelt ID does not
+// silently leave the operation on the caller's tier. Call it after the routes
+// are registered.
+func (rl *RateLimiter) ValidateOperations() error {
+	if len(rl.operations) == 0 {
+		return nil
+	}
+	known := make(map[string]bool)
+	if oapi := rl.api.OpenAPI(); oapi != nil {
+		for _, item := range oapi.Paths {
+			for _, op := range []*huma.Operation{item.Get, item.Put, item.Post, item.Delete, item.Options, item.Head, item.Patch, item.Trace} {
+				if op != nil {
+					known[op.OperationID] = true
+				}
+			}
+		}
+	}
+	var unknown []string
+	for opID := range rl.operations {
+		if !known[opID] {
+			unknown = append(unknown, opID)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	ids := slices.Sorted(maps.Keys(known))
+	slices.Sort(unknown)
+	return fmt.Errorf("rate_limit.operations: unknown operation ID %s; valid IDs: %s",
+		strings.Join(unknown, ", "), strings.Join(ids, ", "))
+}
+
+// HandleIP implements the Huma middleware interface for the stage before
+// AuthMiddleware. It counts every request per client IP under the IP tier.
+func (rl *RateLimiter) HandleIP(ctx huma.Context, next func(huma.Context)) {
+	if !rl.enabled || rl.take(ctx, "client:"+clientIP(ctx.RemoteAddr()), rl.ip) {
+		next(ctx)
+	}
+}
+
+// Handle implements the Huma middleware interface for the stage after
+// AuthMiddleware. It counts the request against the caller's identity tier.
+func (rl *RateLimiter) Handle(ctx huma.Context, next func(huma.Context)) {
+	if !rl.enabled {
+		next(ctx)
+		return
+	}
+	key, tier := rl.identify(ctx)
+	if rl.take(ctx, key, tier) {
+		next(ctx)
+	}
+}
+
+// take counts a request for key under tier and reports whether it may
+// proceed. It sets X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset, which a later stage overwrites. Requests over the limit
+// receive 429 Too Many Requests with a Retry-After header and an RFC 9457
+// problem body. If the store fails, the request is allowed through so a
+// database outage does not take the API down with it.
+func (rl *RateLimiter) take(ctx huma.Context, key string, tier RateLimitTier) bool {
+	remaining, reset, ok, err := rl.store.Take(ctx.Context(), key, tier)
 	if err != nil {
-		return nil, fmt.Errorf("rate limit store: %w", err)
+		slog.Error("rate limit store failed", "key", key, "err", err)
+		return true
+	}
+
+	ctx.SetHeader(httplimit.HeaderRateLimitLimit, strconv.FormatUint(tier.Tokens, 10))
+	ctx.SetHeader(httplimit.HeaderRateLimitRemaining, strconv.FormatUint(remaining, 10))
+	ctx.SetHeader(httplimit.HeaderRateLimitReset, reset.UTC().Format(time.RFC1123))
+
+	if !ok {
+		retryAfter := int64(math.Ceil(time.Until(reset).Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		ctx.SetHeader(httplimit.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+		huma.WriteErr(rl.api, ctx, http.StatusTooManyRequests, //nolint:errcheck
+			fmt.Sprintf("Rate limit of %d requests per %s exceeded; retry in %d seconds", tier.Tokens, tier.Interval, retryAfter))
+		return false
+	}
+	return true
+}
+
+// identify returns the counter key and tier for the request. API keys take
+// precedence over bearer tokens, which take precedence over the client IP.
+func (rl *RateLimiter) identify(ctx huma.Context) (string, RateLimitTier) {
+	var (
+		key  string
+		tier RateLimitTier
+	)
+	if id := ctx.Context().Value(ContextKeyAPIKeyID); id != nil {
+		key, tier = fmt.Sprintf("api_key:%v", id), rl.apiKey
+	} else if id := ctx.Context().Value(ContextKeyUserID); id != nil {
+		key, tier = fmt.Sprintf("user:%v", id), rl.authed
+	} else {
+		key, tier = "ip:"+clientIP(ctx.RemoteAddr()), rl.def
+	}
+
+	if op := ctx.Operation(); op != nil {
+		if override, ok := rl.operations[op.OperationID]; ok {
+			return "op:" + op.OperationID + ":" + key, override
+		}
 	}
+	return key, tier
+}
 
-	m, err := httplimit.NewMiddleware(store, httplimit.IPKeyFunc())
+// clientIP strips the port from a RemoteAddr. RealIP has already replaced
+// RemoteAddr with the forwarded client address for trusted proxies.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
 	if err != nil {
-		return nil, fmt.Errorf("rate limit middleware: %w", err)
+		return remoteAddr
 	}
+	return host
+}
 
-	return m.Handle, nil
+// parseTier converts a TierConfig into a RateLimitTier, filling an unset
+// Tokens or Interval from fallback.
+func parseTier(tc, fallback config.TierConfig) (RateLimitTier, error) {
+	if tc.Tokens == 0 {
+		tc.Tokens = fallback.Tokens
+	}
+	if tc.Interval == "" {
+		tc.Interval = fallback.Interval
+	}
+	interval, err := parseDuration(tc.Interval)
+	if err != nil {
+		return RateLimitTier{}, fmt.Errorf("interval: %w", err)
+	}
+	if interval <= 0 {
+		return RateLimitTier{}, fmt.Errorf("interval: must be positive, got %q", tc.Interval)
+	}
+	return RateLimitTier{Tokens: tc.Tokens, Interval: interval}, nil
 }
 
 // parseDuration wraps time.ParseDuration with a friendlier error message for
@@ -54,3 +247,108 @@ func parseDuration(s string) (time.Duration, error) {
 	}
 	return d, nil
 }
+
+// memoryRateLimitStore keeps a go-limiter token bucket store per tier in
+// process memory. Counters are not shared between app instances.
+type memoryRateLimitStore struct {
+	mu     sync.Mutex
+	stores map[RateLimitTier]limiter.Store
+}
+
+// NewMemoryRateLimitStore returns an in-process RateLimitStore. It is the
+// default and suits single-instance deployments.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{stores: make(map[RateLimitTier]limiter.Store)}
+}
+
+// Take implements RateLimitStore using a token bucket per key.
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, tier RateLimitTier) (uint64, time.Time, bool, error) {
+	store, err := s.storeFor(tier)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	_, remaining, reset, ok, err := store.Take(ctx, key)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	return remaining, time.Unix(0, int64(reset)), ok, nil
+}
+
+// storeFor returns the bucket store for tier, creating it on first use.
+func (s *memoryRateLimitStore) storeFor(tier RateLimitTier) (limiter.Store, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if store, ok := s.stores[tier]; ok {
+		return store, nil
+	}
+	store, err := memorystore.New(&memorystore.Config{
+		Tokens:   tier.Tokens,
+		Interval: tier.Interval,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rate limit store: %w", err)
+	}
+	s.stores[tier] = store
+	return store, nil
+}
+
+// postgresRateLimitStore counts requests in the rate_limits table so limits
+// hold across every app instance sharing the database.
+type postgresRateLimitStore struct {
+	pool *pgxpool.Pool
+	// longest is the longest tier interval taken so far, in nanoseconds; a
+	// row older than that belongs to a window that has ended.
+	longest atomic.Int64
+}
+
+// NewPostgresRateLimitStore returns a RateLimitStore backed by the rate_limits
+// table generated in schema.hcl. It uses a fixed window per key: the counter
+// resets at each multiple of the tier interval.
+func NewPostgresRateLimitStore(pool *pgxpool.Pool) RateLimitStore {
+	return &postgresRateLimitStore{pool: pool}
+}
+
+// takeSQL increments the counter for the current window in a single
+// statement, resetting it when the stored row belongs to an earlier window.
+const takeSQL = `
+INSERT INTO rate_limits (key, window_start, count)
+VALUES ($1, $2, 1)
+ON CONFLICT (key) DO UPDATE SET
+	count = CASE WHEN rate_limits.window_start = EXCLUDED.window_start
+		THEN rate_limits.count + 1 ELSE 1 END,
+	window_start = EXCLUDED.window_start
+RETURNING count`
+
+// Take implements RateLimitStore using a fixed-window counter per key.
+func (s *postgresRateLimitStore) Take(ctx context.Context, key string, tier RateLimitTier) (uint64, time.Time, bool, error) {
+	windowStart := time.Now().Truncate(tier.Interval)
+	reset := windowStart.Add(tier.Interval)
+	for longest := s.longest.Load(); int64(tier.Interval) > longest; longest = s.longest.Load() {
+		if s.longest.CompareAndSwap(longest, int64(tier.Interval)) {
+			break
+		}
+	}
+
+	var count int64
+	if err := s.pool.QueryRow(ctx, takeSQL, key, windowStart).Scan(&count); err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("rate limit take: %w", err)
+	}
+	if uint64(count) > tier.Tokens {
+		return 0, reset, false, nil
+	}
+	return tier.Tokens - uint64(count), reset, true, nil
+}
+
+// PruneRateLimits implements RateLimitPruner. It deletes the counters whose
+// window ended before the longest tier interval seen by this process; until
+// a request has been counted it deletes nothing.
+func (s *postgresRateLimitStore) PruneRateLimits(ctx context.Context) error {
+	longest := time.Duration(s.longest.Load())
+	if longest == 0 {
+		return nil
+	}
+	if _, err := s.pool.Exec(ctx, `DELETE FROM rate_limits WHERE window_start < $1`, time.Now().Add(-longest)); err != nil {
+		return fmt.Errorf("prune rate limits: %w", err)
+	}
+	return nil
+}
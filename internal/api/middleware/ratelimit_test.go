@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+
+	"github.com/alternayte/forge/internal/config"
+)
+
+func TestRateLimiter_CountsFailedAuth(t *testing.T) {
+	_, api := humatest.New(t)
+	cfg := config.DefaultAPIConfig().RateLimit
+	cfg.IP = config.TierConfig{Tokens: 2, Interval: "1m"}
+	rl, err := NewRateLimiter(api, cfg, nil)
+	if err != nil {
+		t.Fatalf("NewRateLimiter: %v", err)
+	}
+	api.UseMiddleware(rl.HandleIP)
+	api.UseMiddleware(NewAuthMiddleware(api, nil, nil, newVerifier(t, config.JWTConfig{Secret: testSecret})).Handle)
+	api.UseMiddleware(rl.Handle)
+	huma.Register(api, huma.Operation{
+		OperationID: "listProducts",
+		Method:      http.MethodGet,
+		Path:        "/products",
+	}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+
+	// Guessed tokens are rejected by auth but still use up the IP's budget.
+	for i := 0; i < 2; i++ {
+		if resp := api.Get("/products", "Authorization: Bearer guess"); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status = %d, want 401", i, resp.Code)
+		}
+	}
+	if resp := api.Get("/products", "Authorization: Bearer guess"); resp.Code != http.StatusTooManyRequests {
+		t.Errorf("guess over the IP limit: status = %d, want 429", resp.Code)
+	}
+}
+
+func TestRateLimiter_ValidateOperations(t *testing.T) {
+	_, api := humatest.New(t)
+	huma.Register(api, huma.Operation{
+		OperationID: "createProduct",
+		Method:      http.MethodPost,
+		Path:        "/products",
+	}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+
+	cfg := config.DefaultAPIConfig().RateLimit
+	cfg.Operations = map[string]config.TierConfig{"createProduct": {Tokens: 10}}
+	rl, err := NewRateLimiter(api, cfg, nil)
+	if err != nil {
+		t.Fatalf("NewRateLimiter: %v", err)
+	}
+	if err := rl.ValidateOperations(); err != nil {
+		t.Errorf("ValidateOperations: %v", err)
+	}
+
+	cfg.Operations = map[string]config.TierConfig{"create-product": {Tokens: 10}}
+	rl, err = NewRateLimiter(api, cfg, nil)
+	if err != nil {
+		t.Fatalf("NewRateLimiter: %v", err)
+	}
+	if err := rl.ValidateOperations(); err == nil || !strings.Contains(err.Error(), "create-product") {
+		t.Errorf("ValidateOperations error = %v, want one naming create-product", err)
+	}
+}
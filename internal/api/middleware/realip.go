@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses server.trusted_proxies entries, each an IP
+// address ("10.0.0.1") or CIDR range ("10.0.0.0/8").
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		if p, err := netip.ParsePrefix(e); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: want an IP address or CIDR range", e)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// RealIP returns a Chi-compatible middleware that replaces r.RemoteAddr with
+// the client address reported by a trusted reverse proxy. The X-Forwarded-For
+// and X-Real-IP headers are honored only when the connection comes from one of
+// trusted; with no trusted proxies RemoteAddr is left alone, so clients cannot
+// pick the address that rate limits, login throttling and audit entries see.
+//
+// X-Forwarded-For is read from the right, skipping trusted proxies, so the
+// first untrusted hop is used and addresses a client prepends are ignored.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(s string) bool {
+		addr, err := netip.ParseAddr(strings.TrimSpace(s))
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(peer); err == nil {
+				peer = host
+			}
+			if isTrusted(peer) {
+				if ip := forwardedClient(r, isTrusted); ip != "" {
+					r.RemoteAddr = ip
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the client address in r's proxy headers: the
+// rightmost untrusted X-Forwarded-For entry, else X-Real-IP. It returns ""
+// when neither holds a valid address.
+func forwardedClient(r *http.Request, isTrusted func(string) bool) string {
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return ""
+		}
+		if !isTrusted(hop) || i == 0 {
+			return addr.Unmap().String()
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return ""
+}
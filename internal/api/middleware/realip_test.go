@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}{
+		{"no trusted proxies", false, "203.0.113.9:4000", "198.51.100.1", "", "203.0.113.9:4000"},
+		{"untrusted peer", true, "203.0.113.9:4000", "198.51.100.1", "", "203.0.113.9:4000"},
+		{"trusted peer", true, "10.1.2.3:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed hop ignored", true, "10.1.2.3:4000", "6.6.6.6, 198.51.100.1", "", "198.51.100.1"},
+		{"chained proxies", true, "192.0.2.1:4000", "198.51.100.1, 10.9.9.9", "", "198.51.100.1"},
+		{"x-real-ip", true, "10.1.2.3:4000", "", "198.51.100.7", "198.51.100.7"},
+		{"malformed header", true, "10.1.2.3:4000", "not-an-ip", "", "10.1.2.3:4000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies := trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			var got string
+			h := RealIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseTrustedProxies([]string{"proxy.internal"}); err == nil {
+		t.Error("ParseTrustedProxies accepted a hostname")
+	}
+}
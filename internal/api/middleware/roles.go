@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// RoleMiddleware loads the roles of the authenticated user with an
+// auth.RoleResolver and stores them via auth.WithUserRoles, and the user's
+// permission strings with an auth.PermissionResolver via auth.WithPermissions,
+// so generated Permission checks see them. It must run after AuthMiddleware, which sets the
+// user for bearer tokens and API keys, and after TenantMiddleware so the
+// resolver can look up tenant-specific roles.
+type RoleMiddleware struct {
+	api         huma.API
+	roles       auth.RoleResolver
+	permissions auth.PermissionResolver
+}
+
+// NewRoleMiddleware creates a RoleMiddleware using the given resolvers. Either
+// may be nil to skip loading roles or permissions; with both nil every request
+// passes through.
+func NewRoleMiddleware(api huma.API, roles auth.RoleResolver, permissions auth.PermissionResolver) *RoleMiddleware {
+	return &RoleMiddleware{api: api, roles: roles, permissions: permissions}
+}
+
+// Handle implements the Huma middleware interface. Requests without an
+// authenticated user pass through unchanged. Roles already in context, taken
+// from a JWT's claims, are kept alongside the resolved ones. A resolver error
+// is logged and answered with 500 Internal Server Error.
+func (m *RoleMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
+	if m.roles == nil && m.permissions == nil {
+		next(ctx)
+		return
+	}
+	userID := auth.UserFromContext(ctx.Context())
+	if userID == uuid.Nil {
+		next(ctx)
+		return
+	}
+
+	c := ctx.Context()
+	if m.roles != nil {
+		roles, err := m.roles(c, userID)
+		if err != nil {
+			slog.Error("role resolver failed", "user_id", userID, "err", err)
+			huma.WriteErr(m.api, ctx, http.StatusInternalServerError, "Could not load user roles") //nolint:errcheck
+			return
+		}
+		for _, role := range auth.RolesFromContext(c) {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+		c = auth.WithUserRoles(c, userID, roles...)
+	}
+	if m.permissions != nil {
+		permissions, err := m.permissions(c, userID)
+		if err != nil {
+			slog.Error("permission resolver failed", "user_id", userID, "err", err)
+			huma.WriteErr(m.api, ctx, http.StatusInternalServerError, "Could not load user permissions") //nolint:errcheck
+			return
+		}
+		c = auth.WithPermissions(c, permissions...)
+	}
+
+	next(huma.WithContext(ctx, c))
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+)
+
+// TenantMiddleware resolves the tenant of each API request and stores it in
+// the context via auth.WithTenant, where generated actions read it to scope
+// queries and set app.current_tenant. Requests whose tenant cannot be resolved
+// receive 401 Unauthorized, and requests for a suspended tenant 403 Forbidden,
+// as an RFC 9457 problem body.
+//
+// An authenticated principal must belong to the resolved tenant, or the
+// request receives 403: a tenant set by AuthMiddleware from a JWT claim must
+// match it, and any other user (opaque token or API key owner) must be
+// admitted by the TenantAuthorizer.
+type TenantMiddleware struct {
+	api       huma.API
+	resolver  auth.TenantResolver
+	authorize auth.TenantAuthorizer
+}
+
+// NewTenantMiddleware creates a TenantMiddleware using resolver and authorize.
+// A nil resolver disables tenant resolution and passes every request through,
+// keeping any tenant taken from a JWT claim. A nil authorize admits only users
+// whose JWT names the tenant.
+func NewTenantMiddleware(api huma.API, resolver auth.TenantResolver, authorize auth.TenantAuthorizer) *TenantMiddleware {
+	return &TenantMiddleware{api: api, resolver: resolver, authorize: authorize}
+}
+
+// Handle implements the Huma middleware interface.
+func (m *TenantMiddleware) Handle(ctx huma.Context, next func(huma.Context)) {
+	if m.resolver == nil {
+		next(ctx)
+		return
+	}
+
+	r, _ := humachi.Unwrap(ctx)
+	tenantID, err := m.resolver.Resolve(r)
+	if errors.Is(err, auth.ErrTenantSuspended) {
+		huma.WriteErr(m.api, ctx, http.StatusForbidden, "Tenant suspended", err) //nolint:errcheck
+		return
+	}
+	if err != nil {
+		huma.WriteErr(m.api, ctx, http.StatusUnauthorized, "Tenant not found", err) //nolint:errcheck
+		return
+	}
+	if claimed, ok := auth.TenantFromContext(ctx.Context()); ok {
+		if claimed != tenantID {
+			huma.WriteErr(m.api, ctx, http.StatusForbidden, "Credential is not valid for this tenant") //nolint:errcheck
+			return
+		}
+	} else if userID := auth.UserFromContext(ctx.Context()); userID != uuid.Nil {
+		err := auth.AuthorizeTenant(ctx.Context(), m.authorize, userID, tenantID)
+		if errors.Is(err, auth.ErrNotTenantMember) {
+			huma.WriteErr(m.api, ctx, http.StatusForbidden, "Credential is not valid for this tenant", err) //nolint:errcheck
+			return
+		}
+		if err != nil {
+			slog.Error("tenant authorizer failed", "err", err)
+			huma.WriteErr(m.api, ctx, http.StatusInternalServerError, "Could not check tenant access") //nolint:errcheck
+			return
+		}
+	}
+
+	next(huma.WithContext(ctx, auth.WithTenant(ctx.Context(), tenantID)))
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/internal/config"
+)
+
+func TestTenantMiddleware_Membership(t *testing.T) {
+	otherTenant := uuid.New()
+	member := func(_ context.Context, userID, tenantID uuid.UUID) (bool, error) {
+		return userID == testUserID && tenantID == testTenantID, nil
+	}
+
+	api := humatest.Wrap(t, humachi.New(chi.NewRouter(), huma.DefaultConfig("test", "1.0.0")))
+	api.UseMiddleware(NewAuthMiddleware(api, nil, nil, newVerifier(t, config.JWTConfig{Secret: testSecret})).Handle)
+	api.UseMiddleware(NewTenantMiddleware(api, auth.HeaderTenantResolver{}, member).Handle)
+	huma.Register(api, huma.Operation{
+		OperationID: "listProducts",
+		Method:      http.MethodGet,
+		Path:        "/products",
+	}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+
+	// A token without a tenant claim is checked with the authorizer.
+	claims := validClaims()
+	delete(claims, "tenant_id")
+	token := "Authorization: Bearer " + signHS256(t, claims, testSecret)
+
+	tests := []struct {
+		name   string
+		token  string
+		tenant uuid.UUID
+		want   int
+	}{
+		{"member", token, testTenantID, http.StatusNoContent},
+		{"not a member", token, otherTenant, http.StatusForbidden},
+		{"claim matches", "Authorization: Bearer " + signHS256(t, validClaims(), testSecret), testTenantID, http.StatusNoContent},
+		{"claim differs", "Authorization: Bearer " + signHS256(t, validClaims(), testSecret), otherTenant, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := api.Get("/products", tt.token, "X-Tenant-ID: "+tt.tenant.String())
+			if resp.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", resp.Code, tt.want, resp.Body.String())
+			}
+		})
+	}
+}
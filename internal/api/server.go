@@ -14,44 +14,46 @@ import (
 )
 
 // SetupAPI creates a Huma API instance on the provided Chi router, wires all middleware in
-// the correct order, then calls registerRoutes to register every generated resource endpoint.
+// the correct order, then calls cfg.RegisterRoutes to register every generated resource endpoint.
 //
 // Middleware order (per security and operational best-practices):
-//  1. Chi-level: RealIP — extract real client IP before any processing
-//  2. Chi-level: Logger — log every request with final status code
-//  3. Chi-level: Recovery (gen/middleware) — catch panics before they terminate the process
-//  4. Huma-level: CORS — set cross-origin headers before auth check
-//  5. Huma-level: RateLimit — enforce rate limits before expensive auth lookup
-//  6. Huma-level: Auth — validate bearer tokens / API keys
+//  1. Chi-level: Logger — log every request with final status code
+//  2. Chi-level: Recovery (gen/middleware) — catch panics before they terminate the process
+//  3. Huma-level: CORS — set cross-origin headers before auth check
+//  4. Huma-level: RateLimit (IP) — bound every client IP, including failed auth attempts
+//  5. Huma-level: Auth — validate bearer tokens (opaque or JWT) / API keys
+//  6. Huma-level: Tenant — resolve the request's tenant for TenantScoped resources
+//  7. Huma-level: Roles — load the authenticated user's roles for Permission checks
+//  8. Huma-level: RateLimit — enforce the tier for the authenticated identity
 //
-// After all middleware is wired, SetupAPI calls registerRoutes (i.e. genapi.RegisterAllRoutes)
+// After all middleware is wired, SetupAPI calls cfg.RegisterRoutes (i.e. genapi.RegisterAllRoutes)
 // to register all generated CRUD endpoints. Huma automatically serves the OpenAPI spec at
 // /api/openapi.json and /api/openapi.yaml because OpenAPIPath is set to "/api/openapi".
 // The Scalar UI docs handler must be registered separately via RegisterDocsHandler.
 //
 // Example wiring in a generated project's main.go:
 //
-//	api, err := apiserver.SetupAPI(router, cfg.API, tokenStore, apiKeyStore, gen_middleware.Recovery, genapi.RegisterAllRoutes)
-func SetupAPI(
-	router chi.Router,
-	cfg config.APIConfig,
-	tokenStore auth.TokenStore,
-	apiKeyStore auth.APIKeyStore,
-	recoveryMiddleware func(http.Handler) http.Handler,
-	registerRoutes func(api huma.API),
-) (huma.API, error) {
+//	api, err := apiserver.SetupAPI(router, api.APIServerConfig{
+//	    APIConfig:          cfg.API,
+//	    TokenStore:         tokenStore,
+//	    APIKeyStore:        apiKeyStore,
+//	    RecoveryMiddleware: gen_middleware.Recovery,
+//	    RegisterRoutes:     genapi.RegisterAllRoutes,
+//	})
+func SetupAPI(router chi.Router, cfg APIServerConfig) (huma.API, error) {
 	// --- Chi-level middleware (runs before Huma processes the request) ---
 
-	// 1. RealIP: extract real client IP from X-Real-IP / X-Forwarded-For headers.
-	//    Must be first so rate limiting and logging use the actual client address.
-	router.Use(chimiddleware.RealIP)
+	// The client address is resolved by forge's trusted-proxy RealIP, which
+	// App.Listen installs on the root router before SetupAPI runs.
 
-	// 2. Logger: log each request with method, path, status, latency, and remote address.
+	// 1. Logger: log each request with method, path, status, latency, and remote address.
 	router.Use(chimiddleware.Logger)
 
-	// 3. Recovery: catch panics and return HTTP 500 without crashing the server.
+	// 2. Recovery: catch panics and return HTTP 500 without crashing the server.
 	//    Uses the generated gen/middleware.Recovery that produces forge error shapes.
-	router.Use(recoveryMiddleware)
+	if cfg.RecoveryMiddleware != nil {
+		router.Use(cfg.RecoveryMiddleware)
+	}
 
 	// --- Huma API instance ---
 
@@ -61,35 +63,64 @@ func SetupAPI(
 	humaConfig.OpenAPIPath = "/api/openapi"
 	// Disable Huma's built-in CDN-hosted Stoplight Elements docs — we serve Scalar UI instead.
 	humaConfig.DocsPath = ""
+	// Security schemes referenced by generated operations. API key operations list
+	// their required scopes (e.g. "products:read"), enforced by the auth middleware.
+	humaConfig.Components.SecuritySchemes = map[string]*huma.SecurityScheme{
+		auth.SecuritySchemeBearer: {Type: "http", Scheme: "bearer"},
+		auth.SecuritySchemeAPIKey: {Type: "apiKey", In: "header", Name: "X-API-Key"},
+	}
 
 	api := humachi.New(router, humaConfig)
 
 	// --- Huma-level middleware (wraps individual operation handlers) ---
 
-	// 4. CORS: set cross-origin headers. Runs early so preflight OPTIONS requests short-circuit.
-	corsHandler := apimiddleware.CORSMiddleware(cfg.CORS)
+	// 3. CORS: set cross-origin headers. Runs early so preflight OPTIONS requests short-circuit.
+	corsHandler := apimiddleware.CORSMiddleware(cfg.APIConfig.CORS)
 	api.UseMiddleware(wrapHTTPMiddleware(corsHandler))
 
-	// 5. RateLimit: enforce per-IP token-bucket limits. Runs after CORS so OPTIONS pass through,
-	//    and before auth so unauthenticated probing is bounded.
-	rateLimitHandler, err := apimiddleware.RateLimitMiddleware(cfg.RateLimit)
+	// 4. RateLimit (IP): count every request per client IP before credentials are
+	//    checked, so API keys and bearer tokens cannot be guessed without limit.
+	rateLimiter, err := apimiddleware.NewRateLimiter(api, cfg.APIConfig.RateLimit, cfg.RateLimitStore)
 	if err != nil {
 		return nil, err
 	}
-	api.UseMiddleware(wrapHTTPMiddleware(rateLimitHandler))
+	api.UseMiddleware(rateLimiter.HandleIP)
 
-	// 6. Auth: validate bearer tokens and API keys. Must run last so auth context values
-	//    (user_id, api_key_id) are available to route handlers.
-	authMiddleware := apimiddleware.NewAuthMiddleware(api, tokenStore, apiKeyStore)
+	// 5. Auth: validate bearer tokens (opaque or JWT) and API keys, setting user_id /
+	//    api_key_id in context for the rate limiter and route handlers.
+	jwtVerifier, err := apimiddleware.NewJWTVerifier(cfg.APIConfig.JWT)
+	if err != nil {
+		return nil, err
+	}
+	authMiddleware := apimiddleware.NewAuthMiddleware(api, cfg.TokenStore, cfg.APIKeyStore, jwtVerifier)
 	api.UseMiddleware(authMiddleware.Handle)
 
+	// 6. Tenant: resolve the tenant (header, subdomain or path) into context so generated
+	//    actions can scope queries and set app.current_tenant for row-level security.
+	//    Runs after Auth so the principal's membership of the tenant can be checked.
+	tenantMiddleware := apimiddleware.NewTenantMiddleware(api, cfg.TenantResolver, cfg.TenantAuthorizer)
+	api.UseMiddleware(tenantMiddleware.Handle)
+
+	// 7. Roles: load the user's roles and permission strings (bearer token user or
+	//    API key owner) into the forge/auth context so generated Permission rules
+	//    apply. Runs after Tenant so grants can be tenant-specific.
+	roleMiddleware := apimiddleware.NewRoleMiddleware(api, cfg.RoleResolver, cfg.PermissionResolver)
+	api.UseMiddleware(roleMiddleware.Handle)
+
+	// 8. RateLimit: count requests per API key, user or client IP under the matching tier,
+	//    with per-operation overrides. Runs after auth so the identity is known.
+	api.UseMiddleware(rateLimiter.Handle)
+
 	// --- Route registration ---
 
 	// RegisterAllRoutes wires all generated CRUD endpoints onto the Huma API.
 	// This is called AFTER all middleware is wired so every endpoint inherits the
-	// complete middleware chain (CORS -> RateLimit -> Auth).
-	if registerRoutes != nil {
-		registerRoutes(api)
+	// complete middleware chain (CORS -> RateLimit (IP) -> Auth -> Tenant -> Roles -> RateLimit).
+	if cfg.RegisterRoutes != nil {
+		cfg.RegisterRoutes(api)
+	}
+	if err := rateLimiter.ValidateOperations(); err != nil {
+		return nil, err
 	}
 
 	// --- Documentation ---
@@ -101,7 +132,7 @@ func SetupAPI(
 }
 
 // wrapHTTPMiddleware converts a standard Go http.Handler middleware into a Huma middleware.
-// This bridges the gap between Chi-style http.Handler wrappers (CORS, rate limit) and
+// This bridges the gap between Chi-style http.Handler wrappers (e.g. CORS) and
 // Huma's context-based middleware interface.
 func wrapHTTPMiddleware(mw func(http.Handler) http.Handler) func(huma.Context, func(huma.Context)) {
 	return func(ctx huma.Context, next func(huma.Context)) {
@@ -115,12 +146,45 @@ func wrapHTTPMiddleware(mw func(http.Handler) http.Handler) func(huma.Context, f
 	}
 }
 
-// ServerConfig groups the configuration dependencies needed to set up the API server.
-// This is a convenience struct for applications that assemble the server from a single
-// configuration object rather than passing each dependency individually.
-type ServerConfig struct {
-	APIConfig   config.APIConfig
-	TokenStore  auth.TokenStore
+// APIServerConfig holds the dependencies required to set up the JSON API routes.
+// Only APIConfig and TokenStore or APIKeyStore are needed for a working API; the
+// remaining fields are optional.
+type APIServerConfig struct {
+	// APIConfig is the [api] section of forge.toml: CORS, rate limits and JWT
+	// verification settings.
+	APIConfig config.APIConfig
+
+	// TokenStore validates opaque bearer tokens. Nil rejects them.
+	TokenStore auth.TokenStore
+
+	// APIKeyStore validates X-API-Key headers. Nil rejects them.
 	APIKeyStore auth.APIKeyStore
-}
 
+	// RateLimitStore holds the rate limit counters. Nil keeps them in process
+	// memory.
+	RateLimitStore apimiddleware.RateLimitStore
+
+	// TenantResolver identifies each request's tenant for TenantScoped
+	// resources. Nil disables tenant resolution.
+	TenantResolver auth.TenantResolver
+
+	// TenantAuthorizer admits authenticated users to the resolved tenant. Nil
+	// admits only users whose JWT names it.
+	TenantAuthorizer auth.TenantAuthorizer
+
+	// RoleResolver loads the authenticated user's roles so generated
+	// Permission rules apply. Nil leaves them unset.
+	RoleResolver auth.RoleResolver
+
+	// PermissionResolver loads the authenticated user's permission strings.
+	// Nil leaves them unset.
+	PermissionResolver auth.PermissionResolver
+
+	// RecoveryMiddleware catches panics and renders them as forge errors,
+	// usually the generated gen/middleware.Recovery. Nil installs none.
+	RecoveryMiddleware func(http.Handler) http.Handler
+
+	// RegisterRoutes registers the generated resource endpoints, usually
+	// genapi.RegisterAllRoutes. It runs after every middleware is wired.
+	RegisterRoutes func(api huma.API)
+}
@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/cobra"
+
+	"github.com/alternayte/forge/forge/auth"
+	"github.com/alternayte/forge/forge/auth/pgstore"
+	"github.com/alternayte/forge/internal/config"
+	"github.com/alternayte/forge/internal/generator"
+	"github.com/alternayte/forge/internal/parser"
+	"github.com/alternayte/forge/internal/ui"
+)
+
+func newAPIKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys",
+		Long: `Create, list, and revoke API keys stored in the api_keys table.
+
+These commands connect to the database in forge.toml and use the same hashed
+storage as the built-in API key store, so keys work with the API as soon as
+they are created.`,
+	}
+
+	cmd.AddCommand(
+		newAPIKeyCreateCmd(),
+		newAPIKeyListCmd(),
+		newAPIKeyRevokeCmd(),
+	)
+
+	return cmd
+}
+
+func newAPIKeyCreateCmd() *cobra.Command {
+	var (
+		userFlag    string
+		nameFlag    string
+		scopesFlag  []string
+		expiresFlag string
+		testFlag    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key",
+		Long: `Create an API key for a user and print it.
+
+The key is stored as a hash and printed exactly once; copy it before closing the
+terminal. Keys are prefixed forg_live_, or forg_test_ with --test. Without
+--expires the key does not expire. --scopes must name scopes the generated API
+checks, e.g. products:read or products:write.
+
+Example:
+  forge apikey create --user 7c9e6679-7425-40de-944b-e07fc1f90ae7 --name ci --scopes products:read --expires 90d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID, err := uuid.Parse(userFlag)
+			if err != nil {
+				return fmt.Errorf("invalid --user %q: %w", userFlag, err)
+			}
+			var expiresAt *time.Time
+			if expiresFlag != "" {
+				d, err := parseExpires(expiresFlag)
+				if err != nil {
+					return err
+				}
+				t := time.Now().Add(d)
+				expiresAt = &t
+			}
+			if err := validateScopes(scopesFlag); err != nil {
+				return err
+			}
+			prefix := auth.PrefixLive
+			if testFlag {
+				prefix = auth.PrefixTest
+			}
+
+			ctx := cmd.Context()
+			pool, err := openAuthDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			key, err := pgstore.NewAPIKeyStore(pool).Create(ctx, userID, nameFlag, prefix, scopesFlag, expiresAt)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println()
+			fmt.Println(ui.Success("API key created: " + key.ID.String()))
+			fmt.Println()
+			fmt.Println("    " + key.Key)
+			fmt.Println()
+			fmt.Println(ui.Warn("Copy this key now. It is stored hashed and cannot be shown again."))
+			fmt.Println()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&userFlag, "user", "", "ID of the user the key acts as")
+	cmd.Flags().StringVar(&nameFlag, "name", "", "name to identify the key")
+	cmd.Flags().StringSliceVar(&scopesFlag, "scopes", nil, "scopes granted to the key (comma-separated, e.g. products:read)")
+	cmd.Flags().StringVar(&expiresFlag, "expires", "", "lifetime of the key, e.g. 90d or 720h (default: never)")
+	cmd.Flags().BoolVar(&testFlag, "test", false, "create a forg_test_ key instead of forg_live_")
+	_ = cmd.MarkFlagRequired("user")
+	_ = cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func newAPIKeyListCmd() *cobra.Command {
+	var userFlag string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Long: `List API keys, newest first, with their status and last use.
+
+Only the start of each key is shown; the full key cannot be recovered.
+
+Example:
+  forge apikey list
+  forge apikey list --user 7c9e6679-7425-40de-944b-e07fc1f90ae7`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID := uuid.Nil
+			if userFlag != "" {
+				var err error
+				if userID, err = uuid.Parse(userFlag); err != nil {
+					return fmt.Errorf("invalid --user %q: %w", userFlag, err)
+				}
+			}
+
+			ctx := cmd.Context()
+			pool, err := openAuthDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			keys, err := pgstore.NewAPIKeyStore(pool).List(ctx, userID)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println()
+			if len(keys) == 0 {
+				fmt.Println(ui.Info("No API keys found."))
+				fmt.Println()
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  ID\tNAME\tKEY\tUSER\tSCOPES\tEXPIRES\tLAST USED\tSTATUS")
+			for _, k := range keys {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					k.ID, k.Name, k.Lookup+"…", k.UserID,
+					strings.Join(k.Scopes, ","),
+					formatOptionalTime(k.ExpiresAt, "never"),
+					formatOptionalTime(k.LastUsedAt, "never"),
+					apiKeyStatus(k),
+				)
+			}
+			w.Flush()
+			fmt.Println()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&userFlag, "user", "", "only list keys of this user ID")
+
+	return cmd
+}
+
+func newAPIKeyRevokeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API key",
+		Long: `Revoke an API key by ID. Requests using it are rejected immediately.
+
+Example:
+  forge apikey revoke 3f2b8c1e-5d4a-4b7e-9c6f-1a2b3c4d5e6f`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyID, err := uuid.Parse(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid API key ID %q: %w", args[0], err)
+			}
+
+			ctx := cmd.Context()
+			pool, err := openAuthDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			if err := pgstore.NewAPIKeyStore(pool).Revoke(ctx, keyID); err != nil {
+				return err
+			}
+
+			fmt.Println()
+			fmt.Println(ui.Success("API key revoked: " + keyID.String()))
+			fmt.Println()
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// Helper functions
+
+// openAuthDB connects to the database configured in the project's forge.toml.
+func openAuthDB(ctx context.Context) (*pgxpool.Pool, error) {
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return nil, fmt.Errorf("not a forge project (forge.toml not found). Run 'forge init' first")
+	}
+
+	cfg, err := config.Load(filepath.Join(projectRoot, "forge.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load forge.toml: %w", err)
+	}
+
+	pool, err := pgxpool.New(ctx, cfg.Database.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return pool, nil
+}
+
+// validateScopes rejects scopes that no generated operation checks, so a typo
+// does not create a key that is forbidden everywhere. The known scopes come
+// from the project's schemas (see generator.APIScopes).
+func validateScopes(scopes []string) error {
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("not a forge project (forge.toml not found). Run 'forge init' first")
+	}
+	result, err := parser.ParseDir(filepath.Join(projectRoot, "resources"))
+	if err != nil {
+		return fmt.Errorf("failed to parse schemas: %w", err)
+	}
+	known := generator.APIScopes(result.Resources)
+	for _, scope := range scopes {
+		if !slices.Contains(known, scope) {
+			return fmt.Errorf("unknown scope %q; valid scopes: %s", scope, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// parseExpires parses a credential lifetime. It accepts Go durations ("720h")
+// and whole days ("90d").
+func parseExpires(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --expires %q: expected e.g. 90d or 720h", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid --expires %q: expected e.g. 90d or 720h", s)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid --expires %q: must be positive", s)
+	}
+	return d, nil
+}
+
+// formatOptionalTime formats t for tables, or returns none when t is nil.
+func formatOptionalTime(t *time.Time, none string) string {
+	if t == nil {
+		return none
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// apiKeyStatus reports whether k is active, revoked or expired.
+func apiKeyStatus(k auth.APIKey) string {
+	switch {
+	case k.RevokedAt != nil:
+		return "revoked"
+	case k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt):
+		return "expired"
+	default:
+		return "active"
+	}
+}
@@ -110,7 +110,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 
 	// Scaffold each resource's views and handlers (scaffold-once: skips existing files)
 	for _, resource := range result.Resources {
-		scaffoldResult, err := generator.ScaffoldResource(resource, projectRoot, cfg.Project.Module)
+		scaffoldResult, err := generator.ScaffoldResource(resource, result.Resources, projectRoot, cfg.Project.Module)
 		if err != nil {
 			return fmt.Errorf("scaffold %s failed: %w", resource.Name, err)
 		}
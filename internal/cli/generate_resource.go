@@ -88,7 +88,7 @@ func runGenerateResource(cmd *cobra.Command, args []string, diff bool) error {
 
 	if diff {
 		// Show diff without writing
-		output, err := generator.DiffResource(*target, projectRoot, cfg.Project.Module)
+		output, err := generator.DiffResource(*target, result.Resources, projectRoot, cfg.Project.Module)
 		if err != nil {
 			return fmt.Errorf("diff failed: %w", err)
 		}
@@ -97,7 +97,7 @@ func runGenerateResource(cmd *cobra.Command, args []string, diff bool) error {
 	}
 
 	// Scaffold the resource
-	scaffoldResult, err := generator.ScaffoldResource(*target, projectRoot, cfg.Project.Module)
+	scaffoldResult, err := generator.ScaffoldResource(*target, result.Resources, projectRoot, cfg.Project.Module)
 	if err != nil {
 		return fmt.Errorf("scaffold failed: %w", err)
 	}
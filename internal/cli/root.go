@@ -29,6 +29,8 @@ func init() {
 	rootCmd.AddCommand(newDBCmd())
 	rootCmd.AddCommand(newDevCmd())
 	rootCmd.AddCommand(newRoutesCmd())
+	rootCmd.AddCommand(newAPIKeyCmd())
+	rootCmd.AddCommand(newTokenCmd())
 
 	openapiCmd := newOpenapiCmd()
 	openapiCmd.AddCommand(newOpenapiExportCmd())
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/alternayte/forge/forge/auth/pgstore"
+	"github.com/alternayte/forge/internal/ui"
+)
+
+func newTokenCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage API bearer tokens",
+		Long: `Create and revoke bearer tokens stored in the api_tokens table.
+
+These commands connect to the database in forge.toml and use the same hashed
+storage as the built-in token store.`,
+	}
+
+	cmd.AddCommand(
+		newTokenCreateCmd(),
+		newTokenRevokeCmd(),
+	)
+
+	return cmd
+}
+
+func newTokenCreateCmd() *cobra.Command {
+	var (
+		userFlag    string
+		expiresFlag string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a bearer token",
+		Long: `Create a bearer token for a user and print it.
+
+The token is stored as a hash and printed exactly once; copy it before closing
+the terminal. Send it as "Authorization: Bearer <token>".
+
+Example:
+  forge token create --user 7c9e6679-7425-40de-944b-e07fc1f90ae7 --expires 7d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID, err := uuid.Parse(userFlag)
+			if err != nil {
+				return fmt.Errorf("invalid --user %q: %w", userFlag, err)
+			}
+			d, err := parseExpires(expiresFlag)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			pool, err := openAuthDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			token, err := pgstore.NewTokenStore(pool).Create(ctx, userID, time.Now().Add(d))
+			if err != nil {
+				return err
+			}
+
+			fmt.Println()
+			fmt.Println(ui.Success("Token created: " + token.ID.String()))
+			fmt.Println(ui.Info("Expires " + formatOptionalTime(&token.ExpiresAt, "")))
+			fmt.Println()
+			fmt.Println("    " + token.Token)
+			fmt.Println()
+			fmt.Println(ui.Warn("Copy this token now. It is stored hashed and cannot be shown again."))
+			fmt.Println()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&userFlag, "user", "", "ID of the user the token acts as")
+	cmd.Flags().StringVar(&expiresFlag, "expires", "30d", "lifetime of the token, e.g. 7d or 12h")
+	_ = cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+func newTokenRevokeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke a bearer token",
+		Long: `Revoke a bearer token by ID. Requests using it are rejected immediately.
+
+Example:
+  forge token revoke 3f2b8c1e-5d4a-4b7e-9c6f-1a2b3c4d5e6f`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokenID, err := uuid.Parse(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid token ID %q: %w", args[0], err)
+			}
+
+			ctx := cmd.Context()
+			pool, err := openAuthDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			if err := pgstore.NewTokenStore(pool).Delete(ctx, tokenID); err != nil {
+				return err
+			}
+
+			fmt.Println()
+			fmt.Println(ui.Success("Token revoked: " + tokenID.String()))
+			fmt.Println()
+
+			return nil
+		},
+	}
+
+	return cmd
+}
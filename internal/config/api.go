@@ -3,15 +3,87 @@ package config
 // APIConfig holds configuration for the REST API layer.
 // It maps to the [api] section in forge.toml.
 type APIConfig struct {
+	// Auth selects the credential stores used when none are set in code with
+	// UseTokenStore or UseAPIKeyStore: "postgres" (default) uses the hashed
+	// api_tokens and api_keys tables; "none" configures no stores, leaving API
+	// routes public unless JWT is configured.
+	Auth string `toml:"auth"`
+
+	JWT       JWTConfig       `toml:"jwt"`
 	RateLimit RateLimitConfig `toml:"rate_limit"`
 	CORS      CORSConfig      `toml:"cors"`
 }
 
+// JWTConfig enables stateless JWT bearer tokens, validated against the
+// configured keys without a database lookup. Bearer tokens that are not JWTs
+// still go to the token store. JWT is enabled when Secret, PublicKeyFile,
+// JWKSURL or JWKSFile is set.
+type JWTConfig struct {
+	// Algorithm restricts accepted tokens to one signing algorithm: "HS256",
+	// "RS256" or "EdDSA". Defaults to HS256 with Secret and to RS256 and EdDSA
+	// with public keys.
+	Algorithm string `toml:"algorithm"`
+
+	// Secret is the HS256 shared secret. Prefer the FORGE_JWT_SECRET
+	// environment variable over committing it to forge.toml.
+	Secret string `toml:"secret"`
+
+	// PublicKeyFile is a PEM-encoded RSA or Ed25519 public key.
+	PublicKeyFile string `toml:"public_key_file"`
+
+	// JWKSURL is fetched for the signing keys, selected by the token's "kid"
+	// header. Keys are refreshed hourly and when a token names an unknown kid.
+	JWKSURL string `toml:"jwks_url"`
+
+	// JWKSFile is a local JWKS document read once at startup.
+	JWKSFile string `toml:"jwks_file"`
+
+	// Issuer, when set, must equal the token's "iss" claim.
+	Issuer string `toml:"issuer"`
+
+	// Audience, when set, must be listed in the token's "aud" claim.
+	Audience string `toml:"audience"`
+
+	// Leeway is the clock skew tolerated when checking "exp" and "nbf"
+	// (e.g. "30s"). Default: no leeway.
+	Leeway string `toml:"leeway"`
+
+	// Claims names the claims mapped into the request context.
+	Claims JWTClaimsConfig `toml:"claims"`
+}
+
+// JWTClaimsConfig maps token claims to the forge/auth context. Empty fields
+// use the defaults shown.
+type JWTClaimsConfig struct {
+	// User holds the user's UUID. Default: "sub".
+	User string `toml:"user"`
+
+	// Roles holds a role name or a list of role names. Default: "roles".
+	Roles string `toml:"roles"`
+
+	// Tenant holds the tenant's UUID. Default: "tenant_id".
+	Tenant string `toml:"tenant"`
+
+	// Scopes holds a space-separated string or a list of scopes. When the
+	// claim is present, operations require the same scopes as for API keys.
+	// Default: "scope".
+	Scopes string `toml:"scopes"`
+}
+
+// Enabled reports whether any JWT signing key is configured.
+func (c JWTConfig) Enabled() bool {
+	return c.Secret != "" || c.PublicKeyFile != "" || c.JWKSURL != "" || c.JWKSFile != ""
+}
+
 // RateLimitConfig holds rate limiting settings for the API.
 type RateLimitConfig struct {
 	// Enabled toggles rate limiting globally. Default: true.
 	Enabled bool `toml:"enabled"`
 
+	// IP applies per client IP to every request before authentication, so
+	// credentials cannot be guessed faster than this (default: 5000 req/min).
+	IP TierConfig `toml:"ip"`
+
 	// Default applies to unauthenticated requests (default: 100 req/min).
 	Default TierConfig `toml:"default"`
 
@@ -20,6 +92,17 @@ type RateLimitConfig struct {
 
 	// APIKey applies to requests with a valid API key (default: 5000 req/min).
 	APIKey TierConfig `toml:"api_key"`
+
+	// Operations overrides the tier for individual operations, keyed by Huma
+	// operation ID (e.g. "createProduct", "listProducts"). The override
+	// replaces the caller's tier for that operation and is counted separately
+	// from other requests. Unknown operation IDs are rejected at startup.
+	Operations map[string]TierConfig `toml:"operations"`
+
+	// Store selects where rate limit counters are kept: "memory" (default) keeps
+	// them per process; "postgres" shares them across app instances through the
+	// rate_limits table. Any other value is rejected at startup.
+	Store string `toml:"store"`
 }
 
 // TierConfig defines the token bucket parameters for a single rate limit tier.
@@ -64,8 +147,14 @@ type CORSConfig struct {
 // DefaultAPIConfig returns an APIConfig populated with sensible production defaults.
 func DefaultAPIConfig() APIConfig {
 	return APIConfig{
+		Auth: "postgres",
 		RateLimit: RateLimitConfig{
 			Enabled: true,
+			Store:   "memory",
+			IP: TierConfig{
+				Tokens:   5000,
+				Interval: "1m",
+			},
 			Default: TierConfig{
 				Tokens:   100,
 				Interval: "1m",
@@ -0,0 +1,171 @@
+package config
+
+// AuthConfig holds browser sign-in settings for HTML routes.
+// It maps to the [auth] section in forge.toml.
+type AuthConfig struct {
+	// BaseURL is the public URL of the app (e.g. "https://app.example.com").
+	// It is required by UseAccounts, whose emailed links point to it, and
+	// used to build OIDC redirect URIs, which are derived from each request's
+	// host when it is empty.
+	BaseURL string `toml:"base_url"`
+
+	// OIDC lists the OpenID Connect providers users can sign in with, each
+	// declared as an [[auth.oidc]] table.
+	OIDC []OIDCProviderConfig `toml:"oidc"`
+
+	// Accounts tunes the built-in password account lifecycle enabled with
+	// UseAccounts.
+	Accounts AccountsConfig `toml:"accounts"`
+
+	// Lockout throttles failed password sign-ins.
+	Lockout LockoutConfig `toml:"lockout"`
+
+	// TwoFactor adds TOTP two-factor authentication to password sign-in.
+	TwoFactor TwoFactorConfig `toml:"two_factor"`
+
+	// Impersonation lets admins act as another user from the HTML app.
+	Impersonation ImpersonationConfig `toml:"impersonation"`
+}
+
+// ImpersonationConfig holds admin impersonation settings.
+// It maps to the [auth.impersonation] section in forge.toml.
+type ImpersonationConfig struct {
+	// Enabled registers POST /auth/impersonate and /auth/impersonate/stop.
+	// Needs UseRBAC or UseRoleResolver.
+	Enabled bool `toml:"enabled"`
+
+	// Roles lists roles that may impersonate other users. Users holding one
+	// cannot be impersonated. Default: ["admin"].
+	Roles []string `toml:"roles"`
+
+	// MaxDuration is how long an impersonation lasts before the admin's own
+	// session is restored, as a Go duration string. Default: "1h".
+	MaxDuration string `toml:"max_duration"`
+}
+
+// TwoFactorConfig holds TOTP two-factor authentication settings.
+// It maps to the [auth.two_factor] section in forge.toml.
+type TwoFactorConfig struct {
+	// Enabled lets users turn on 2FA at /auth/2fa/setup and asks enrolled
+	// users for a code after their password.
+	Enabled bool `toml:"enabled"`
+
+	// Issuer names the app in authenticator apps. Default: project.name.
+	Issuer string `toml:"issuer"`
+
+	// RequiredRoles lists roles that must use 2FA, e.g. ["admin"]. Users
+	// holding one enrol before their sign-in completes. Needs UseRBAC or
+	// UseRoleResolver.
+	RequiredRoles []string `toml:"required_roles"`
+
+	// RecoveryCodes is how many one-time recovery codes enrolment issues.
+	// Default: 10.
+	RecoveryCodes int `toml:"recovery_codes"`
+
+	// PendingTTL is how long a sign-in waits for its code after the password
+	// was accepted, as a Go duration string. Default: "5m".
+	PendingTTL string `toml:"pending_ttl"`
+}
+
+// LockoutConfig holds brute-force protection settings for password login.
+// It maps to the [auth.lockout] section in forge.toml.
+type LockoutConfig struct {
+	// Disabled turns off login throttling and lockout.
+	Disabled bool `toml:"disabled"`
+
+	// MaxAccountFailures failed sign-ins for one email within Window lock
+	// that account. Default: 10.
+	MaxAccountFailures int `toml:"max_account_failures"`
+
+	// MaxIPFailures failed sign-ins from one client IP within Window, across
+	// all emails, lock that IP. Default: 100.
+	MaxIPFailures int `toml:"max_ip_failures"`
+
+	// Window is how long failures are counted, as a Go duration string.
+	// Default: "15m".
+	Window string `toml:"window"`
+
+	// LockoutDuration is how long a lockout lasts, as a Go duration string.
+	// Default: "15m".
+	LockoutDuration string `toml:"lockout_duration"`
+
+	// DelayAfter failures of an account, each further attempt must wait a
+	// delay that doubles from one second up to MaxDelay. Default: 3.
+	DelayAfter int `toml:"delay_after"`
+
+	// MaxDelay caps the progressive delay, as a Go duration string.
+	// Default: "30s".
+	MaxDelay string `toml:"max_delay"`
+}
+
+// AccountsConfig holds signup, verification and password reset settings.
+// It maps to the [auth.accounts] section in forge.toml.
+type AccountsConfig struct {
+	// DisableSignup removes the /auth/signup routes, e.g. for invite-only apps.
+	DisableSignup bool `toml:"disable_signup"`
+
+	// RequireVerifiedEmail rejects password sign-in until the user follows
+	// the link emailed at signup.
+	RequireVerifiedEmail bool `toml:"require_verified_email"`
+
+	// ResetTokenTTL is how long password reset links stay valid, as a Go
+	// duration string. Default: "1h".
+	ResetTokenTTL string `toml:"reset_token_ttl"`
+
+	// VerifyTokenTTL is how long email verification links stay valid, as a
+	// Go duration string. Default: "48h".
+	VerifyTokenTTL string `toml:"verify_token_ttl"`
+
+	// MinPasswordLength is the shortest accepted password. Default: 8.
+	MinPasswordLength int `toml:"min_password_length"`
+}
+
+// OIDCProviderConfig configures one OpenID Connect identity provider such as
+// Okta, Keycloak or Azure AD.
+type OIDCProviderConfig struct {
+	// Name identifies the provider in its routes, /auth/{name} and
+	// /auth/{name}/callback.
+	Name string `toml:"name"`
+
+	// DisplayName labels the provider on the login page. Defaults to Name.
+	DisplayName string `toml:"display_name"`
+
+	// Issuer is the provider's issuer URL. Endpoints and signing keys are
+	// discovered from its /.well-known/openid-configuration.
+	Issuer string `toml:"issuer"`
+
+	ClientID string `toml:"client_id"`
+
+	// ClientSecret is the OAuth client secret. Prefer the
+	// FORGE_OIDC_<NAME>_CLIENT_SECRET environment variable over committing it.
+	ClientSecret string `toml:"client_secret"`
+
+	// Scopes are requested in addition to "openid".
+	// Default: ["email", "profile"].
+	Scopes []string `toml:"scopes"`
+
+	// RolesClaim names the ID token claim holding the user's groups or roles
+	// (e.g. "groups"). Empty maps no roles.
+	RolesClaim string `toml:"roles_claim"`
+
+	// RoleMap translates RolesClaim values to app roles. Values without an
+	// entry are dropped. When empty, the claim values are used as roles.
+	RoleMap map[string]string `toml:"role_map"`
+}
+
+// MailConfig holds the SMTP server used for account emails.
+// It maps to the [mail] section in forge.toml. Without SMTPAddr, emails are
+// written to the log.
+type MailConfig struct {
+	// SMTPAddr is the server's host:port, e.g. "smtp.example.com:587".
+	SMTPAddr string `toml:"smtp_addr"`
+
+	// From is the sender, e.g. "Acme <no-reply@acme.com>".
+	From string `toml:"from"`
+
+	Username string `toml:"username"`
+
+	// Password authenticates Username. Prefer the FORGE_SMTP_PASSWORD
+	// environment variable over committing it.
+	Password string `toml:"password"`
+}
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -20,6 +21,10 @@ type Config struct {
 	Observe  ObserveConfig  `toml:"telemetry"`
 	Admin    AdminConfig    `toml:"admin"`
 	API      APIConfig      `toml:"api"`
+	Tenant   TenantConfig   `toml:"tenant"`
+	Auth     AuthConfig     `toml:"auth"`
+	Mail     MailConfig     `toml:"mail"`
+	Audit    AuditConfig    `toml:"audit"`
 }
 
 // ProjectConfig holds project-level settings
@@ -46,22 +51,62 @@ type ToolsConfig struct {
 type ServerConfig struct {
 	Port int    `toml:"port"`
 	Host string `toml:"host"`
+
+	// TrustedProxies lists the reverse proxies, as IP addresses or CIDR
+	// ranges, whose X-Forwarded-For and X-Real-IP headers give the client
+	// address. Empty ignores those headers and uses the connection's address.
+	TrustedProxies []string `toml:"trusted_proxies"`
 }
 
 // SessionConfig holds session cookie and store settings
 type SessionConfig struct {
-	// Secret is the HMAC signing key for session data integrity. Should be a
-	// 32- or 64-byte random string. Not required when using pgxstore (server-
-	// side storage), but reserved for future HMAC signing use.
+	// Secret signs the short-lived cookie that holds OAuth handshake state
+	// when OAuthConfig.SessionSecret is empty. Should be a 32- or 64-byte
+	// random string. Sessions themselves are stored server-side and need no
+	// secret.
 	Secret string `toml:"secret"`
 
 	// Secure controls whether the session cookie is sent only over HTTPS.
-	// Set to true in production, false during local development.
-	Secure bool `toml:"secure"`
+	// When unset, cookies are secure unless server.host is "localhost".
+	Secure *bool `toml:"secure"`
 
 	// Lifetime is the session duration expressed as a Go duration string
 	// (e.g. "24h", "168h"). Defaults to 24h when empty.
 	Lifetime string `toml:"lifetime"`
+
+	// IdleTimeout ends sessions that make no request for this long, as a Go
+	// duration string (e.g. "30m"). Empty disables it.
+	IdleTimeout string `toml:"idle_timeout"`
+
+	// CookieDomain shares the session cookie with subdomains, e.g.
+	// "example.com". Empty scopes it to the host that set it.
+	CookieDomain string `toml:"cookie_domain"`
+}
+
+// TenantConfig selects how the tenant of each request is identified for
+// TenantScoped resources. It maps to the [tenant] section in forge.toml.
+type TenantConfig struct {
+	// Resolver is "header", "subdomain", "path" or "slug" (custom domains and
+	// subdomain slugs looked up in the tenants table). Empty disables tenant
+	// resolution unless a resolver is set in code via forge.App.UseTenantResolver.
+	Resolver string `toml:"resolver"`
+
+	// Header is the request header read by the "header" resolver.
+	// Defaults to X-Tenant-ID when empty.
+	Header string `toml:"header"`
+
+	// BaseDomain is the domain tenant subdomains live under for the "slug"
+	// resolver, e.g. "example.com" to match "acme.example.com" to the slug
+	// "acme". When empty only the tenants' custom domains match.
+	BaseDomain string `toml:"base_domain"`
+}
+
+// AuditConfig controls how Auditable resources write audit_logs. It maps to
+// the [audit] section in forge.toml.
+type AuditConfig struct {
+	// BestEffort lets a change commit when its audit entry cannot be written,
+	// logging the failure instead. By default the change is rolled back.
+	BestEffort bool `toml:"best_effort"`
 }
 
 // JobsConfig holds background job processing settings
@@ -161,6 +206,22 @@ func (c *Config) ApplyEnvOverrides() {
 	if v := os.Getenv("FORGE_SESSION_SECRET"); v != "" {
 		c.Session.Secret = v
 	}
+
+	if v := os.Getenv("FORGE_JWT_SECRET"); v != "" {
+		c.API.JWT.Secret = v
+	}
+
+	if v := os.Getenv("FORGE_SMTP_PASSWORD"); v != "" {
+		c.Mail.Password = v
+	}
+
+	// FORGE_OIDC_<NAME>_CLIENT_SECRET, e.g. FORGE_OIDC_OKTA_CLIENT_SECRET
+	for i, p := range c.Auth.OIDC {
+		name := strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+		if v := os.Getenv("FORGE_OIDC_" + name + "_CLIENT_SECRET"); v != "" {
+			c.Auth.OIDC[i].ClientSecret = v
+		}
+	}
 }
 
 // Load reads and parses a forge.toml file, then applies any FORGE_* env var
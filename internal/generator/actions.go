@@ -17,8 +17,10 @@ func GenerateActions(resources []parser.ResourceIR, outputDir string, projectMod
 	// Generate shared types.go file first
 	typesData := struct {
 		ProjectModule string
+		Resources     []parser.ResourceIR
 	}{
 		ProjectModule: projectModule,
+		Resources:     resources,
 	}
 
 	typesRaw, err := renderTemplate("templates/actions_types.go.tmpl", typesData)
@@ -56,9 +58,11 @@ func GenerateActions(resources []parser.ResourceIR, outputDir string, projectMod
 		data := struct {
 			parser.ResourceIR
 			ProjectModule string
+			Resources     []parser.ResourceIR
 		}{
 			ResourceIR:    resource,
 			ProjectModule: projectModule,
+			Resources:     resources,
 		}
 
 		// Render template
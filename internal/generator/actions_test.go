@@ -85,6 +85,9 @@ func TestGenerateActions(t *testing.T) {
 	if !strings.Contains(typesStr, "func GetTyped[T any](r *Registry, name string) (T, bool)") {
 		t.Error("Generated types.go missing GetTyped generic function")
 	}
+	if !strings.Contains(typesStr, "forgeauth.HasPermission(ctx, permission) || forgeauth.HasRole(ctx, allowedRoles...)") {
+		t.Error("Generated types.go checkPermission should accept the permission string or any listed role")
+	}
 
 	// Verify product.go was generated
 	productPath := filepath.Join(tempDir, "actions", "product.go")
@@ -255,3 +258,569 @@ func TestGenerateActions_MultipleResources(t *testing.T) {
 		t.Error("Generated category.go missing 'type DefaultCategoryActions struct'")
 	}
 }
+
+// relationshipTestResources returns Product (HasMany Reviews, ManyToMany Tags),
+// Review (BelongsTo Product) and Tag resources for relationship generation tests.
+func relationshipTestResources() []parser.ResourceIR {
+	return []parser.ResourceIR{
+		{
+			Name: "Product",
+			Fields: []parser.FieldIR{
+				{Name: "ID", Type: "UUID"},
+				{Name: "Name", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+			},
+			Relationships: []parser.RelationshipIR{
+				{Name: "Reviews", Type: "HasMany", Table: "reviews"},
+				{Name: "Tags", Type: "ManyToMany", Table: "tags"},
+			},
+		},
+		{
+			Name: "Review",
+			Fields: []parser.FieldIR{
+				{Name: "ID", Type: "UUID"},
+				{Name: "Rating", Type: "Int", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+			},
+			Relationships: []parser.RelationshipIR{
+				{Name: "Item", Type: "BelongsTo", Table: "products", OnDelete: "Cascade"},
+			},
+			Options: parser.ResourceOptionsIR{SoftDelete: true},
+		},
+		{
+			Name: "Tag",
+			Fields: []parser.FieldIR{
+				{Name: "ID", Type: "UUID"},
+				{Name: "Label", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+			},
+		},
+	}
+}
+
+func TestGenerateActions_Relationships(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := GenerateActions(relationshipTestResources(), tempDir, "github.com/example/testapp")
+	if err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		// HasMany: read-only list using the inverse BelongsTo column
+		"ListReviews(ctx context.Context, id uuid.UUID) ([]models.Review, error)",
+		"SELECT * FROM reviews WHERE item_id = $1 AND deleted_at IS NULL",
+		// ManyToMany: list/add/remove through the join table
+		"ListTags(ctx context.Context, id uuid.UUID) ([]models.Tag, error)",
+		"AddTags(ctx context.Context, id uuid.UUID, tagIDs []uuid.UUID) error",
+		"RemoveTags(ctx context.Context, id uuid.UUID, tagIDs []uuid.UUID) error",
+		"JOIN products_tags j ON j.tag_id = t.id",
+		"INSERT INTO products_tags (product_id, tag_id)",
+		"ON CONFLICT DO NOTHING",
+		"DELETE FROM products_tags WHERE product_id = $1 AND tag_id = ANY($2)",
+		`referencesExist(ctx, a.DB, "tags", "tag_ids", tagIDs, false, false)`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated product.go missing %q", c)
+		}
+	}
+
+	typesContent, err := os.ReadFile(filepath.Join(tempDir, "actions", "types.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated types.go: %v", err)
+	}
+	if !strings.Contains(string(typesContent), "func referencesExist(") {
+		t.Error("Generated types.go missing referencesExist helper")
+	}
+}
+
+func TestGenerateActions_Includes(t *testing.T) {
+	resources := relationshipTestResources()
+	resources[1].Relationships[0].Eager = true
+	resources = append(resources, parser.ResourceIR{
+		Name:   "Note",
+		Fields: []parser.FieldIR{{Name: "ID", Type: "UUID"}},
+		Relationships: []parser.RelationshipIR{
+			{Name: "Author", Type: "BelongsTo", Table: "users"},
+		},
+	})
+	tempDir := t.TempDir()
+
+	err := GenerateActions(resources, tempDir, "github.com/example/testapp")
+	if err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	product, err := os.ReadFile(filepath.Join(tempDir, "actions", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product.go: %v", err)
+	}
+	productStr := string(product)
+
+	checks := []string{
+		"a.loadRelations(ctx, items, includesFrom(ctx))",
+		`case "reviews":`,
+		`case "tags":`,
+		`errors.BadRequest(fmt.Sprintf("unknown include %q for Product", name))`,
+		// HasMany: one query grouped by the inverse foreign key
+		"SELECT * FROM reviews WHERE item_id = ANY($1) AND deleted_at IS NULL",
+		"i := index[r.ItemID]",
+		// ManyToMany: join table pairs, then one query for the targets
+		"SELECT product_id, tag_id FROM products_tags",
+		"SELECT * FROM tags WHERE id = ANY($1)",
+	}
+	for _, c := range checks {
+		if !strings.Contains(productStr, c) {
+			t.Errorf("Generated product.go missing %q", c)
+		}
+	}
+
+	// Eager BelongsTo is always loaded, batched by foreign key
+	review, err := os.ReadFile(filepath.Join(tempDir, "actions", "review.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated review.go: %v", err)
+	}
+	reviewStr := string(review)
+	for _, c := range []string{
+		`includesFrom(ctx, "item")`,
+		"SELECT * FROM products WHERE id = ANY($1)",
+		"items[i].Item = byID[items[i].ItemID]",
+	} {
+		if !strings.Contains(reviewStr, c) {
+			t.Errorf("Generated review.go missing %q", c)
+		}
+	}
+
+	// Relationships to unknown resources are not includable
+	note, err := os.ReadFile(filepath.Join(tempDir, "actions", "note.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated note.go: %v", err)
+	}
+	if strings.Contains(string(note), "loadRelations") {
+		t.Error("Generated note.go should not load relations to an unknown resource")
+	}
+
+	types, err := os.ReadFile(filepath.Join(tempDir, "actions", "types.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated types.go: %v", err)
+	}
+	if !strings.Contains(string(types), "func WithIncludes(ctx context.Context, names ...string) context.Context") {
+		t.Error("Generated types.go missing WithIncludes")
+	}
+}
+
+func TestGenerateActions_IncludeReadRules(t *testing.T) {
+	resources := relationshipTestResources()
+	resources[1].Fields = append(resources[1].Fields, parser.FieldIR{Name: "AuthorID", Type: "UUID"})
+	resources[1].Fields[1].Modifiers = append(resources[1].Fields[1].Modifiers, parser.ModifierIR{Type: "Visibility", Value: "moderator"})
+	resources[1].Options.OwnedBy = "AuthorID"
+	resources[1].Options.Permissions = map[string][]string{"list": {"owner", "admin"}}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions(resources, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	review, err := os.ReadFile(filepath.Join(tempDir, "actions", "review.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated review.go: %v", err)
+	}
+	for _, c := range []string{
+		"func visibleReviews(ctx context.Context, items []models.Review) ([]models.Review, error)",
+		`checkOwnerPermission(ctx, "review.list", "owner", "admin")`,
+		"return item.AuthorID != owner",
+		`if !forgeauth.HasRole(ctx, "moderator")`,
+		"clearField(&items[i].Rating)",
+	} {
+		if !strings.Contains(string(review), c) {
+			t.Errorf("Generated review.go missing %q", c)
+		}
+	}
+
+	// Included reviews pass the Review read rules
+	product, err := os.ReadFile(filepath.Join(tempDir, "actions", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product.go: %v", err)
+	}
+	if !strings.Contains(string(product), "related, err = visibleReviews(ctx, related)") {
+		t.Error("Generated product.go should filter included reviews with visibleReviews")
+	}
+	if !strings.Contains(string(product), "return visibleReviews(ctx, items)") {
+		t.Error("Generated ListReviews should filter reviews with visibleReviews")
+	}
+
+	// Resources without read rules load related rows as-is
+	tag, err := os.ReadFile(filepath.Join(tempDir, "actions", "tag.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated tag.go: %v", err)
+	}
+	if strings.Contains(string(tag), "func visibleTags") {
+		t.Error("Generated tag.go should not define visibleTags without read rules")
+	}
+}
+
+func TestGenerateActions_ForeignKeyChecks(t *testing.T) {
+	resources := relationshipTestResources()
+	resources[0].Options.TenantScoped = true
+	resources[0].Options.SoftDelete = true
+	resources[1].Relationships = append(resources[1].Relationships,
+		parser.RelationshipIR{Name: "Editor", Type: "BelongsTo", Table: "users", Optional: true, OnDelete: "SetNull"})
+	tempDir := t.TempDir()
+
+	if err := GenerateActions(resources, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	review, err := os.ReadFile(filepath.Join(tempDir, "actions", "review.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated review.go: %v", err)
+	}
+	for _, c := range []string{
+		// References to a TenantScoped resource are checked within the tenant,
+		// and soft-deleted targets do not count
+		`referenceExists(ctx, a.DB, "products", "item_id", input.ItemID, true, true)`,
+		`referenceExists(ctx, a.DB, "users", "editor_id", *input.EditorID, false, false)`,
+		// Optional references can be cleared on update
+		"if input.ClearEditorID {",
+		`setClauses = append(setClauses, "editor_id = NULL")`,
+	} {
+		if !strings.Contains(string(review), c) {
+			t.Errorf("Generated review.go missing %q", c)
+		}
+	}
+	if strings.Contains(string(review), "ClearItemID") {
+		t.Error("Generated review.go should not clear the required Item reference")
+	}
+
+	types, err := os.ReadFile(filepath.Join(tempDir, "actions", "types.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated types.go: %v", err)
+	}
+	if n := strings.Count(string(types), "` AND deleted_at IS NULL`"); n != 2 {
+		t.Errorf("Generated types.go excludes soft-deleted rows in %d reference checks, want 2", n)
+	}
+}
+
+func TestGenerate_MissingInverseForeignKey(t *testing.T) {
+	resources := relationshipTestResources()
+	resources[1].Relationships = nil
+
+	err := Generate(resources, GenerateConfig{OutputDir: t.TempDir(), ProjectModule: "github.com/example/testapp"})
+	if err == nil || !strings.Contains(err.Error(), `Review declares no BelongsTo(..., "products")`) {
+		t.Errorf("Generate error = %v, want one naming the missing BelongsTo", err)
+	}
+}
+
+func TestGenerateActions_ListCursor(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Name", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}, {Type: "Sortable"}}},
+		},
+		Options: parser.ResourceOptionsIR{SoftDelete: true},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{resource}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"ListCursor(ctx context.Context, filter models.ProductFilter, sort models.ProductSort, cursor string, limit int) ([]models.Product, queries.PageInfo, error)",
+		"queries.ProductCursorMods(filter, sort, cursor, limit)",
+		"slices.Reverse(items)",
+		"queries.ProductCursor(items[len(items)-1], filter, sort, false)",
+		// List and ListCursor share the soft-delete scope
+		"func (a *DefaultProductActions) listFilterMods(",
+		"filterMods, err := a.listFilterMods(ctx, filter)",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated product.go missing %q", c)
+		}
+	}
+}
+
+func TestGenerateActions_ListCursorRelevance(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Article",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}, {Type: "Searchable"}}},
+		},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{resource}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "article.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated article.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		// Relevance cursors store the rank selected with each row
+		"pgx.CollectRows(rows, pgx.RowToStructByName[queries.RankedArticle])",
+		"queries.ArticleCursor(items[0], ranks[items[0].ID], filter, sort, true)",
+		"queries.ArticleCursor(items[len(items)-1], ranks[items[len(items)-1].ID], filter, sort, false)",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated article.go missing %q", c)
+		}
+	}
+}
+
+func TestGenerateActions_TenantTx(t *testing.T) {
+	product := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Name", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Options: parser.ResourceOptionsIR{TenantScoped: true},
+	}
+	tag := parser.ResourceIR{
+		Name:   "Tag",
+		Fields: []parser.FieldIR{{Name: "ID", Type: "UUID"}},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{product, tag}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"func (a *DefaultProductActions) withTenantTx(ctx context.Context) (*DefaultProductActions, func(*error), error)",
+		"beginTenantTx(ctx, a.DB)",
+		"List(ctx context.Context, filter models.ProductFilter, sort models.ProductSort, page int, pageSize int) (_ []models.Product, _ int64, retErr error)",
+		"Get(ctx context.Context, id uuid.UUID) (_ *models.Product, retErr error)",
+		"Delete(ctx context.Context, id uuid.UUID) (retErr error)",
+		"a, endTx, txErr := a.withTenantTx(ctx)",
+		"defer endTx(&retErr)",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated product.go missing %q", c)
+		}
+	}
+
+	// Resources without tenant-scoped data keep plain signatures and no transaction
+	tagContent, err := os.ReadFile(filepath.Join(tempDir, "actions", "tag.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated tag.go: %v", err)
+	}
+	if strings.Contains(string(tagContent), "withTenantTx") {
+		t.Error("tag.go should not open tenant transactions")
+	}
+
+	types, err := os.ReadFile(filepath.Join(tempDir, "actions", "types.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated types.go: %v", err)
+	}
+	for _, c := range []string{"func beginTenantTx(", "forgeauth.SetLocalTenant(ctx, tx)", "tx.Commit(ctx)"} {
+		if !strings.Contains(string(types), c) {
+			t.Errorf("Generated types.go missing %q", c)
+		}
+	}
+}
+
+func TestGenerateActions_Permissions(t *testing.T) {
+	post := parser.ResourceIR{
+		Name: "BlogPost",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Options: parser.ResourceOptionsIR{Permissions: parser.PermissionsIR{
+			"update": {"admin", "editor"},
+			"delete": nil,
+		}},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{post}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "blog_post.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated blog_post.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`checkPermission(ctx, "blog_post.update", "admin", "editor")`,
+		`checkPermission(ctx, "blog_post.delete")`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated blog_post.go missing %q", c)
+		}
+	}
+	if strings.Contains(contentStr, `"blog_post.list"`) {
+		t.Error("List should not be permission-checked without a Permission rule")
+	}
+}
+
+func TestGenerateActions_OwnedBy(t *testing.T) {
+	post := parser.ResourceIR{
+		Name: "Post",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "AuthorID", Type: "UUID", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Options: parser.ResourceOptionsIR{
+			OwnedBy: "AuthorID",
+			Permissions: parser.PermissionsIR{
+				"list":   {"owner", "admin"},
+				"update": {"owner", "admin"},
+				"delete": {"admin"},
+			},
+		},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{post}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "post.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated post.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`owner, ownerErr := checkOwnerPermission(ctx, "post.list", "owner", "admin")`,
+		`sm.Where(psql.Quote("author_id").EQ(psql.Arg(owner)))`,
+		`owner, ownerErr := checkOwnerPermission(ctx, "post.update", "owner", "admin")`,
+		`whereClause += fmt.Sprintf(" AND author_id = $%d", argN)`,
+		`*input.AuthorID != owner`,
+		// delete does not allow owners, so it keeps the plain role check
+		`checkPermission(ctx, "post.delete", "admin")`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated post.go missing %q", c)
+		}
+	}
+
+	types, err := os.ReadFile(filepath.Join(tempDir, "actions", "types.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated types.go: %v", err)
+	}
+	if !strings.Contains(string(types), "func checkOwnerPermission(") {
+		t.Error("Generated types.go missing checkOwnerPermission")
+	}
+}
+
+func TestGenerateActions_AuditFailuresRollBack(t *testing.T) {
+	product := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Name", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Options: parser.ResourceOptionsIR{Auditable: true, SoftDelete: true},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{product}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"audit.Record(ctx, db, audit.Entry{",
+		`a.recordAudit(ctx, tx, "update", id, &beforeItem, result)`,
+		`return errors.InternalError(fmt.Errorf("record audit log: %w", auditErr))`,
+		// The soft delete and its audit entry share a transaction
+		"Delete(ctx context.Context, id uuid.UUID) (retErr error)",
+		"a, endTx, txErr := a.withTenantTx(ctx)",
+		`a.recordAudit(ctx, a.DB, "delete", id, nil,`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated product.go missing %q", c)
+		}
+	}
+	// A missing or already deleted row is reported before anything is audited
+	notFound := strings.Index(contentStr, `return errors.NotFound("Product", id.String())`)
+	audited := strings.Index(contentStr, `a.recordAudit(ctx, a.DB, "delete"`)
+	if notFound < 0 || notFound > audited {
+		t.Error("Generated Delete should return NotFound before recording the audit entry")
+	}
+	for _, c := range []string{"_ = auditErr", "//nolint:errcheck\n\treturn nil", "recordAuditTx"} {
+		if strings.Contains(contentStr, c) {
+			t.Errorf("Generated product.go should not contain %q", c)
+		}
+	}
+}
+
+func TestGenerateActions_SoftDeleteScope(t *testing.T) {
+	post := parser.ResourceIR{
+		Name: "Post",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "AuthorID", Type: "UUID", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Options: parser.ResourceOptionsIR{
+			OwnedBy:      "AuthorID",
+			SoftDelete:   true,
+			TenantScoped: true,
+			Permissions:  parser.PermissionsIR{"delete": {"owner", "admin"}},
+		},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateActions([]parser.ResourceIR{post}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateActions failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "actions", "post.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated post.go: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`deleteSQL += " AND tenant_id = $2"`,
+		`deleteSQL += fmt.Sprintf(" AND author_id = $%d", len(deleteArgs))`,
+		`restoreSQL += " AND tenant_id = $2"`,
+		`restoreSQL += fmt.Sprintf(" AND author_id = $%d", len(restoreArgs))`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated post.go missing %q", c)
+		}
+	}
+	if strings.Contains(contentStr, "owner != uuid.Nil && result.RowsAffected() == 0") {
+		t.Error("Generated post.go should report a missing row whether or not the caller is an owner")
+	}
+}
@@ -2,6 +2,7 @@ package generator
 
 import (
 	"path/filepath"
+	"slices"
 
 	"github.com/alternayte/forge/internal/parser"
 )
@@ -37,9 +38,11 @@ func GenerateAPI(resources []parser.ResourceIR, outputDir string, projectModule
 		data := struct {
 			parser.ResourceIR
 			ProjectModule string
+			Resources     []parser.ResourceIR
 		}{
 			ResourceIR:    resource,
 			ProjectModule: projectModule,
+			Resources:     resources,
 		}
 
 		// Render api_inputs.go.tmpl -> gen/api/{snake}_inputs.go
@@ -92,5 +95,33 @@ func GenerateAPI(resources []parser.ResourceIR, outputDir string, projectModule
 		return err
 	}
 
+	// Generate audit.go (cross-resource audit log endpoint) when any resource is Auditable
+	if hasAuditableResource(resources) {
+		auditRaw, err := renderTemplate("templates/api_audit.go.tmpl", registerAllData)
+		if err != nil {
+			return err
+		}
+		if err := writeGoFile(filepath.Join(apiDir, "audit.go"), auditRaw); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// APIScopes returns every API key scope the generated API checks for resources,
+// sorted: each operation's scopes (see apiScopes) plus "audit:read" for the
+// cross-resource audit log when any resource is Auditable.
+func APIScopes(resources []parser.ResourceIR) []string {
+	var scopes []string
+	for _, r := range resources {
+		for _, op := range []string{"list", "read", "create", "update", "delete"} {
+			scopes = append(scopes, operationScopes(r, op)...)
+		}
+	}
+	if hasAuditableResource(resources) {
+		scopes = append(scopes, "audit:read")
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
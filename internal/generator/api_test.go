@@ -3,6 +3,7 @@ package generator
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -64,12 +65,15 @@ func TestGenerateAPI(t *testing.T) {
 	if !strings.Contains(typesStr, "toHumaError") {
 		t.Error("Generated types.go missing toHumaError function")
 	}
-	if !strings.Contains(typesStr, `json:"page"`) {
-		t.Error("Generated types.go PaginationMeta missing page field")
+	if !strings.Contains(typesStr, `json:"next_cursor,omitempty"`) {
+		t.Error("Generated types.go PaginationMeta missing next_cursor field")
 	}
 	if !strings.Contains(typesStr, `json:"has_more"`) {
 		t.Error("Generated types.go PaginationMeta missing has_more field")
 	}
+	if !strings.Contains(typesStr, `json:"total_count,omitempty"`) || !strings.Contains(typesStr, `json:"page_size,omitempty"`) {
+		t.Error("Generated types.go PaginationMeta missing offset pagination fields")
+	}
 
 	// --- Verify inputs.go ---
 	inputsPath := filepath.Join(tempDir, "api", "product_inputs.go")
@@ -89,7 +93,7 @@ func TestGenerateAPI(t *testing.T) {
 	if !strings.Contains(inputsStr, `query:"limit"`) {
 		t.Error("Generated product_inputs.go ListProductInput missing limit query param")
 	}
-	if !strings.Contains(inputsStr, `query:"status"`) {
+	if !strings.Contains(inputsStr, `query:"status,deepObject"`) {
 		t.Error("Generated product_inputs.go ListProductInput missing status filter param")
 	}
 
@@ -239,6 +243,11 @@ func TestGenerateAPI(t *testing.T) {
 	if !strings.Contains(routesStr, "buildAPILinkHeader") {
 		t.Error("Generated product_routes.go List handler missing buildAPILinkHeader call")
 	}
+
+	// ?page= switches to offset pagination with the total count
+	if !strings.Contains(routesStr, "act.List(ctx, filter, sort, input.Page, pageSize)") || !strings.Contains(routesStr, "TotalCount: &total") {
+		t.Error("Generated product_routes.go List handler missing offset pagination")
+	}
 }
 
 func TestGenerateAPI_MultipleResources(t *testing.T) {
@@ -386,8 +395,8 @@ func TestGenerateAPI_LinkHeader(t *testing.T) {
 	if !strings.Contains(routesStr, `rel="next"`) {
 		t.Error("Generated List handler missing RFC 8288 rel=\"next\" in Link header")
 	}
-	if !strings.Contains(routesStr, "hasMore") {
-		t.Error("Generated List handler missing hasMore condition for Link header")
+	if !strings.Contains(routesStr, "act.ListCursor(ctx, filter, sort, input.Cursor, pageSize)") {
+		t.Error("Generated List handler does not page with the request cursor")
 	}
 	if !strings.Contains(routesStr, "out.Link") {
 		t.Error("Generated List handler missing out.Link assignment (header field)")
@@ -396,3 +405,292 @@ func TestGenerateAPI_LinkHeader(t *testing.T) {
 		t.Error("Generated List handler missing buildAPILinkHeader call")
 	}
 }
+
+func TestGenerateAPI_NestedRelationshipRoutes(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := GenerateAPI(relationshipTestResources(), tempDir, "github.com/example/testapp")
+	if err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+
+	routes, err := os.ReadFile(filepath.Join(tempDir, "api", "product_routes.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_routes.go: %v", err)
+	}
+	routesStr := string(routes)
+
+	checks := []string{
+		`OperationID: "listProductReviews"`,
+		`Path:        "/api/v1/products/{id}/reviews"`,
+		`OperationID: "listProductTags"`,
+		`OperationID: "addProductTags"`,
+		`OperationID:   "removeProductTags"`,
+		`Path:        "/api/v1/products/{id}/tags"`,
+		"act.AddTags(ctx, id, input.Body.TagIDs)",
+		"act.RemoveTags(ctx, id, input.Body.TagIDs)",
+	}
+	for _, c := range checks {
+		if !strings.Contains(routesStr, c) {
+			t.Errorf("Generated product_routes.go missing %q", c)
+		}
+	}
+
+	// HasMany is read-only: no add/remove routes for reviews
+	if strings.Contains(routesStr, "addProductReviews") {
+		t.Error("HasMany relationship should not generate an add route")
+	}
+
+	inputs, err := os.ReadFile(filepath.Join(tempDir, "api", "product_inputs.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_inputs.go: %v", err)
+	}
+	if !strings.Contains(string(inputs), "type ProductTagsInput struct") {
+		t.Error("Generated product_inputs.go missing ProductTagsInput")
+	}
+	if !strings.Contains(string(inputs), `json:"tag_ids"`) {
+		t.Error("Generated product_inputs.go missing tag_ids body field")
+	}
+}
+
+func TestGenerateAPI_IncludeParam(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := GenerateAPI(relationshipTestResources(), tempDir, "github.com/example/testapp")
+	if err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+
+	inputs, err := os.ReadFile(filepath.Join(tempDir, "api", "product_inputs.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_inputs.go: %v", err)
+	}
+	include := "Include []string `query:\"include\" enum:\"reviews,tags\""
+	if n := strings.Count(string(inputs), include); n != 2 {
+		t.Errorf("Expected include param on List and Get inputs, found %d", n)
+	}
+
+	routes, err := os.ReadFile(filepath.Join(tempDir, "api", "product_routes.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_routes.go: %v", err)
+	}
+	if n := strings.Count(string(routes), "ctx = actions.WithIncludes(ctx, input.Include...)"); n != 2 {
+		t.Errorf("Expected List and Get handlers to pass includes, found %d", n)
+	}
+
+	// Tag declares no relationships, so it has no include param
+	tagInputs, err := os.ReadFile(filepath.Join(tempDir, "api", "tag_inputs.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated tag_inputs.go: %v", err)
+	}
+	if strings.Contains(string(tagInputs), "Include") {
+		t.Error("Generated tag_inputs.go should not have an include param")
+	}
+}
+
+func TestGenerateAPI_SearchParam(t *testing.T) {
+	article := parser.ResourceIR{
+		Name: "Article",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String"},
+		},
+		Options: parser.ResourceOptionsIR{Searchable: true},
+	}
+	tempDir := t.TempDir()
+
+	err := GenerateAPI([]parser.ResourceIR{article}, tempDir, "github.com/example/testapp")
+	if err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+
+	inputs, err := os.ReadFile(filepath.Join(tempDir, "api", "article_inputs.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated article_inputs.go: %v", err)
+	}
+	if !strings.Contains(string(inputs), "Q string `query:\"q\"") {
+		t.Error("Generated article_inputs.go missing q search param")
+	}
+
+	routes, err := os.ReadFile(filepath.Join(tempDir, "api", "article_routes.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated article_routes.go: %v", err)
+	}
+	if !strings.Contains(string(routes), "filter.Search = &input.Q") {
+		t.Error("Generated article_routes.go does not pass q to the filter")
+	}
+}
+
+func TestGenerateAPI_FilterParams(t *testing.T) {
+	product := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Price", Type: "Decimal", Modifiers: []parser.ModifierIR{{Type: "Filterable"}}},
+			{Name: "Status", Type: "Enum", EnumValues: []string{"draft", "published"}, Modifiers: []parser.ModifierIR{{Type: "Filterable"}}},
+		},
+	}
+	tempDir := t.TempDir()
+
+	err := GenerateAPI([]parser.ResourceIR{product}, tempDir, "github.com/example/testapp")
+	if err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+
+	inputs, err := os.ReadFile(filepath.Join(tempDir, "api", "product_inputs.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_inputs.go: %v", err)
+	}
+	inputsStr := string(inputs)
+	checks := []string{
+		"Price ProductPriceFilter `query:\"price,deepObject\"",
+		"type ProductPriceFilter struct",
+		"GTE    string `json:\"gte,omitempty\" doc:\"Greater than or equal to\"`",
+		"EQ     string `json:\"eq,omitempty\" enum:\"draft,published\"",
+		"func (i *ListProductInput) Resolve(ctx huma.Context) []error",
+		"queries.ParseProductFilter(i.query)",
+	}
+	for _, c := range checks {
+		if !strings.Contains(inputsStr, c) {
+			t.Errorf("Generated product_inputs.go missing %q", c)
+		}
+	}
+
+	routes, err := os.ReadFile(filepath.Join(tempDir, "api", "product_routes.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_routes.go: %v", err)
+	}
+	if !strings.Contains(string(routes), "filter := input.filter") {
+		t.Error("Generated product_routes.go does not use the resolved filter")
+	}
+}
+
+func TestGenerateAPI_Scopes(t *testing.T) {
+	product := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Name", Type: "String"},
+		},
+		Options: parser.ResourceOptionsIR{Scopes: parser.ScopesIR{"list": {"catalog:read"}}},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateAPI([]parser.ResourceIR{product}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+
+	routes, err := os.ReadFile(filepath.Join(tempDir, "api", "product_routes.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_routes.go: %v", err)
+	}
+	routesStr := string(routes)
+	checks := []string{
+		// Schema override
+		`Security:    security("catalog:read"),`,
+		// Defaults derived from the resource and operation
+		`Security:    security("products:read"),`,
+		`Security:      security("products:write"),`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(routesStr, c) {
+			t.Errorf("Generated product_routes.go missing %q", c)
+		}
+	}
+
+	types, err := os.ReadFile(filepath.Join(tempDir, "api", "types.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated types.go: %v", err)
+	}
+	if !strings.Contains(string(types), "{forgeauth.SecuritySchemeAPIKey: scopes}") {
+		t.Error("Generated types.go security() does not require API key scopes")
+	}
+}
+
+func TestGenerateAPI_AuditLog(t *testing.T) {
+	product := parser.ResourceIR{
+		Name:    "Product",
+		Fields:  []parser.FieldIR{{Name: "ID", Type: "UUID"}},
+		Options: parser.ResourceOptionsIR{Auditable: true, TenantScoped: true},
+	}
+	tag := parser.ResourceIR{
+		Name:   "Tag",
+		Fields: []parser.FieldIR{{Name: "ID", Type: "UUID"}},
+	}
+	tempDir := t.TempDir()
+
+	if err := GenerateAPI([]parser.ResourceIR{product, tag}, tempDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "api", "audit.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated audit.go: %v", err)
+	}
+	contentStr := string(content)
+	checks := []string{
+		`Path:        "/api/v1/audit",`,
+		`Security:    security("audit:read"),`,
+		`forgeauth.HasPermission(ctx, "audit.read")`,
+		`query:"resource_type" enum:"product"`,
+		`query:"since"`,
+		`query:"cursor"`,
+		`[]string{"product"}`,
+		"audit.List(ctx, db, filter)",
+		"filter.TenantID = &tenantID",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated audit.go missing %q", c)
+		}
+	}
+
+	// The per-record history has the same gate and only covers readable rows
+	routes, err := os.ReadFile(filepath.Join(tempDir, "api", "product_routes.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated product_routes.go: %v", err)
+	}
+	routesStr := string(routes)
+	for _, c := range []string{
+		`forgeauth.HasPermission(ctx, "audit.read") && !forgeauth.HasRole(ctx, "admin")`,
+		"act.Get(ctx, id)",
+		"AND tenant_id = $3",
+		`"product", id, tenantID,`,
+	} {
+		if !strings.Contains(routesStr, c) {
+			t.Errorf("Generated product_routes.go audit handler missing %q", c)
+		}
+	}
+	if strings.Contains(routesStr, "continue") {
+		t.Error("Generated product_routes.go audit handler skips scan errors")
+	}
+
+	registerAll, err := os.ReadFile(filepath.Join(tempDir, "api", "register_all.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated register_all.go: %v", err)
+	}
+	if !strings.Contains(string(registerAll), "RegisterAuditRoutes(api, db)") {
+		t.Error("RegisterAllRoutes does not register the audit log")
+	}
+
+	// Projects without Auditable resources get no audit endpoint
+	plainDir := t.TempDir()
+	if err := GenerateAPI([]parser.ResourceIR{tag}, plainDir, "github.com/example/testapp"); err != nil {
+		t.Fatalf("GenerateAPI failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(plainDir, "api", "audit.go")); !os.IsNotExist(err) {
+		t.Errorf("audit.go generated without Auditable resources (stat err: %v)", err)
+	}
+}
+
+func TestAPIScopes(t *testing.T) {
+	resources := []parser.ResourceIR{
+		{Name: "Product", Options: parser.ResourceOptionsIR{Auditable: true, Scopes: parser.ScopesIR{"list": {"catalog:read"}}}},
+		{Name: "OrderItem"},
+	}
+	got := APIScopes(resources)
+	want := []string{"audit:read", "catalog:read", "order-items:read", "order-items:write", "products:read", "products:write"}
+	if !slices.Equal(got, want) {
+		t.Errorf("APIScopes = %v, want %v", got, want)
+	}
+}
@@ -8,7 +8,42 @@ import (
 
 // AtlasTemplateData holds data for rendering the Atlas schema template.
 type AtlasTemplateData struct {
-	Resources []parser.ResourceIR
+	Resources  []parser.ResourceIR
+	JoinTables []JoinTableData
+}
+
+// JoinTableData describes a join table generated for a ManyToMany relationship.
+type JoinTableData struct {
+	Name        string // Join table name (e.g., "products_tags")
+	LeftTable   string // Owning resource table (e.g., "products")
+	LeftColumn  string // Column referencing LeftTable (e.g., "product_id")
+	RightTable  string // Related table (e.g., "tags")
+	RightColumn string // Column referencing RightTable (e.g., "tag_id")
+}
+
+// collectJoinTables returns one JoinTableData per distinct ManyToMany association.
+// When both sides declare the relationship, the shared join table is emitted once.
+func collectJoinTables(resources []parser.ResourceIR) []JoinTableData {
+	var tables []JoinTableData
+	seen := make(map[string]bool)
+	for _, r := range resources {
+		for _, rel := range relsOfType(r.Relationships, "ManyToMany") {
+			name := joinTable(r.Name, rel)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			ownerTable := plural(snake(r.Name))
+			tables = append(tables, JoinTableData{
+				Name:        name,
+				LeftTable:   ownerTable,
+				LeftColumn:  joinOwnerColumn(r.Name),
+				RightTable:  rel.Table,
+				RightColumn: joinTargetColumn(r.Name, rel),
+			})
+		}
+	}
+	return tables
 }
 
 // GenerateAtlasSchema generates an Atlas HCL schema file from parsed resources.
@@ -16,7 +51,8 @@ type AtlasTemplateData struct {
 func GenerateAtlasSchema(resources []parser.ResourceIR, outputDir string) error {
 	// Prepare template data
 	data := AtlasTemplateData{
-		Resources: resources,
+		Resources:  resources,
+		JoinTables: collectJoinTables(resources),
 	}
 
 	// Render the Atlas HCL template
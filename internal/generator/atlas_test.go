@@ -64,12 +64,15 @@ func TestGenerateAtlasSchema_ProductTable(t *testing.T) {
 		t.Error("Generated file missing table 'products'")
 	}
 
-	// Verify id column with UUID type (must appear exactly once — not duplicated)
-	if !strings.Contains(contentStr, `column "id"`) {
+	// Verify id column with UUID type (must appear exactly once in the products
+	// table — not duplicated; framework tables such as api_keys have their own)
+	_, productsTable, _ := strings.Cut(contentStr, `table "products"`)
+	productsTable, _, _ = strings.Cut(productsTable, "\ntable ")
+	if !strings.Contains(productsTable, `column "id"`) {
 		t.Error("Generated file missing id column")
 	}
-	if strings.Count(contentStr, `column "id"`) != 1 {
-		t.Errorf("id column declared %d times, want exactly 1", strings.Count(contentStr, `column "id"`))
+	if strings.Count(productsTable, `column "id"`) != 1 {
+		t.Errorf("id column declared %d times, want exactly 1", strings.Count(productsTable, `column "id"`))
 	}
 	if !strings.Contains(contentStr, "type    = uuid") {
 		t.Error("Generated file missing uuid type for id")
@@ -176,7 +179,7 @@ func TestAtlasTypeMapping(t *testing.T) {
 // TestAtlasUniqueIndex verifies Unique modifier generates unique index.
 func TestAtlasUniqueIndex(t *testing.T) {
 	resource := parser.ResourceIR{
-		Name: "User",
+		Name: "Customer",
 		Fields: []parser.FieldIR{
 			{Name: "Email", Type: "Email", Modifiers: []parser.ModifierIR{{Type: "Unique"}, {Type: "Required"}}},
 		},
@@ -200,7 +203,7 @@ func TestAtlasUniqueIndex(t *testing.T) {
 	contentStr := string(content)
 
 	// Verify unique index generated
-	if !strings.Contains(contentStr, `index "users_email_unique"`) {
+	if !strings.Contains(contentStr, `index "customers_email_unique"`) {
 		t.Error("Generated file missing unique index on Email")
 	}
 	if !strings.Contains(contentStr, `columns = [column.email]`) {
@@ -315,3 +318,585 @@ func TestAtlasDefault(t *testing.T) {
 		})
 	}
 }
+
+// TestAtlasForeignKeys verifies BelongsTo and HasOne relationships generate an FK
+// column, an index and a foreign_key block with the declared ON DELETE action.
+func TestAtlasForeignKeys(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Relationships: []parser.RelationshipIR{
+			{Name: "Category", Type: "BelongsTo", Table: "categories", Optional: true, OnDelete: "SetNull"},
+			{Name: "Owner", Type: "BelongsTo", Table: "users", OnDelete: "Cascade"},
+			{Name: "Spec", Type: "HasOne", Table: "specs"},
+			{Name: "Reviews", Type: "HasMany", Table: "reviews"},
+		},
+	}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	err := GenerateAtlasSchema([]parser.ResourceIR{resource}, outputDir)
+	if err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`column "category_id"`,
+		`column "owner_id"`,
+		`column "spec_id"`,
+		`index "products_category_id_idx"`,
+		`index "products_owner_id_idx"`,
+		`index "products_spec_id_unique"`,
+		`foreign_key "products_category_id_fkey"`,
+		`ref_columns = [table.categories.column.id]`,
+		`on_delete   = SET_NULL`,
+		`ref_columns = [table.users.column.id]`,
+		`on_delete   = CASCADE`,
+		`ref_columns = [table.specs.column.id]`,
+		`on_delete   = NO_ACTION`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+
+	// HasMany stores its FK on the related table, not on products
+	if strings.Contains(contentStr, `column "reviews_id"`) {
+		t.Error("HasMany relationship should not generate an FK column on the owning table")
+	}
+}
+
+// TestAtlasOnDelete verifies OnDelete IR values map to Atlas referential actions.
+func TestAtlasOnDelete(t *testing.T) {
+	tests := []struct {
+		action   string
+		expected string
+	}{
+		{"Cascade", "CASCADE"},
+		{"SetNull", "SET_NULL"},
+		{"Restrict", "RESTRICT"},
+		{"NoAction", "NO_ACTION"},
+		{"", "NO_ACTION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			result := atlasOnDelete(tt.action)
+			if result != tt.expected {
+				t.Errorf("atlasOnDelete(%q) = %q, want %q", tt.action, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestAtlasJoinTables verifies ManyToMany relationships generate a single shared
+// join table with a composite primary key and cascading foreign keys.
+func TestAtlasJoinTables(t *testing.T) {
+	resources := []parser.ResourceIR{
+		{
+			Name:          "Product",
+			Relationships: []parser.RelationshipIR{{Name: "Tags", Type: "ManyToMany", Table: "tags"}},
+		},
+		{
+			Name:          "Tag",
+			Relationships: []parser.RelationshipIR{{Name: "Products", Type: "ManyToMany", Table: "products"}},
+		},
+	}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	if n := strings.Count(contentStr, `table "products_tags"`); n != 1 {
+		t.Errorf("join table declared %d times, want exactly 1", n)
+	}
+	checks := []string{
+		`column "product_id"`,
+		`column "tag_id"`,
+		`columns = [column.product_id, column.tag_id]`,
+		`foreign_key "products_tags_product_id_fkey"`,
+		`foreign_key "products_tags_tag_id_fkey"`,
+		`ref_columns = [table.tags.column.id]`,
+		`on_delete   = CASCADE`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasSelfJoinTable verifies that a resource related to itself gets a join
+// table with distinct columns for the two sides.
+func TestAtlasSelfJoinTable(t *testing.T) {
+	resources := []parser.ResourceIR{{
+		Name:          "Member",
+		Relationships: []parser.RelationshipIR{{Name: "Friends", Type: "ManyToMany", Table: "members"}},
+	}}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "members_friends"`,
+		`columns = [column.member_id, column.friend_id]`,
+		`foreign_key "members_friends_friend_id_fkey"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasRateLimitsTable verifies the rate_limits table used by the Postgres
+// rate limit store is generated even when no resource needs it.
+func TestAtlasRateLimitsTable(t *testing.T) {
+	resources := []parser.ResourceIR{{Name: "Product"}}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "rate_limits"`,
+		`column "key"`,
+		`column "window_start"`,
+		`column "count"`,
+		`columns = [column.key]`,
+		`index "rate_limits_window_start_idx"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasRolesTables verifies the roles and role_assignments tables read by
+// auth.RBAC are generated with Permission rules, referencing tenants only when
+// that table exists.
+func TestAtlasRolesTables(t *testing.T) {
+	perms := parser.PermissionsIR{"update": {"admin"}}
+	tests := []struct {
+		name      string
+		resources []parser.ResourceIR
+		tenantFK  bool
+	}{
+		{"plain", []parser.ResourceIR{{Name: "Product", Options: parser.ResourceOptionsIR{Permissions: perms}}}, false},
+		{"tenant scoped", []parser.ResourceIR{{Name: "Product", Options: parser.ResourceOptionsIR{Permissions: perms, TenantScoped: true}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := filepath.Join(t.TempDir(), "gen")
+			if err := GenerateAtlasSchema(tt.resources, outputDir); err != nil {
+				t.Fatalf("GenerateAtlasSchema failed: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+			if err != nil {
+				t.Fatalf("Failed to read generated file: %v", err)
+			}
+			contentStr := string(content)
+
+			checks := []string{
+				`table "roles"`,
+				`column "permissions"`,
+				`index "roles_tenant_name_unique"`,
+				`table "role_assignments"`,
+				`index "role_assignments_unique"`,
+				`ref_columns = [table.roles.column.id]`,
+			}
+			for _, c := range checks {
+				if !strings.Contains(contentStr, c) {
+					t.Errorf("Generated schema missing %s", c)
+				}
+			}
+			if got := strings.Contains(contentStr, `foreign_key "role_assignments_tenant_fk"`); got != tt.tenantFK {
+				t.Errorf("role_assignments tenant foreign key present = %v, want %v", got, tt.tenantFK)
+			}
+		})
+	}
+}
+
+// TestAtlasTenantsTable verifies the tenants table backing the slug resolver is
+// generated only when a resource is TenantScoped.
+func TestAtlasTenantsTable(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []parser.ResourceIR
+		want      bool
+	}{
+		{"tenant scoped", []parser.ResourceIR{{Name: "Product", Options: parser.ResourceOptionsIR{TenantScoped: true}}}, true},
+		{"not tenant scoped", []parser.ResourceIR{{Name: "Product"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := filepath.Join(t.TempDir(), "gen")
+			if err := GenerateAtlasSchema(tt.resources, outputDir); err != nil {
+				t.Fatalf("GenerateAtlasSchema failed: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+			if err != nil {
+				t.Fatalf("Failed to read generated file: %v", err)
+			}
+			contentStr := string(content)
+
+			if got := strings.Contains(contentStr, `table "tenants"`); got != tt.want {
+				t.Fatalf("tenants table generated = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			checks := []string{
+				`column "slug"`,
+				`column "domains"`,
+				`type    = sql("text[]")`,
+				`column "status"`,
+				`index "tenants_slug_unique"`,
+				`check "tenants_status_check"`,
+			}
+			for _, c := range checks {
+				if !strings.Contains(contentStr, c) {
+					t.Errorf("Generated schema missing %s", c)
+				}
+			}
+		})
+	}
+}
+
+func TestAtlasSearchVector(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Article",
+		Fields: []parser.FieldIR{
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Searchable"}}},
+			{Name: "Body", Type: "Text", Modifiers: []parser.ModifierIR{{Type: "Searchable"}}},
+			{Name: "Summary", Type: "Text"},
+		},
+	}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	err := GenerateAtlasSchema([]parser.ResourceIR{resource}, outputDir)
+	if err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`column "search_vector"`,
+		`type = tsvector`,
+		`expr = "setweight(to_tsvector('english', coalesce(title, '')), 'A') || setweight(to_tsvector('english', coalesce(body, '')), 'B')"`,
+		`type = STORED`,
+		`index "articles_search_vector_idx"`,
+		`type    = GIN`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+	if strings.Contains(contentStr, "coalesce(summary") {
+		t.Error("Unmarked field should not be indexed when other fields are Searchable")
+	}
+}
+
+// TestSearchFields verifies which fields feed the search_vector column.
+func TestSearchFields(t *testing.T) {
+	title := parser.FieldIR{Name: "Title", Type: "String"}
+	body := parser.FieldIR{Name: "Body", Type: "Text"}
+	stock := parser.FieldIR{Name: "Stock", Type: "Int", Modifiers: []parser.ModifierIR{{Type: "Searchable"}}}
+	markedBody := parser.FieldIR{Name: "Body", Type: "Text", Modifiers: []parser.ModifierIR{{Type: "Searchable"}}}
+
+	tests := []struct {
+		name       string
+		searchable bool
+		fields     []parser.FieldIR
+		want       []string
+	}{
+		{"no search", false, []parser.FieldIR{title, body}, nil},
+		{"resource option indexes all text fields", true, []parser.FieldIR{title, body, stock}, []string{"Title", "Body"}},
+		{"marked fields win over resource option", true, []parser.FieldIR{title, markedBody}, []string{"Body"}},
+		{"marked fields without resource option", false, []parser.FieldIR{title, markedBody}, []string{"Body"}},
+		{"non-text fields are skipped", false, []parser.FieldIR{stock}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range searchFields(parser.ResourceOptionsIR{Searchable: tt.searchable}, tt.fields) {
+				got = append(got, f.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("searchFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAtlasCredentialTables verifies the api_tokens and api_keys tables used by
+// forge/auth/pgstore are always generated and store hashes, not secrets.
+func TestAtlasCredentialTables(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "gen")
+	if err := GenerateAtlasSchema([]parser.ResourceIR{{Name: "Product"}}, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "api_tokens"`,
+		`column "token_hash"`,
+		`index "api_tokens_token_hash_unique"`,
+		`table "api_keys"`,
+		`column "key_hash"`,
+		`column "lookup"`,
+		`column "last_used_at"`,
+		`index "api_keys_key_hash_unique"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasAccountTables verifies the users and account_tokens tables used by
+// pgstore.UserStore are generated, and that no resource may replace the
+// built-in users table.
+func TestAtlasAccountTables(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "gen")
+	if err := GenerateAtlasSchema([]parser.ResourceIR{{Name: "Product"}}, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+	checks := []string{
+		`table "users"`,
+		`column "password_hash"`,
+		`column "email_verified_at"`,
+		`index "users_email_unique"`,
+		`table "account_tokens"`,
+		`index "account_tokens_token_hash_unique"`,
+		`foreign_key "account_tokens_user_fk"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+
+	user := parser.ResourceIR{
+		Name:   "User",
+		Fields: []parser.FieldIR{{Name: "Email", Type: "Email", Modifiers: []parser.ModifierIR{{Type: "Required"}}}},
+	}
+	err = Generate([]parser.ResourceIR{user}, GenerateConfig{OutputDir: t.TempDir(), ProjectModule: "github.com/example/testapp"})
+	if err == nil || !strings.Contains(err.Error(), "built-in users table") {
+		t.Errorf("Generate error = %v, want one rejecting the User resource", err)
+	}
+}
+
+// TestAtlasLoginAttemptTables verifies the login_attempts and auth_events
+// tables used by auth.LoginLimiter are generated even when no resource needs
+// them.
+func TestAtlasLoginAttemptTables(t *testing.T) {
+	resources := []parser.ResourceIR{{Name: "Product"}}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "login_attempts"`,
+		`column "failures"`,
+		`column "first_failure_at"`,
+		`column "locked_until"`,
+		`index "login_attempts_last_failure_at_idx"`,
+		`table "auth_events"`,
+		`column "user_agent"`,
+		`index "auth_events_email_idx"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasUserSessionsTable verifies the user_sessions index used by
+// auth.Sessions is generated even when no resource needs it.
+func TestAtlasUserSessionsTable(t *testing.T) {
+	resources := []parser.ResourceIR{{Name: "Product"}}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "user_sessions"`,
+		`column "last_seen_at"`,
+		`index "user_sessions_user_idx"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasTwoFactorTables verifies the user_totp and recovery_codes tables
+// used by auth.TwoFactor are generated even when no resource needs them.
+func TestAtlasTwoFactorTables(t *testing.T) {
+	resources := []parser.ResourceIR{{Name: "Product"}}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "user_totp"`,
+		`column "last_step"`,
+		`table "recovery_codes"`,
+		`column "code_hash"`,
+		`ref_columns = [table.user_totp.column.user_id]`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+func TestAtlasAuditLogsTable(t *testing.T) {
+	resources := []parser.ResourceIR{{Name: "Product", Options: parser.ResourceOptionsIR{Auditable: true}}}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		`table "audit_logs"`,
+		`column "created_by"`,
+		`column "impersonated_user_id"`,
+		`column "request_id"`,
+		`column "ip_address"`,
+		`column "user_agent"`,
+		`column "api_key_id"`,
+		`column "tenant_id"`,
+		`index "audit_logs_created_by_idx"`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated schema missing %s", c)
+		}
+	}
+}
+
+// TestAtlasTenantPolicies verifies the RLS policies compare against a NULL
+// tenant, instead of raising an error, when app.current_tenant is unset.
+func TestAtlasTenantPolicies(t *testing.T) {
+	resources := []parser.ResourceIR{{Name: "Product", Options: parser.ResourceOptionsIR{TenantScoped: true}}}
+	outputDir := filepath.Join(t.TempDir(), "gen")
+	if err := GenerateAtlasSchema(resources, outputDir); err != nil {
+		t.Fatalf("GenerateAtlasSchema failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "atlas", "schema.hcl"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	want := `(tenant_id = NULLIF(current_setting('app.current_tenant', true), '')::uuid)`
+	if n := strings.Count(contentStr, want); n != 3 {
+		t.Errorf("Generated schema has %d policy expressions %s, want 3", n, want)
+	}
+	if strings.Contains(contentStr, "current_setting('app.current_tenant')") {
+		t.Error("Generated schema reads app.current_tenant without missing_ok")
+	}
+}
@@ -10,6 +10,7 @@ import (
 type FactoryTemplateData struct {
 	Name          string
 	Fields        []parser.FieldIR
+	Relationships []parser.RelationshipIR
 	Options       parser.ResourceOptionsIR
 	HasTimestamps bool
 	ProjectModule string
@@ -23,6 +24,7 @@ func GenerateFactories(resources []parser.ResourceIR, outputDir string, projectM
 		data := FactoryTemplateData{
 			Name:          resource.Name,
 			Fields:        resource.Fields,
+			Relationships: resource.Relationships,
 			Options:       resource.Options,
 			HasTimestamps: resource.HasTimestamps,
 			ProjectModule: projectModule,
@@ -2,6 +2,7 @@ package generator
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -50,14 +51,57 @@ func BuildFuncMap() template.FuncMap {
 		"formNeedsJSON":  formNeedsJSON,
 		// Phase 7: Advanced data feature helpers
 		"hasPermission":          hasPermission,
-		"permissionRoles":        permissionRoles,
+		"permissionArgs":         permissionArgs,
+		"ownerScoped":            ownerScoped,
+		"ownerOptional":          ownerOptional,
+		"ownerNullable":          ownerNullable,
+		"restrictsReads":         restrictsReads,
+		"apiScopes":              apiScopes,
 		"hasAnyVisibility":       hasAnyVisibility,
 		"hasAnyPermission":       hasAnyPermission,
 		"hasAuditableResource":   hasAuditableResource,
+		"auditableResources":     auditableResources,
 		"hasTenantScopedResource": hasTenantScopedResource,
+		"hasPermissionResource":   hasPermissionResource,
 		// Phase 8: Background jobs helpers
 		"hasHooks": hasHooks,
 		"pascal":   pascal,
+		// Relationship helpers
+		"ownsForeignKey":   ownsForeignKey,
+		"foreignKeyRels":   foreignKeyRels,
+		"fkColumn":         fkColumn,
+		"fkField":          fkField,
+		"fkGoType":         fkGoType,
+		"atlasOnDelete":    atlasOnDelete,
+		"singular":         singular,
+		"relsOfType":       relsOfType,
+		"relatedResource":  relatedResource,
+		"inverseFKColumn":  inverseFKColumn,
+		"joinTable":        joinTable,
+		"joinColumn":       joinColumn,
+		"joinOwnerColumn":  joinOwnerColumn,
+		"joinTargetColumn": joinTargetColumn,
+		"relatedScoped":    relatedScoped,
+		"targetScoped":     targetScoped,
+		"targetSoftDelete": targetSoftDelete,
+		"inverseFKRel":     inverseFKRel,
+		"includableRels":   includableRels,
+		"includeNames":     includeNames,
+		"eagerIncludes":    eagerIncludes,
+		"displayField":     displayField,
+		// Full-text search helpers
+		"isSearchable":     isSearchable,
+		"searchFields":     searchFields,
+		"searchVectorExpr": searchVectorExpr,
+		"searchConfig":     searchConfigName,
+		// Filter operator helpers
+		"filterOps":      filterOps,
+		"filterOpField":  filterOpField,
+		"filterOpGoType": filterOpGoType,
+		"filterOpMethod": filterOpMethod,
+		"filterOpDoc":    filterOpDoc,
+		"filterParser":   filterParser,
+		"filterControl":  filterControl,
 	}
 }
 
@@ -110,6 +154,9 @@ func lower(s string) string {
 // snake delegates to stringutil.Snake for use within this package.
 var snake = stringutil.Snake
 
+// singular delegates to stringutil.Singular for use within this package.
+var singular = stringutil.Singular
+
 // plural delegates to stringutil.Plural for use within this package.
 var plural = stringutil.Plural
 
@@ -421,24 +468,83 @@ func hasPermission(opts parser.ResourceOptionsIR, operation string) bool {
 	return ok
 }
 
-// permissionRoles returns a Go code literal of quoted role strings for the
-// given operation (e.g., `"admin", "editor"`). Returns an empty string if
-// the operation has no permissions defined.
-func permissionRoles(opts parser.ResourceOptionsIR, operation string) string {
-	if opts.Permissions == nil {
-		return ""
+// permissionArgs returns the Go arguments for a generated checkPermission call:
+// the quoted permission string "<resource>.<operation>" (e.g. "product.update")
+// followed by the quoted roles the schema allows for the operation.
+func permissionArgs(name string, opts parser.ResourceOptionsIR, operation string) string {
+	args := []string{`"` + snake(name) + "." + operation + `"`}
+	for _, r := range opts.Permissions[operation] {
+		args = append(args, `"`+r+`"`)
 	}
-	roles, ok := opts.Permissions[operation]
-	if !ok || len(roles) == 0 {
-		return ""
+	return strings.Join(args, ", ")
+}
+
+// ownerScoped returns true if the operation lists the "owner" role on a
+// resource declaring schema.OwnedBy, so generated actions limit owner-only
+// callers to their own rows.
+func ownerScoped(opts parser.ResourceOptionsIR, operation string) bool {
+	return opts.OwnedBy != "" && slices.Contains(opts.Permissions[operation], "owner")
+}
+
+// ownerOptional returns true if the OwnedBy field is a pointer on the Create
+// model: an optional UUID field or an Optional BelongsTo foreign key.
+func ownerOptional(fields []parser.FieldIR, rels []parser.RelationshipIR, ownedBy string) bool {
+	for _, f := range fields {
+		if f.Name == ownedBy {
+			return !isRequired(f.Modifiers)
+		}
 	}
-	quoted := make([]string, len(roles))
-	for i, r := range roles {
-		quoted[i] = `"` + r + `"`
+	for _, r := range foreignKeyRels(rels) {
+		if fkField(r) == ownedBy {
+			return r.Optional
+		}
+	}
+	return false
+}
+
+// ownerNullable returns true if the OwnedBy field is a pointer on the model,
+// which is only the case for an Optional BelongsTo foreign key.
+func ownerNullable(rels []parser.RelationshipIR, ownedBy string) bool {
+	for _, r := range foreignKeyRels(rels) {
+		if fkField(r) == ownedBy {
+			return r.Optional
+		}
+	}
+	return false
+}
+
+// restrictsReads returns true if a resource limits who may read its rows: a list
+// permission (possibly owner-scoped) or a field with a Visibility modifier. Rows of
+// such a resource loaded through a relationship must pass the same checks.
+func restrictsReads(opts parser.ResourceOptionsIR, fields []parser.FieldIR) bool {
+	return hasPermission(opts, "list") || hasAnyVisibility(fields)
+}
+
+// apiScopes returns the quoted, comma-separated API key scopes required for the
+// given operation (list, read, create, update, delete) on resource r. A schema.Scope
+// override wins; otherwise list and read require "<resources>:read" and all other
+// operations require "<resources>:write", e.g. "products:read".
+func apiScopes(r parser.ResourceIR, operation string) string {
+	scopes := operationScopes(r, operation)
+	quoted := make([]string, len(scopes))
+	for i, s := range scopes {
+		quoted[i] = `"` + s + `"`
 	}
 	return strings.Join(quoted, ", ")
 }
 
+// operationScopes returns the unquoted scopes described by apiScopes.
+func operationScopes(r parser.ResourceIR, operation string) []string {
+	if scopes := r.Options.Scopes[operation]; len(scopes) > 0 {
+		return scopes
+	}
+	access := "write"
+	if operation == "list" || operation == "read" {
+		access = "read"
+	}
+	return []string{kebab(plural(r.Name)) + ":" + access}
+}
+
 // hasAnyVisibility returns true if any field in the list has a Visibility modifier.
 func hasAnyVisibility(fields []parser.FieldIR) bool {
 	for _, f := range fields {
@@ -464,6 +570,17 @@ func hasAuditableResource(resources []parser.ResourceIR) bool {
 	return false
 }
 
+// auditableResources returns the resources in the slice that have Auditable enabled.
+func auditableResources(resources []parser.ResourceIR) []parser.ResourceIR {
+	var out []parser.ResourceIR
+	for _, r := range resources {
+		if r.Options.Auditable {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
 // hasTenantScopedResource returns true if any resource in the slice has TenantScoped enabled.
 func hasTenantScopedResource(resources []parser.ResourceIR) bool {
 	for _, r := range resources {
@@ -474,6 +591,16 @@ func hasTenantScopedResource(resources []parser.ResourceIR) bool {
 	return false
 }
 
+// hasPermissionResource returns true if any resource in the slice has Permission rules.
+func hasPermissionResource(resources []parser.ResourceIR) bool {
+	for _, r := range resources {
+		if hasAnyPermission(r.Options) {
+			return true
+		}
+	}
+	return false
+}
+
 // Phase 8: Background jobs helpers
 
 // hasHooks returns true when the resource options declare at least one lifecycle job.
@@ -496,6 +623,234 @@ func pascal(s string) string {
 	return strings.Join(parts, "")
 }
 
+// Relationship helpers
+
+// ownsForeignKey returns true if the relationship stores its foreign key column on
+// the declaring resource. BelongsTo and HasOne both do; HasOne additionally gets a
+// unique index so the association stays one-to-one.
+func ownsForeignKey(rel parser.RelationshipIR) bool {
+	return rel.Type == "BelongsTo" || rel.Type == "HasOne"
+}
+
+// foreignKeyRels returns only relationships that own a foreign key column.
+func foreignKeyRels(rels []parser.RelationshipIR) []parser.RelationshipIR {
+	var result []parser.RelationshipIR
+	for _, r := range rels {
+		if ownsForeignKey(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// fkColumn returns the foreign key column name for a relationship.
+// For example, BelongsTo("Category", ...) becomes "category_id".
+func fkColumn(rel parser.RelationshipIR) string {
+	return snake(rel.Name) + "_id"
+}
+
+// fkField returns the Go struct field name for a relationship's foreign key.
+// For example, BelongsTo("Category", ...) becomes "CategoryID".
+func fkField(rel parser.RelationshipIR) string {
+	return camel(rel.Name) + "ID"
+}
+
+// fkGoType returns the Go type of a foreign key field on the model:
+// uuid.UUID for required relationships, *uuid.UUID for Optional ones.
+func fkGoType(rel parser.RelationshipIR) string {
+	if rel.Optional {
+		return "*uuid.UUID"
+	}
+	return "uuid.UUID"
+}
+
+// atlasOnDelete maps an IR OnDelete action (the schema constant name) to the
+// Atlas HCL referential action keyword. Unset actions default to NO_ACTION.
+func atlasOnDelete(action string) string {
+	switch action {
+	case "Cascade":
+		return "CASCADE"
+	case "SetNull":
+		return "SET_NULL"
+	case "Restrict":
+		return "RESTRICT"
+	default:
+		return "NO_ACTION"
+	}
+}
+
+// relsOfType returns the relationships of the given type (e.g., "HasMany").
+func relsOfType(rels []parser.RelationshipIR, relType string) []parser.RelationshipIR {
+	var result []parser.RelationshipIR
+	for _, r := range rels {
+		if r.Type == relType {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// relatedResource finds the resource whose table a relationship points to.
+// Tables are matched by convention (plural snake_case of the resource name).
+// Returns nil when the table is not backed by a forge resource.
+func relatedResource(resources []parser.ResourceIR, rel parser.RelationshipIR) *parser.ResourceIR {
+	for i := range resources {
+		if plural(snake(resources[i].Name)) == rel.Table {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+// inverseFKColumn returns the column on the target resource that points back to
+// the owner of a HasMany relationship, taken from the BelongsTo/HasOne declared on
+// the target. Without one there is no column to load the records by, so it
+// returns an error instead of guessing "<owner>_id".
+func inverseFKColumn(target parser.ResourceIR, ownerName string) (string, error) {
+	if r := inverseFKRel(target, ownerName); r != nil {
+		return fkColumn(*r), nil
+	}
+	return "", errMissingInverseFK(target, ownerName)
+}
+
+// errMissingInverseFK reports a HasMany from ownerName to target that target does
+// not answer with a BelongsTo.
+func errMissingInverseFK(target parser.ResourceIR, ownerName string) error {
+	return fmt.Errorf("%s has many %s, but %s declares no BelongsTo(..., %q) to load them by",
+		ownerName, plural(target.Name), target.Name, plural(snake(ownerName)))
+}
+
+// inverseFKRel returns the BelongsTo/HasOne relationship on target that points back
+// at the owner resource, or nil if target does not declare one.
+func inverseFKRel(target parser.ResourceIR, ownerName string) *parser.RelationshipIR {
+	ownerTable := plural(snake(ownerName))
+	for i, r := range target.Relationships {
+		if ownsForeignKey(r) && r.Table == ownerTable {
+			return &target.Relationships[i]
+		}
+	}
+	return nil
+}
+
+// includableRels returns the relationships that can be batch-loaded through
+// ?include= or Eager. The target resource must be known and, for HasMany, must
+// declare the inverse foreign key so loaded rows can be grouped by owner.
+func includableRels(resources []parser.ResourceIR, ownerName string, rels []parser.RelationshipIR) []parser.RelationshipIR {
+	var out []parser.RelationshipIR
+	for _, r := range rels {
+		target := relatedResource(resources, r)
+		if target == nil {
+			continue
+		}
+		if r.Type == "HasMany" && inverseFKRel(*target, ownerName) == nil {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// includeNames returns the ?include= names (snake_case) for the given relationships.
+func includeNames(rels []parser.RelationshipIR) []string {
+	names := make([]string, len(rels))
+	for i, r := range rels {
+		names[i] = snake(r.Name)
+	}
+	return names
+}
+
+// eagerIncludes returns the include names of relationships marked Eager.
+func eagerIncludes(rels []parser.RelationshipIR) []string {
+	var names []string
+	for _, r := range rels {
+		if r.Eager {
+			names = append(names, snake(r.Name))
+		}
+	}
+	return names
+}
+
+// joinTable returns the join table name for a ManyToMany relationship. The two table
+// names are sorted so both sides of the association share one table (e.g., "products_tags").
+// A resource related to itself gets a table named after the relationship instead
+// (e.g., User.ManyToMany("Friends") uses "users_friends").
+func joinTable(ownerName string, rel parser.RelationshipIR) string {
+	a, b := plural(snake(ownerName)), rel.Table
+	if a == b {
+		return a + "_" + snake(rel.Name)
+	}
+	if a > b {
+		a, b = b, a
+	}
+	return a + "_" + b
+}
+
+// joinColumn returns the join table column referencing the given table
+// (e.g., "tags" becomes "tag_id").
+func joinColumn(table string) string {
+	return singular(table) + "_id"
+}
+
+// joinOwnerColumn returns the join table column referencing the resource that
+// declares a ManyToMany relationship (e.g., "product_id").
+func joinOwnerColumn(ownerName string) string {
+	return joinColumn(plural(snake(ownerName)))
+}
+
+// joinTargetColumn returns the join table column referencing the related records
+// of a ManyToMany relationship (e.g., "tag_id"). A resource related to itself
+// cannot use the table name for both columns, so the target column is named
+// after the relationship instead (e.g., "friend_id" next to "user_id").
+func joinTargetColumn(ownerName string, rel parser.RelationshipIR) string {
+	owner := joinOwnerColumn(ownerName)
+	if plural(snake(ownerName)) != rel.Table {
+		return joinColumn(rel.Table)
+	}
+	if col := singular(snake(rel.Name)) + "_id"; col != owner {
+		return col
+	}
+	return "related_" + owner
+}
+
+// displayField returns the field used to label a record when it is shown as a
+// related item: the first String-like field, falling back to ID.
+func displayField(r parser.ResourceIR) string {
+	for _, f := range r.Fields {
+		switch f.Type {
+		case "String", "Slug", "Email":
+			if !isIDField(f) {
+				return f.Name
+			}
+		}
+	}
+	return "ID"
+}
+
+// relatedScoped returns true if any related target resource is TenantScoped.
+// Used to decide whether nested and include queries need the tenant context.
+func relatedScoped(resources []parser.ResourceIR, rels []parser.RelationshipIR) bool {
+	for _, r := range rels {
+		if target := relatedResource(resources, r); target != nil && target.Options.TenantScoped {
+			return true
+		}
+	}
+	return false
+}
+
+// targetScoped returns true if the resource a relationship points to is
+// TenantScoped, so references to it must be checked against the current tenant.
+func targetScoped(resources []parser.ResourceIR, rel parser.RelationshipIR) bool {
+	target := relatedResource(resources, rel)
+	return target != nil && target.Options.TenantScoped
+}
+
+// targetSoftDelete returns true if the resource a relationship points to is
+// SoftDelete, so deleted rows must not count as existing references.
+func targetSoftDelete(resources []parser.ResourceIR, rel parser.RelationshipIR) bool {
+	target := relatedResource(resources, rel)
+	return target != nil && target.Options.SoftDelete
+}
+
 // formNeedsFmt returns true if any field requires the "fmt" package in the form template.
 // Fields with Visibility or Mutability modifiers use fmt.Sprint for read-only display,
 // and Decimal fields use fmt.Sprint for input value formatting.
@@ -548,3 +903,231 @@ func htmlInputType(fieldType string) string {
 		return "text"
 	}
 }
+
+// searchConfig is the PostgreSQL text search configuration used for generated
+// search_vector columns and websearch_to_tsquery calls.
+const searchConfig = "english"
+
+// searchConfigName returns searchConfig for use in templates.
+func searchConfigName() string {
+	return searchConfig
+}
+
+// isTextSearchType returns true for field types that can feed a tsvector directly.
+func isTextSearchType(fieldType string) bool {
+	switch fieldType {
+	case "String", "Text", "Slug", "Email", "URL", "Enum":
+		return true
+	}
+	return false
+}
+
+// searchFields returns the fields indexed for full-text search. Fields marked
+// Searchable are used when present; otherwise a Searchable resource indexes all of
+// its text fields. Non-text fields are skipped because their ::text casts are not
+// immutable and cannot be used in a generated column.
+func searchFields(opts parser.ResourceOptionsIR, fields []parser.FieldIR) []parser.FieldIR {
+	var marked, text []parser.FieldIR
+	for _, f := range fields {
+		if !isTextSearchType(f.Type) {
+			continue
+		}
+		text = append(text, f)
+		if hasModifier(f.Modifiers, "Searchable") {
+			marked = append(marked, f)
+		}
+	}
+	if len(marked) > 0 {
+		return marked
+	}
+	if opts.Searchable {
+		return text
+	}
+	return nil
+}
+
+// isSearchable returns true if the resource has a generated search_vector column.
+func isSearchable(opts parser.ResourceOptionsIR, fields []parser.FieldIR) bool {
+	return len(searchFields(opts, fields)) > 0
+}
+
+// searchWeight returns the tsvector weight for a field: short text such as titles
+// and names rank above long-form Text bodies.
+func searchWeight(f parser.FieldIR) string {
+	if f.Type == "Text" {
+		return "B"
+	}
+	return "A"
+}
+
+// searchVectorExpr returns the SQL expression for the generated search_vector column,
+// e.g. setweight(to_tsvector('english', coalesce(title, '')), 'A') || ...
+func searchVectorExpr(opts parser.ResourceOptionsIR, fields []parser.FieldIR) string {
+	var parts []string
+	for _, f := range searchFields(opts, fields) {
+		parts = append(parts, fmt.Sprintf(`setweight(to_tsvector('%s', coalesce(%s, '')), '%s')`,
+			searchConfig, snake(f.Name), searchWeight(f)))
+	}
+	return strings.Join(parts, " || ")
+}
+
+// isOrderedType returns true for field types that support range comparisons.
+func isOrderedType(fieldType string) bool {
+	switch fieldType {
+	case "Int", "BigInt", "Decimal", "DateTime", "Date":
+		return true
+	}
+	return false
+}
+
+// isTextType returns true for field types that support substring matching.
+func isTextType(fieldType string) bool {
+	switch fieldType {
+	case "String", "Text", "Email", "URL", "Slug":
+		return true
+	}
+	return false
+}
+
+// filterOps returns the list filter operators supported by a field, in documentation
+// order: eq and neq for every type, in for types with discrete values, range operators
+// for ordered types, contains for text, and is_null for optional (nullable) fields.
+func filterOps(f parser.FieldIR) []string {
+	ops := []string{"eq", "neq"}
+	if f.Type != "Bool" && f.Type != "JSON" {
+		ops = append(ops, "in")
+	}
+	if isOrderedType(f.Type) {
+		ops = append(ops, "gt", "gte", "lt", "lte")
+	}
+	if isTextType(f.Type) {
+		ops = append(ops, "contains")
+	}
+	if !isRequired(f.Modifiers) && !isIDField(f) {
+		ops = append(ops, "is_null")
+	}
+	return ops
+}
+
+// filterOpSuffixes maps filter operators to the suffix of their Filter struct field.
+var filterOpSuffixes = map[string]string{
+	"eq":       "",
+	"neq":      "Neq",
+	"in":       "In",
+	"gt":       "Gt",
+	"gte":      "Gte",
+	"lt":       "Lt",
+	"lte":      "Lte",
+	"contains": "Contains",
+	"is_null":  "IsNull",
+}
+
+// filterOpField returns the Filter struct field holding op for f (e.g. "PriceGte").
+// The eq operator uses the bare field name.
+func filterOpField(f parser.FieldIR, op string) string {
+	return f.Name + filterOpSuffixes[op]
+}
+
+// filterOpGoType returns the Filter struct field type for op on f.
+func filterOpGoType(f parser.FieldIR, op string) string {
+	switch op {
+	case "in":
+		return "[]" + goType(f.Type)
+	case "contains":
+		return "*string"
+	case "is_null":
+		return "*bool"
+	default:
+		return goPointerType(f.Type)
+	}
+}
+
+// filterOpMethod returns the suffix of the generated <Field><Method> query mod for op.
+func filterOpMethod(op string) string {
+	switch op {
+	case "in":
+		return "In"
+	case "contains":
+		return "Contains"
+	case "is_null":
+		return "IsNull"
+	default:
+		return strings.ToUpper(op)
+	}
+}
+
+// filterOpDoc returns the OpenAPI description of a filter operator.
+func filterOpDoc(op string) string {
+	switch op {
+	case "eq":
+		return "Equal to"
+	case "neq":
+		return "Not equal to"
+	case "in":
+		return "Any of a comma-separated list of values"
+	case "gt":
+		return "Greater than"
+	case "gte":
+		return "Greater than or equal to"
+	case "lt":
+		return "Less than"
+	case "lte":
+		return "Less than or equal to"
+	case "contains":
+		return "Case-insensitive substring match"
+	case "is_null":
+		return "true matches empty values, false matches non-empty values"
+	default:
+		return ""
+	}
+}
+
+// filterParser returns the Go expression that parses a raw query value for f into
+// its Go type. The parse functions live in the generated queries/filter_params.go.
+func filterParser(f parser.FieldIR) string {
+	switch f.Type {
+	case "UUID":
+		return "parseUUID"
+	case "Int":
+		return "parseInt"
+	case "BigInt":
+		return "parseInt64"
+	case "Decimal":
+		return "parseDecimal"
+	case "Bool":
+		return "parseBool"
+	case "DateTime":
+		return "parseDateTime"
+	case "Date":
+		return "parseDate"
+	case "JSON":
+		return "parseJSON"
+	case "Enum":
+		quoted := make([]string, len(f.EnumValues))
+		for i, v := range f.EnumValues {
+			quoted[i] = fmt.Sprintf("%q", v)
+		}
+		return "enumParser(" + strings.Join(quoted, ", ") + ")"
+	default:
+		return "parseString"
+	}
+}
+
+// filterControl returns the kind of control used for f in the scaffolded filter bar:
+// "select" for enums, "bool" for booleans, "range" for ordered types, "contains" for
+// text, and "exact" for everything else.
+func filterControl(f parser.FieldIR) string {
+	switch {
+	case f.Type == "Enum":
+		return "select"
+	case f.Type == "Bool":
+		return "bool"
+	case isOrderedType(f.Type):
+		return "range"
+	case isTextType(f.Type):
+		return "contains"
+	default:
+		return "exact"
+	}
+}
+
@@ -2,8 +2,10 @@ package generator
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"text/template"
 
 	"github.com/alternayte/forge/internal/parser"
@@ -18,6 +20,14 @@ type GenerateConfig struct {
 
 // Generate orchestrates all code generation from parsed resources.
 func Generate(resources []parser.ResourceIR, cfg GenerateConfig) error {
+	// Reject relationships that cannot be generated before writing any files
+	if err := checkRelationships(resources); err != nil {
+		return err
+	}
+	if err := checkReservedTables(resources); err != nil {
+		return err
+	}
+
 	// Generate model types
 	if err := GenerateModels(resources, cfg.OutputDir, cfg.ProjectModule); err != nil {
 		return err
@@ -93,6 +103,50 @@ func Generate(resources []parser.ResourceIR, cfg GenerateConfig) error {
 	return nil
 }
 
+// checkRelationships verifies that every HasMany between known resources is
+// answered by a BelongsTo on the target, which holds the foreign key the nested
+// route and ?include= load the records by.
+func checkRelationships(resources []parser.ResourceIR) error {
+	for _, r := range resources {
+		for _, rel := range relsOfType(r.Relationships, "HasMany") {
+			target := relatedResource(resources, rel)
+			if target != nil && inverseFKRel(*target, r.Name) == nil {
+				return errMissingInverseFK(*target, r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// builtinTables lists the tables the Atlas schema always declares for forge's
+// own auth, RBAC, audit and tenancy packages.
+var builtinTables = []string{
+	"users", "account_tokens", "login_attempts", "auth_events",
+	"sessions", "user_sessions", "user_totp", "recovery_codes",
+	"rate_limits", "api_tokens", "api_keys",
+	"roles", "role_assignments", "audit_logs", "tenants",
+}
+
+// checkReservedTables rejects resources stored in one of the builtinTables,
+// which would declare the table twice. A User resource in particular would
+// replace the users table that pgstore.UserStore reads password_hash and
+// email_verified_at from, and declaring those fields would expose them through
+// the generated CRUD routes. Profile data belongs in its own resource with a
+// BelongsTo("User", "users").
+func checkReservedTables(resources []parser.ResourceIR) error {
+	for _, r := range resources {
+		table := plural(snake(r.Name))
+		if !slices.Contains(builtinTables, table) {
+			continue
+		}
+		if table == "users" {
+			return fmt.Errorf("resource %s is stored in the built-in users table; keep profile data in a separate resource, e.g. Profile with schema.BelongsTo(\"User\", \"users\")", r.Name)
+		}
+		return fmt.Errorf("resource %s is stored in the built-in %s table; rename the resource", r.Name, table)
+	}
+	return nil
+}
+
 // renderTemplate parses and executes a template from TemplatesFS.
 func renderTemplate(tmplName string, data interface{}) ([]byte, error) {
 	// Read template content from embedded filesystem
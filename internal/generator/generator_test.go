@@ -216,3 +216,73 @@ func TestIsFilterable(t *testing.T) {
 		})
 	}
 }
+
+// TestGenerateModels_ForeignKeys verifies BelongsTo relationships add FK fields to
+// the model, Create and Update structs.
+func TestGenerateModels_ForeignKeys(t *testing.T) {
+	product := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}}},
+		},
+		Relationships: []parser.RelationshipIR{
+			{Name: "Category", Type: "BelongsTo", Table: "categories", Optional: true, OnDelete: "SetNull"},
+			{Name: "Owner", Type: "BelongsTo", Table: "users"},
+		},
+	}
+
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	err := GenerateModels([]parser.ResourceIR{product}, outputDir, "example.com/project")
+	if err != nil {
+		t.Fatalf("GenerateModels failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "models", "product.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"CategoryID *uuid.UUID `json:\"category_id,omitempty\" db:\"category_id\"`",
+		"OwnerID    uuid.UUID  `json:\"owner_id\" db:\"owner_id\"`",
+		"OwnerID    uuid.UUID  `json:\"owner_id\" validate:\"required\"`",
+		"OwnerID         *uuid.UUID `json:\"owner_id,omitempty\"`",
+		// Only an optional reference can be cleared on update
+		"ClearCategoryID bool       `json:\"clear_category_id,omitempty\"`",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated model missing %s", c)
+		}
+	}
+}
+
+func TestGenerateModels_RelationFields(t *testing.T) {
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "gen")
+
+	err := GenerateModels(relationshipTestResources(), outputDir, "example.com/project")
+	if err != nil {
+		t.Fatalf("GenerateModels failed: %v", err)
+	}
+
+	product := readFile(t, filepath.Join(outputDir, "models", "product.go"))
+	for _, c := range []string{
+		"Reviews []Review `json:\"reviews,omitempty\" db:\"-\"`",
+		"Tags    []Tag    `json:\"tags,omitempty\" db:\"-\"`",
+	} {
+		if !strings.Contains(product, c) {
+			t.Errorf("Generated product model missing %s", c)
+		}
+	}
+
+	review := readFile(t, filepath.Join(outputDir, "models", "review.go"))
+	if !strings.Contains(review, "Item *Product `json:\"item,omitempty\" db:\"-\"`") {
+		t.Error("Generated review model missing Item relation field")
+	}
+}
+
@@ -65,7 +65,7 @@ func TestGoldenPath(t *testing.T) {
 	}
 
 	// Step 2: Scaffold resource handlers and hooks
-	_, err = ScaffoldResource(post, projectRoot, projectModule)
+	_, err = ScaffoldResource(post, resources, projectRoot, projectModule)
 	if err != nil {
 		t.Fatalf("ScaffoldResource failed: %v", err)
 	}
@@ -82,6 +82,7 @@ func TestGoldenPath(t *testing.T) {
 import (
 	"context"
 	"io"
+	"net/url"
 
 	"example.com/fgtester/gen/models"
 )
@@ -91,7 +92,7 @@ type stubComponent struct{}
 
 func (stubComponent) Render(_ context.Context, _ io.Writer) error { return nil }
 
-func PostList(_ []models.Post, _ string, _ string, _ int, _ int) stubComponent {
+func PostList(_ []models.Post, _ string, _ string, _ int, _ int, _ url.Values) stubComponent {
 	return stubComponent{}
 }
 
@@ -6,8 +6,8 @@ import (
 	"github.com/alternayte/forge/internal/parser"
 )
 
-// GenerateHTML generates the HTML primitives library, Datastar SSE helpers,
-// and the HTML route registration dispatcher.
+// GenerateHTML generates the HTML primitives library, the login and account
+// pages, Datastar SSE helpers, and the HTML route registration dispatcher.
 // These are generated-always files in gen/html/ (not scaffolded-once).
 func GenerateHTML(resources []parser.ResourceIR, outputDir, projectModule string) error {
 	// Template data with project module for imports
@@ -49,6 +49,23 @@ func GenerateHTML(resources []parser.ResourceIR, outputDir, projectModule string
 		return err
 	}
 
+	// Generate gen/html/authpages/pages.templ (login and account pages for
+	// forge's UseAuthPages)
+	authPagesDir := filepath.Join(outputDir, "html", "authpages")
+	if err := ensureDir(authPagesDir); err != nil {
+		return err
+	}
+
+	authPagesRaw, err := renderTemplate("templates/auth_pages.templ.tmpl", data)
+	if err != nil {
+		return err
+	}
+
+	authPagesPath := filepath.Join(authPagesDir, "pages.templ")
+	if err := writeRawFile(authPagesPath, authPagesRaw); err != nil {
+		return err
+	}
+
 	// Generate gen/html/sse/sse.go
 	sseDir := filepath.Join(outputDir, "html", "sse")
 	if err := ensureDir(sseDir); err != nil {
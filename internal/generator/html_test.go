@@ -50,6 +50,57 @@ func TestGenerateHTML(t *testing.T) {
 		}
 	}
 
+	// Verify gen/html/layout/layout.templ exposes the CSRF token
+	layoutContent, err := os.ReadFile(filepath.Join(tempDir, "html", "layout", "layout.templ"))
+	if err != nil {
+		t.Fatalf("Failed to read layout/layout.templ: %v", err)
+	}
+	for _, element := range []string{
+		`<meta name="csrf-token" content={ auth.CSRFToken(ctx) }/>`,
+		`data-signals:_csrf=`,
+		`auth.ImpersonatorFromContext(ctx)`,
+		`action="/auth/impersonate/stop"`,
+	} {
+		if !strings.Contains(string(layoutContent), element) {
+			t.Errorf("layout/layout.templ missing required element: %s", element)
+		}
+	}
+
+	// Verify gen/html/authpages/pages.templ was generated
+	authPagesContent, err := os.ReadFile(filepath.Join(tempDir, "html", "authpages", "pages.templ"))
+	if err != nil {
+		t.Fatalf("Failed to read authpages/pages.templ: %v", err)
+	}
+
+	authPagesStr := string(authPagesContent)
+
+	// Assert pages.templ implements auth.Pages with the app layout
+	requiredAuthPagesElements := []string{
+		"package authpages",
+		`"github.com/test/myapp/gen/html/layout"`,
+		"var _ auth.Pages = Pages{}",
+		"func (Pages) Login(d auth.PageData) auth.Component",
+		"func (Pages) ResetPassword(d auth.PageData) auth.Component",
+		`action="/auth/signup"`,
+		`action="/auth/forgot-password"`,
+		`name="token" value={ d.Token }`,
+		`action="/auth/change-password"`,
+		`name="csrf_token" value={ auth.CSRFToken(ctx) }`,
+		"templ LogoutButton()",
+		`action="/auth/logout"`,
+		"func (Pages) Sessions(d auth.PageData) auth.Component",
+		`action="/auth/sessions/revoke-all"`,
+		"func (Pages) TwoFactorSetup(d auth.PageData) auth.Component",
+		`action="/auth/2fa"`,
+		`templ.SafeURL(d.TOTPURI)`,
+	}
+
+	for _, element := range requiredAuthPagesElements {
+		if !strings.Contains(authPagesStr, element) {
+			t.Errorf("authpages/pages.templ missing required element: %s", element)
+		}
+	}
+
 	// Verify gen/html/sse/sse.go was generated
 	ssePath := filepath.Join(tempDir, "html", "sse", "sse.go")
 	sseContent, err := os.ReadFile(ssePath)
@@ -16,8 +16,17 @@ func GenerateModels(resources []parser.ResourceIR, outputDir string, projectModu
 
 	// Generate a file for each resource
 	for _, resource := range resources {
+		// Prepare template data with all resources so relation fields can resolve their targets
+		data := struct {
+			parser.ResourceIR
+			Resources []parser.ResourceIR
+		}{
+			ResourceIR: resource,
+			Resources:  resources,
+		}
+
 		// Render template
-		raw, err := renderTemplate("templates/model.go.tmpl", resource)
+		raw, err := renderTemplate("templates/model.go.tmpl", data)
 		if err != nil {
 			return err
 		}
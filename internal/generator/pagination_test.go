@@ -60,7 +60,12 @@ func TestGeneratePagination(t *testing.T) {
 		"func CursorPaginationMods",
 		"sm.Limit",
 		"sm.Offset",
-		"psql.Raw",
+		"psql.Group",
+		"func cursorValue[T any]",
+		"Backward",
+		"IsNull()",
+		"type cursorOrder struct",
+		"func (c *cursor) checkOrder(order cursorOrder) error",
 	}
 
 	for _, expected := range expectedElements {
@@ -14,6 +14,23 @@ func GenerateQueries(resources []parser.ResourceIR, outputDir string, projectMod
 		return err
 	}
 
+	// Generate shared filter_params.go file first (field[op]= query parameter parsing)
+	paramsData := struct {
+		ProjectModule string
+	}{
+		ProjectModule: projectModule,
+	}
+
+	paramsRaw, err := renderTemplate("templates/filter_params.go.tmpl", paramsData)
+	if err != nil {
+		return err
+	}
+
+	paramsPath := filepath.Join(queriesDir, "filter_params.go")
+	if err := writeGoFile(paramsPath, paramsRaw); err != nil {
+		return err
+	}
+
 	// Generate a query file for each resource
 	for _, resource := range resources {
 		// Prepare template data with ProjectModule
@@ -173,3 +173,178 @@ func TestGenerateQueries(t *testing.T) {
 		t.Errorf("Generated code does not compile: %v", err)
 	}
 }
+
+func TestGenerateQueries_Search(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Article",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Searchable"}}},
+		},
+	}
+
+	tempDir := t.TempDir()
+	if err := GenerateQueries([]parser.ResourceIR{resource}, tempDir, "github.com/example/app"); err != nil {
+		t.Fatalf("GenerateQueries failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "queries", "article_queries.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"func (f ArticleFilters) SearchMod(q string) bob.Mod[*dialect.SelectQuery]",
+		`search_vector @@ websearch_to_tsquery('english', ?)`,
+		"func ArticleSearchRankMod(q string) bob.Mod[*dialect.SelectQuery]",
+		`ts_rank(search_vector, websearch_to_tsquery('english', ?))`,
+		"mods = append(mods, (ArticleFilters{}).SearchMod(*filter.Search))",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated queries missing %q", c)
+		}
+	}
+}
+
+func TestGenerateQueries_FilterOperators(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}, {Type: "Filterable"}}},
+			{Name: "Price", Type: "Decimal", Modifiers: []parser.ModifierIR{{Type: "Filterable"}}},
+			{Name: "Status", Type: "Enum", EnumValues: []string{"draft", "published"}, Modifiers: []parser.ModifierIR{{Type: "Filterable"}}},
+		},
+	}
+
+	tempDir := t.TempDir()
+	if err := GenerateQueries([]parser.ResourceIR{resource}, tempDir, "github.com/example/app"); err != nil {
+		t.Fatalf("GenerateQueries failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "queries", "filter_params.go")); err != nil {
+		t.Errorf("Expected shared filter_params.go to be generated: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "queries", "product_queries.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"func (f ProductFilters) PriceGT(val decimal.Decimal)",
+		"func (f ProductFilters) PriceIn(vals []decimal.Decimal)",
+		"func (f ProductFilters) PriceIsNull(isNull bool)",
+		"func (f ProductFilters) TitleContains(val string)",
+		"mods = append(mods, (ProductFilters{}).PriceGTE(*filter.PriceGte))",
+		"if len(filter.StatusIn) > 0 {",
+		"func ParseProductFilter(values url.Values) (models.ProductFilter, validation.ValidationErrors)",
+		`filter.StatusIn = parseFilterList(errs, key, raw, enumParser("draft", "published"))`,
+		`unsupported operator %q for title (supported: eq, neq, in, contains)`,
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated queries missing %q", c)
+		}
+	}
+
+	// Required fields cannot be NULL, so no is_null operator is generated for them.
+	if strings.Contains(contentStr, "TitleIsNull") {
+		t.Error("Generated queries should not have an is_null filter for required Title")
+	}
+}
+
+func TestGenerateQueries_ContainsEscapesWildcards(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Product",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Filterable"}}},
+		},
+	}
+
+	tempDir := t.TempDir()
+	if err := GenerateQueries([]parser.ResourceIR{resource}, tempDir, "github.com/example/app"); err != nil {
+		t.Fatalf("GenerateQueries failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "queries", "product_queries.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	want := "psql.Raw(`\"title\" ILIKE ? ESCAPE '\\'`, containsPattern(val))"
+	if !strings.Contains(string(content), want) {
+		t.Errorf("Generated queries missing %q", want)
+	}
+	if strings.Contains(string(content), `"%" + val + "%"`) {
+		t.Error("Generated queries should not pass val to ILIKE unescaped")
+	}
+
+	params, err := os.ReadFile(filepath.Join(tempDir, "queries", "filter_params.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated filter_params.go: %v", err)
+	}
+	want = "strings.NewReplacer(`\\`, `\\\\`, `%`, `\\%`, `_`, `\\_`)"
+	if !strings.Contains(string(params), want) {
+		t.Errorf("Generated filter_params.go missing %q", want)
+	}
+}
+
+func TestGenerateQueries_CursorMods(t *testing.T) {
+	resource := parser.ResourceIR{
+		Name: "Article",
+		Fields: []parser.FieldIR{
+			{Name: "ID", Type: "UUID"},
+			{Name: "Title", Type: "String", Modifiers: []parser.ModifierIR{{Type: "Required"}, {Type: "Sortable"}, {Type: "Searchable"}}},
+			{Name: "PublishedAt", Type: "DateTime", Modifiers: []parser.ModifierIR{{Type: "Sortable"}}},
+		},
+	}
+
+	tempDir := t.TempDir()
+	if err := GenerateQueries([]parser.ResourceIR{resource}, tempDir, "github.com/example/app"); err != nil {
+		t.Fatalf("GenerateQueries failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "queries", "article_queries.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	checks := []string{
+		"func ArticleCursorMods(filter models.ArticleFilter, sort models.ArticleSort, encoded string, pageSize int) ([]bob.Mod[*dialect.SelectQuery], bool, error)",
+		"func ArticleCursor(item models.Article, rank float32, filter models.ArticleFilter, sort models.ArticleSort, backward bool) (string, error)",
+		"keyValue, err = cursorValue[time.Time](cur)",
+		"value = item.PublishedAt",
+		// Searching without an explicit sort pages by relevance, and the
+		// cursor stores the rank instead of looking up the cursor row
+		`order.SortField, order.SortDir = "relevance", "desc"`,
+		"keyValue, err = cursorValue[float32](cur)",
+		"value = rank",
+		"mods = append(mods, sm.Columns(psql.Raw(\"*\"), rank))",
+		"type RankedArticle struct",
+		// A cursor only continues the sort and search it was issued for
+		"order.Search = *filter.Search",
+		"if err := cur.checkOrder(order); err != nil {",
+		"mods := CursorPaginationMods(key, nullable, order.SortDir == \"desc\", cur, keyValue, pageSize)",
+		// Offset pages by relevance break ties by id
+		"sm.OrderBy(psql.Quote(\"id\")).Asc(),",
+	}
+	for _, c := range checks {
+		if !strings.Contains(contentStr, c) {
+			t.Errorf("Generated queries missing %q", c)
+		}
+	}
+
+	if strings.Contains(contentStr, "FROM articles WHERE id = ?") {
+		t.Error("Generated relevance cursor should not look up the cursor row")
+	}
+
+	// Only the optional PublishedAt pages over NULLs
+	if n := strings.Count(contentStr, "nullable = true"); n != 1 {
+		t.Errorf("Generated queries mark %d sort columns nullable, want 1 (published_at)", n)
+	}
+}
@@ -51,15 +51,17 @@ func scaffoldFiles(resource parser.ResourceIR) []scaffoldFile {
 type scaffoldTemplateData struct {
 	Resource      parser.ResourceIR
 	ProjectModule string
+	Resources     []parser.ResourceIR // All resources, used to resolve relationship targets
 }
 
 // ScaffoldResource writes scaffold-once files for a resource into resources/<name>/.
 // It skips any file that already exists on disk (protecting developer customizations).
-func ScaffoldResource(resource parser.ResourceIR, projectRoot, projectModule string) (*ScaffoldResult, error) {
+// resources is the full set of parsed resources, used to render related records.
+func ScaffoldResource(resource parser.ResourceIR, resources []parser.ResourceIR, projectRoot, projectModule string) (*ScaffoldResult, error) {
 	resourceDir := filepath.Join(projectRoot, "resources", snake(resource.Name))
 	result := &ScaffoldResult{}
 
-	rendered, err := renderScaffoldToMap(resource, projectModule)
+	rendered, err := renderScaffoldToMap(resource, resources, projectModule)
 	if err != nil {
 		return nil, fmt.Errorf("rendering scaffold templates: %w", err)
 	}
@@ -93,10 +95,11 @@ func ScaffoldResource(resource parser.ResourceIR, projectRoot, projectModule str
 
 // renderScaffoldToMap renders all scaffold templates into a map of outputPath -> rendered bytes.
 // Used by both ScaffoldResource and DiffResource.
-func renderScaffoldToMap(resource parser.ResourceIR, projectModule string) (map[string][]byte, error) {
+func renderScaffoldToMap(resource parser.ResourceIR, resources []parser.ResourceIR, projectModule string) (map[string][]byte, error) {
 	data := scaffoldTemplateData{
 		Resource:      resource,
 		ProjectModule: projectModule,
+		Resources:     resources,
 	}
 
 	files := scaffoldFiles(resource)
@@ -113,10 +116,10 @@ func renderScaffoldToMap(resource parser.ResourceIR, projectModule string) (map[
 
 // DiffResource produces a unified diff between on-disk scaffold files and freshly-rendered
 // scaffold output. Files that don't exist on disk are reported as "would be created".
-func DiffResource(resource parser.ResourceIR, projectRoot, projectModule string) (string, error) {
+func DiffResource(resource parser.ResourceIR, resources []parser.ResourceIR, projectRoot, projectModule string) (string, error) {
 	resourceDir := filepath.Join(projectRoot, "resources", snake(resource.Name))
 
-	rendered, err := renderScaffoldToMap(resource, projectModule)
+	rendered, err := renderScaffoldToMap(resource, resources, projectModule)
 	if err != nil {
 		return "", fmt.Errorf("rendering scaffold templates: %w", err)
 	}
@@ -35,10 +35,7 @@ func TestScaffoldTemplates(t *testing.T) {
 		},
 	}
 
-	data := struct {
-		Resource      parser.ResourceIR
-		ProjectModule string
-	}{
+	data := scaffoldTemplateData{
 		Resource:      resource,
 		ProjectModule: "github.com/example/myapp",
 	}
@@ -68,7 +65,7 @@ func TestScaffoldTemplates(t *testing.T) {
 				// Datastar SSE form element
 				"product-form",
 				"data-on:submit__prevent",
-				"@post('/products')",
+				"@post('/products', {headers: {'X-CSRF-Token': $_csrf}})",
 				// Dynamic data-signals via helper
 				"data-signals",
 				// data-bind on inline inputs (Bool, Int generate inline inputs with data-bind)
@@ -135,7 +132,7 @@ func TestScaffoldTemplates(t *testing.T) {
 				"data-on:click",
 				"@get('/products')",
 				// Filter input for filterable field (Name is Filterable)
-				"filter_name",
+				`name="name[contains]"`,
 				// Pagination controls
 				"Previous",
 				"Next",
@@ -42,7 +42,7 @@ func TestScaffoldResource(t *testing.T) {
 	resource := sampleProduct()
 	const module = "github.com/example/myapp"
 
-	result, err := ScaffoldResource(resource, dir, module)
+	result, err := ScaffoldResource(resource, nil, dir, module)
 	if err != nil {
 		t.Fatalf("ScaffoldResource failed: %v", err)
 	}
@@ -119,7 +119,7 @@ func TestScaffoldResource_SkipsExisting(t *testing.T) {
 		t.Fatalf("pre-creating form.templ: %v", err)
 	}
 
-	result, err := ScaffoldResource(resource, dir, module)
+	result, err := ScaffoldResource(resource, nil, dir, module)
 	if err != nil {
 		t.Fatalf("ScaffoldResource failed: %v", err)
 	}
@@ -150,11 +150,11 @@ func TestScaffoldResource_MultipleResources(t *testing.T) {
 	product := sampleProduct()
 	category := sampleCategory()
 
-	_, err := ScaffoldResource(product, dir, module)
+	_, err := ScaffoldResource(product, nil, dir, module)
 	if err != nil {
 		t.Fatalf("ScaffoldResource(product) failed: %v", err)
 	}
-	_, err = ScaffoldResource(category, dir, module)
+	_, err = ScaffoldResource(category, nil, dir, module)
 	if err != nil {
 		t.Fatalf("ScaffoldResource(category) failed: %v", err)
 	}
@@ -182,6 +182,34 @@ func TestScaffoldResource_MultipleResources(t *testing.T) {
 	}
 }
 
+// TestScaffoldResource_Relations verifies that the detail view renders related records
+// and the detail handler requests them via actions.WithIncludes.
+func TestScaffoldResource_Relations(t *testing.T) {
+	dir := t.TempDir()
+	resources := relationshipTestResources()
+	const module = "github.com/example/myapp"
+
+	if _, err := ScaffoldResource(resources[0], resources, dir, module); err != nil {
+		t.Fatalf("ScaffoldResource failed: %v", err)
+	}
+
+	detail := readFile(t, filepath.Join(dir, "resources", "product", "views", "detail.templ"))
+	for _, c := range []string{
+		"for _, related := range product.Reviews {",
+		`templ.SafeURL(fmt.Sprintf("/tags/%s", related.ID))`,
+		"fmt.Sprint(related.Label)",
+	} {
+		if !strings.Contains(detail, c) {
+			t.Errorf("detail.templ missing %q", c)
+		}
+	}
+
+	handlers := readFile(t, filepath.Join(dir, "resources", "product", "handlers.go"))
+	if !strings.Contains(handlers, `actions.WithIncludes(ctx, "reviews", "tags")`) {
+		t.Error("handlers.go HandleDetail does not request related records")
+	}
+}
+
 // TestDiffResource verifies that DiffResource produces a non-empty diff when a scaffolded
 // file has been modified on disk.
 func TestDiffResource(t *testing.T) {
@@ -190,7 +218,7 @@ func TestDiffResource(t *testing.T) {
 	const module = "github.com/example/myapp"
 
 	// First scaffold the resource
-	_, err := ScaffoldResource(resource, dir, module)
+	_, err := ScaffoldResource(resource, nil, dir, module)
 	if err != nil {
 		t.Fatalf("ScaffoldResource failed: %v", err)
 	}
@@ -203,7 +231,7 @@ func TestDiffResource(t *testing.T) {
 		t.Fatalf("modifying form.templ: %v", err)
 	}
 
-	diff, err := DiffResource(resource, dir, module)
+	diff, err := DiffResource(resource, nil, dir, module)
 	if err != nil {
 		t.Fatalf("DiffResource failed: %v", err)
 	}
@@ -225,7 +253,7 @@ func TestDiffResource_NoExistingFiles(t *testing.T) {
 	resource := sampleProduct()
 	const module = "github.com/example/myapp"
 
-	diff, err := DiffResource(resource, dir, module)
+	diff, err := DiffResource(resource, nil, dir, module)
 	if err != nil {
 		t.Fatalf("DiffResource failed: %v", err)
 	}
@@ -114,6 +114,8 @@ func TestGenerateTransaction(t *testing.T) {
 		"github.com/jackc/pgx/v5/pgxpool",
 		"github.com/riverqueue/river",
 		"InsertTx",
+		// Transactions scope row-level security to the context tenant
+		"auth.SetLocalTenant(ctx, tx)",
 	}
 
 	for _, expected := range expectedElements {
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
 	"strconv"
@@ -168,13 +169,24 @@ func extractSchemaDefinition(fset *token.FileSet, call *ast.CallExpr, source []b
 		} else if funcName == "Timestamps" {
 			resource.HasTimestamps = true
 		} else if isPermissionType(funcName) {
+			// An operation without roles is admitted by its permission string only.
 			op, roles := extractPermission(fset, argCall, source, filename)
-			if op != "" && len(roles) > 0 {
+			if op != "" {
 				if resource.Options.Permissions == nil {
 					resource.Options.Permissions = make(PermissionsIR)
 				}
 				resource.Options.Permissions[op] = roles
 			}
+		} else if funcName == "OwnedBy" {
+			resource.Options.OwnedBy = extractOwnedBy(rootCall)
+		} else if isScopeType(funcName) {
+			op, scopes := extractPermission(fset, argCall, source, filename)
+			if op != "" && len(scopes) > 0 {
+				if resource.Options.Scopes == nil {
+					resource.Options.Scopes = make(ScopesIR)
+				}
+				resource.Options.Scopes[op] = scopes
+			}
 		} else if isHooksType(funcName) {
 			hooks, err := extractHooks(argCall)
 			if err == nil {
@@ -186,9 +198,48 @@ func extractSchemaDefinition(fset *token.FileSet, call *ast.CallExpr, source []b
 		_ = rootCall
 	}
 
+	if resource.Options.OwnedBy != "" && !isOwnerField(resource, resource.Options.OwnedBy) {
+		diag := errors.NewDiagnostic(
+			errors.ErrInvalidFieldName,
+			fmt.Sprintf("schema.OwnedBy(%q) does not name a UUID field or BelongsTo foreign key of %s", resource.Options.OwnedBy, name),
+		).File(filename).Line(resource.SourceLine).
+			Hint(`declare schema.UUID("AuthorID") or schema.BelongsTo("Author", ...) and pass its ID field name`).Build()
+		diagnostics = append(diagnostics, diag)
+	}
+
 	return resource, diagnostics
 }
 
+// extractOwnedBy returns the field name passed to schema.OwnedBy(), or "" when
+// it is not a string literal.
+func extractOwnedBy(call *ast.CallExpr) string {
+	if call == nil || len(call.Args) != 1 {
+		return ""
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	field, _ := strconv.Unquote(lit.Value)
+	return field
+}
+
+// isOwnerField reports whether field is a UUID field of resource or the foreign
+// key field ("<Name>ID") of one of its BelongsTo relationships.
+func isOwnerField(resource *ResourceIR, field string) bool {
+	for _, f := range resource.Fields {
+		if f.Name == field && f.Type == "UUID" {
+			return true
+		}
+	}
+	for _, rel := range resource.Relationships {
+		if rel.Type == "BelongsTo" && rel.Name+"ID" == field {
+			return true
+		}
+	}
+	return false
+}
+
 // isFieldType checks if a function name is a field type constructor.
 func isFieldType(name string) bool {
 	fieldTypes := map[string]bool{
@@ -357,6 +408,16 @@ func extractRelationship(fset *token.FileSet, call *ast.CallExpr, source []byte,
 		}
 	}
 
+	// SetNull needs a nullable foreign key column, which only Optional provides
+	if rel.OnDelete == "SetNull" && !rel.Optional && (relType == "BelongsTo" || relType == "HasOne") {
+		diag := errors.NewDiagnostic(
+			errors.ErrInvalidModifierValue,
+			fmt.Sprintf("%s(%q) uses OnDelete(schema.SetNull) but its foreign key is required", relType, name),
+		).File(filename).Line(rel.SourceLine).
+			Hint("add .Optional() or use schema.Cascade or schema.Restrict").Build()
+		diagnostics = append(diagnostics, diag)
+	}
+
 	return rel, diagnostics
 }
 
@@ -431,6 +492,11 @@ func isPermissionType(name string) bool {
 	return name == "Permission"
 }
 
+// isScopeType checks if a function name is a Scope constructor.
+func isScopeType(name string) bool {
+	return name == "Scope"
+}
+
 // isHooksType checks if a function name is a WithHooks constructor.
 func isHooksType(name string) bool {
 	return name == "WithHooks"
@@ -524,9 +590,10 @@ func extractHooks(callExpr *ast.CallExpr) (HooksIR, error) {
 }
 
 // extractPermission extracts the operation and roles from a schema.Permission() call.
+// schema.Scope() calls share the same shape and are extracted the same way.
 func extractPermission(fset *token.FileSet, call *ast.CallExpr, source []byte, filename string) (string, []string) {
 	rootCall, _ := findRootCall(call)
-	if rootCall == nil || len(rootCall.Args) < 2 {
+	if rootCall == nil || len(rootCall.Args) < 1 {
 		return "", nil
 	}
 	// First arg: operation string
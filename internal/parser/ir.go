@@ -43,6 +43,11 @@ type RelationshipIR struct {
 // Example: {"list": ["admin", "editor"], "delete": ["admin"]}
 type PermissionsIR map[string][]string
 
+// ScopesIR maps operation names to the API key scopes required to perform them,
+// overriding the default "<resources>:read" / "<resources>:write" scopes.
+// Example: {"list": ["catalog:read"]}
+type ScopesIR map[string][]string
+
 // JobRefIR represents a River job reference extracted from a schema.JobRef literal.
 // Kind is the job worker kind string; Queue is the River queue name.
 type JobRefIR struct {
@@ -64,6 +69,8 @@ type ResourceOptionsIR struct {
 	TenantScoped bool          // Enable multi-tenancy scoping
 	Searchable   bool          // Enable full-text search
 	Permissions  PermissionsIR // Role-based permission rules per operation
+	OwnedBy      string        // Field holding the owning user's ID; enables the "owner" role
+	Scopes       ScopesIR      // API key scope overrides per operation
 	Hooks        HooksIR       // Lifecycle River job enqueueing declarations
 }
 
@@ -186,7 +186,7 @@ import "github.com/alternayte/forge/schema"
 
 var Post = schema.Define("Post",
 	schema.String("Title"),
-	schema.BelongsTo("Category", "categories").Optional().OnDelete(schema.SetNull),
+	schema.BelongsTo("Category", "categories").Optional().OnDelete(schema.SetNull).Eager(),
 )
 `
 	result, err := ParseString(source, "test.go")
@@ -219,6 +219,9 @@ var Post = schema.Define("Post",
 	if rel.OnDelete != "SetNull" {
 		t.Errorf("Expected relationship.OnDelete='SetNull', got '%s'", rel.OnDelete)
 	}
+	if !rel.Eager {
+		t.Errorf("Expected relationship.Eager=true, got false")
+	}
 }
 
 // TestParseResourceOptions tests parsing resource-level options
@@ -436,3 +439,126 @@ var Post = schema.Define("Post",
 		}
 	}
 }
+
+func TestParseScopes(t *testing.T) {
+	source := `package resources
+
+import "github.com/alternayte/forge/schema"
+
+var Product = schema.Define("Product",
+	schema.String("Name"),
+	schema.Scope("list", "catalog:read"),
+	schema.Scope("delete", "catalog:admin", "products:write"),
+)
+`
+	result, err := ParseString(source, "test.go")
+	if err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %d: %v", len(result.Errors), result.Errors)
+	}
+
+	scopes := result.Resources[0].Options.Scopes
+	if got := scopes["list"]; len(got) != 1 || got[0] != "catalog:read" {
+		t.Errorf("Expected list scopes [catalog:read], got %v", got)
+	}
+	if got := scopes["delete"]; len(got) != 2 || got[1] != "products:write" {
+		t.Errorf("Expected delete scopes [catalog:admin products:write], got %v", got)
+	}
+}
+
+func TestParsePermissions(t *testing.T) {
+	source := `package resources
+
+import "github.com/alternayte/forge/schema"
+
+var Product = schema.Define("Product",
+	schema.String("Name"),
+	schema.Permission("update", "admin", "editor"),
+	schema.Permission("delete"),
+)
+`
+	result, err := ParseString(source, "test.go")
+	if err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %d: %v", len(result.Errors), result.Errors)
+	}
+
+	perms := result.Resources[0].Options.Permissions
+	if got := perms["update"]; len(got) != 2 || got[0] != "admin" {
+		t.Errorf("Expected update roles [admin editor], got %v", got)
+	}
+	if got, ok := perms["delete"]; !ok || len(got) != 0 {
+		t.Errorf("Expected delete permission without roles, got %v (present=%v)", got, ok)
+	}
+}
+
+func TestParseOwnedBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   string
+		wantErr bool
+	}{
+		{"uuid field", `schema.UUID("AuthorID"),`, false},
+		{"belongs to", `schema.BelongsTo("Author", "users"),`, false},
+		{"unknown field", `schema.String("AuthorID"),`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := `package resources
+
+import "github.com/alternayte/forge/schema"
+
+var Post = schema.Define("Post",
+	` + tt.items + `
+	schema.OwnedBy("AuthorID"),
+	schema.Permission("update", "owner", "admin"),
+)
+`
+			result, err := ParseString(source, "test.go")
+			if err != nil {
+				t.Fatalf("ParseString failed: %v", err)
+			}
+			if got := len(result.Errors) > 0; got != tt.wantErr {
+				t.Fatalf("errors = %v, want error %v", result.Errors, tt.wantErr)
+			}
+			if got := result.Resources[0].Options.OwnedBy; got != "AuthorID" {
+				t.Errorf("Expected OwnedBy AuthorID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestParseSetNullRequiresOptional(t *testing.T) {
+	tests := []struct {
+		name    string
+		rel     string
+		wantErr bool
+	}{
+		{"optional", `schema.BelongsTo("Category", "categories").Optional().OnDelete(schema.SetNull),`, false},
+		{"required", `schema.BelongsTo("Category", "categories").OnDelete(schema.SetNull),`, true},
+		{"required cascade", `schema.BelongsTo("Category", "categories").OnDelete(schema.Cascade),`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := `package resources
+
+import "github.com/alternayte/forge/schema"
+
+var Product = schema.Define("Product",
+	` + tt.rel + `
+)
+`
+			result, err := ParseString(source, "test.go")
+			if err != nil {
+				t.Fatalf("ParseString failed: %v", err)
+			}
+			if got := len(result.Errors) > 0; got != tt.wantErr {
+				t.Errorf("errors = %v, want error %v", result.Errors, tt.wantErr)
+			}
+		})
+	}
+}
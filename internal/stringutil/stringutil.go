@@ -16,6 +16,20 @@ func Plural(s string) string {
 	return s + "s"
 }
 
+// Singular naively singularizes a string, reversing Plural.
+func Singular(s string) string {
+	if strings.HasSuffix(s, "ies") {
+		return s[:len(s)-3] + "y"
+	}
+	if strings.HasSuffix(s, "ses") || strings.HasSuffix(s, "xes") || strings.HasSuffix(s, "ches") || strings.HasSuffix(s, "shes") {
+		return s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "s") && !strings.HasSuffix(s, "ss") {
+		return s[:len(s)-1]
+	}
+	return s
+}
+
 // Snake converts PascalCase to snake_case.
 func Snake(s string) string {
 	if len(s) == 0 {
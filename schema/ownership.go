@@ -0,0 +1,22 @@
+package schema
+
+// OwnedByItem names the field that holds the ID of the user who owns each row.
+type OwnedByItem struct {
+	Field string // UUID field or BelongsTo foreign key field, e.g. "AuthorID"
+}
+
+// schemaItem implements the SchemaItem interface.
+func (o *OwnedByItem) schemaItem() {}
+
+// OwnedBy declares the field holding the owning user's ID: a UUID field or the
+// foreign key field of a BelongsTo relationship. List the "owner" role in a
+// Permission to let users perform that operation on the rows they own. Rows
+// owned by someone else are reported as not found.
+//
+// Example:
+//
+//	schema.OwnedBy("AuthorID"),
+//	schema.Permission("update", "owner", "admin"),
+func OwnedBy(field string) *OwnedByItem {
+	return &OwnedByItem{Field: field}
+}
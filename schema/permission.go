@@ -11,7 +11,10 @@ type PermissionItem struct {
 func (p *PermissionItem) schemaItem() {}
 
 // Permission creates a resource-level permission rule restricting the given
-// operation to the specified roles.
+// operation to the specified roles. Users without one of the roles are still
+// admitted when they hold the permission string "<resource>.<operation>"
+// (e.g. "product.update"), such as through auth.RBAC; with no roles, only the
+// permission string grants access.
 //
 // Example: schema.Permission("list", "admin", "editor")
 func Permission(operation string, roles ...string) *PermissionItem {
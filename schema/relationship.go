@@ -33,6 +33,7 @@ type Relationship struct {
 	table     string
 	onDelete  OnDeleteAction
 	isOptional bool
+	isEager    bool
 }
 
 // Name returns the relationship name.
@@ -60,6 +61,11 @@ func (r *Relationship) IsOptional() bool {
 	return r.isOptional
 }
 
+// IsEager returns whether the relationship is loaded with every List and Get.
+func (r *Relationship) IsEager() bool {
+	return r.isEager
+}
+
 // schemaItem implements the SchemaItem interface.
 func (r *Relationship) schemaItem() {}
 
@@ -69,6 +75,12 @@ func (r *Relationship) Optional() *Relationship {
 	return r
 }
 
+// Eager marks the relationship to be batch-loaded with every List and Get.
+func (r *Relationship) Eager() *Relationship {
+	r.isEager = true
+	return r
+}
+
 // OnDelete sets the action to take when the referenced record is deleted.
 func (r *Relationship) OnDelete(action OnDeleteAction) *Relationship {
 	r.onDelete = action
@@ -85,7 +97,8 @@ func BelongsTo(name, table string) *Relationship {
 	}
 }
 
-// HasMany creates a has-many relationship.
+// HasMany creates a has-many relationship. The related resource must declare
+// the matching BelongsTo, whose foreign key the records are loaded by.
 func HasMany(name, table string) *Relationship {
 	return &Relationship{
 		name:     name,
@@ -105,7 +118,8 @@ func HasOne(name, table string) *Relationship {
 	}
 }
 
-// ManyToMany creates a many-to-many relationship.
+// ManyToMany creates a many-to-many relationship. A resource related to itself
+// gets a join table named after the relationship (e.g. "users_friends").
 func ManyToMany(name, table string) *Relationship {
 	return &Relationship{
 		name:     name,
@@ -12,7 +12,7 @@ func TestProductSchema(t *testing.T) {
 		Decimal("Price").Required().Filterable().Sortable(),
 		Enum("Status", "draft", "published", "archived").Default("draft"),
 		Bool("Featured").Default(false),
-		BelongsTo("Category", "categories").Optional().OnDelete(SetNull),
+		BelongsTo("Category", "categories").Optional().OnDelete(SetNull).Eager(),
 		HasMany("Reviews", "reviews"),
 		Timestamps(),
 	)
@@ -77,6 +77,9 @@ func TestProductSchema(t *testing.T) {
 	if rels[0].OnDeleteAction() != SetNull {
 		t.Errorf("Expected first relationship OnDelete to be SetNull, got %s", rels[0].OnDeleteAction().String())
 	}
+	if !rels[0].IsEager() {
+		t.Error("Expected first relationship to be eager")
+	}
 
 	if rels[1].RelType() != RelHasMany {
 		t.Errorf("Expected second relationship to be HasMany, got %s", rels[1].RelType().String())
@@ -0,0 +1,22 @@
+package schema
+
+// ScopeItem overrides the API key scopes required for a resource operation.
+// Without an override, read operations require "<resources>:read" and
+// mutations require "<resources>:write".
+type ScopeItem struct {
+	Operation string   // One of: "list", "read", "create", "update", "delete"
+	Scopes    []string // Scopes an API key must hold to perform the operation
+}
+
+// schemaItem implements the SchemaItem interface.
+func (s *ScopeItem) schemaItem() {}
+
+// Scope overrides the API key scopes required for the given operation.
+//
+// Example: schema.Scope("list", "catalog:read")
+func Scope(operation string, scopes ...string) *ScopeItem {
+	return &ScopeItem{
+		Operation: operation,
+		Scopes:    scopes,
+	}
+}